@@ -0,0 +1,129 @@
+package calendar
+
+import "time"
+
+// DateFormat is the layout of dates, as used in exceptions and when stored to DB.
+const DateFormat = "2006-01-02"
+
+// Hours are the regular opening hours of a weekday. Opens and Closes
+// are formatted as HH:MM.
+type Hours struct {
+	Weekday time.Weekday
+	Opens   string
+	Closes  string
+}
+
+// Exception overrides the regular opening hours on a given date, either
+// with different hours, or as closed if Opens is empty.
+type Exception struct {
+	Date   string // YYYY-MM-DD
+	Opens  string
+	Closes string
+	Note   string
+}
+
+// Closed reports whether the exception marks the library as closed.
+func (e Exception) Closed() bool {
+	return e.Opens == ""
+}
+
+// Holiday is a public holiday, on which the library is closed.
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// Calendar is the opening hours of a library branch.
+//
+// A Calendar without any weekly hours is considered to be open every day,
+// except on holidays and exceptions marked as closed.
+type Calendar struct {
+	Branch     string
+	Weekly     []Hours
+	Exceptions []Exception
+	// Holidays returns the public holidays of the given year. If nil, no
+	// public holidays are taken into account.
+	Holidays func(year int) []Holiday
+}
+
+// IsOpen reports whether the library is open on the date of t.
+func (c Calendar) IsOpen(t time.Time) bool {
+	date := t.Format(DateFormat)
+	for _, e := range c.Exceptions {
+		if e.Date == date {
+			return !e.Closed()
+		}
+	}
+	if c.Holidays != nil {
+		for _, h := range c.Holidays(t.Year()) {
+			if h.Date.Format(DateFormat) == date {
+				return false
+			}
+		}
+	}
+	if len(c.Weekly) == 0 {
+		return true
+	}
+	for _, h := range c.Weekly {
+		if h.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if the library is open on the date of t, otherwise t moved
+// forward to the first day the library is open. If the library is not open
+// on any day within the next year, t is returned unmodified.
+func (c Calendar) NextOpen(t time.Time) time.Time {
+	for i := 0; i <= 366; i++ {
+		d := t.AddDate(0, 0, i)
+		if c.IsOpen(d) {
+			return d
+		}
+	}
+	return t
+}
+
+// easter returns the date of Easter Sunday in the given year, using
+// the anonymous Gregorian algorithm (Meeus/Jones/Butcher).
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+}
+
+// NorwegianHolidays returns the Norwegian public holidays (helligdager and
+// høytidsdager) of the given year, in chronological order.
+func NorwegianHolidays(year int) []Holiday {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	e := easter(year)
+	return []Holiday{
+		{Date: date(time.January, 1), Name: "Første nyttårsdag"},
+		{Date: e.AddDate(0, 0, -3), Name: "Skjærtorsdag"},
+		{Date: e.AddDate(0, 0, -2), Name: "Langfredag"},
+		{Date: e, Name: "Første påskedag"},
+		{Date: e.AddDate(0, 0, 1), Name: "Andre påskedag"},
+		{Date: date(time.May, 1), Name: "Offentlig høytidsdag"},
+		{Date: date(time.May, 17), Name: "Grunnlovsdag"},
+		{Date: e.AddDate(0, 0, 39), Name: "Kristi himmelfartsdag"},
+		{Date: e.AddDate(0, 0, 49), Name: "Første pinsedag"},
+		{Date: e.AddDate(0, 0, 50), Name: "Andre pinsedag"},
+		{Date: date(time.December, 25), Name: "Første juledag"},
+		{Date: date(time.December, 26), Name: "Andre juledag"},
+	}
+}
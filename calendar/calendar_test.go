@@ -0,0 +1,96 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEaster(t *testing.T) {
+	tests := map[int]string{
+		2019: "2019-04-21",
+		2022: "2022-04-17",
+		2023: "2023-04-09",
+		2024: "2024-03-31",
+		2038: "2038-04-25",
+	}
+	for year, want := range tests {
+		if got := easter(year).Format(DateFormat); got != want {
+			t.Errorf("easter(%d) = %s; want %s", year, got, want)
+		}
+	}
+}
+
+func TestNorwegianHolidays(t *testing.T) {
+	want := []string{
+		"2022-01-01",
+		"2022-04-14",
+		"2022-04-15",
+		"2022-04-17",
+		"2022-04-18",
+		"2022-05-01",
+		"2022-05-17",
+		"2022-05-26",
+		"2022-06-05",
+		"2022-06-06",
+		"2022-12-25",
+		"2022-12-26",
+	}
+	got := NorwegianHolidays(2022)
+	if len(got) != len(want) {
+		t.Fatalf("got %d holidays; want %d", len(got), len(want))
+	}
+	for i, h := range got {
+		if h.Date.Format(DateFormat) != want[i] {
+			t.Errorf("%s = %s; want %s", h.Name, h.Date.Format(DateFormat), want[i])
+		}
+	}
+}
+
+func TestCalendarNextOpen(t *testing.T) {
+	cal := Calendar{
+		Weekly: []Hours{
+			{Weekday: time.Monday, Opens: "10:00", Closes: "19:00"},
+			{Weekday: time.Tuesday, Opens: "10:00", Closes: "19:00"},
+			{Weekday: time.Wednesday, Opens: "10:00", Closes: "19:00"},
+			{Weekday: time.Thursday, Opens: "10:00", Closes: "19:00"},
+			{Weekday: time.Friday, Opens: "10:00", Closes: "16:00"},
+		},
+		Exceptions: []Exception{
+			{Date: "2022-04-19", Note: "Inventory"},
+			{Date: "2022-05-01", Opens: "12:00", Closes: "16:00", Note: "Open sunday"},
+		},
+		Holidays: NorwegianHolidays,
+	}
+
+	date := func(s string) time.Time {
+		t, err := time.ParseInLocation(DateFormat, s, time.Local)
+		if err != nil {
+			panic(err)
+		}
+		return t.Add(12 * time.Hour)
+	}
+
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2022-04-12", "2022-04-12"}, // regular tuesday
+		{"2022-04-16", "2022-04-20"}, // saturday, easter monday and exception
+		{"2022-05-01", "2022-05-01"}, // open on holiday by exception
+		{"2022-05-17", "2022-05-18"}, // national day
+		{"2022-12-24", "2022-12-27"},
+	}
+	for _, test := range tests {
+		got := cal.NextOpen(date(test.date))
+		if got.Format(DateFormat) != test.want {
+			t.Errorf("NextOpen(%s) = %s; want %s", test.date, got.Format(DateFormat), test.want)
+		}
+		if got.Hour() != 12 {
+			t.Errorf("NextOpen(%s) did not preserve time of day: %v", test.date, got)
+		}
+	}
+
+	if got := (Calendar{}).NextOpen(date("2022-05-17")); got.Format(DateFormat) != "2022-05-17" {
+		t.Errorf("empty calendar NextOpen = %v; want same day", got)
+	}
+}
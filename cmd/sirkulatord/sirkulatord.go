@@ -2,16 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/smtp"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 
 	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/etl"
 	"github.com/knakk/sirkulator/http"
+	"github.com/knakk/sirkulator/notice"
 	"github.com/knakk/sirkulator/search"
 	"github.com/knakk/sirkulator/sql"
 	"golang.org/x/text/language"
@@ -46,6 +55,36 @@ type Config struct {
 	Lang      language.Tag
 	AssetsDir string
 	DataDir   string
+	DryRun    bool   // only list pending database migrations
+	Awards    string // CSV file of literary awards, imported by the import_literary_awards job
+	Backup    BackupConfig
+	SMTP      SMTPConfig
+}
+
+// BackupConfig is the configuration of database backups. If Restore is set,
+// the databases are restored from that backup directory, and the program exits.
+type BackupConfig struct {
+	Dir     string
+	Keep    int
+	Restore string
+}
+
+// SMTPConfig is the configuration of the SMTP server used to send notices
+// to patrons. If Addr is empty, notices are written to files in the data directory.
+type SMTPConfig struct {
+	Addr     string
+	From     string
+	User     string
+	Password string
+}
+
+// String returns the config with the password masked, so it can be safely logged.
+func (c SMTPConfig) String() string {
+	pass := ""
+	if c.Password != "" {
+		pass = "****"
+	}
+	return fmt.Sprintf("{Addr:%s From:%s User:%s Password:%s}", c.Addr, c.From, c.User, pass)
 }
 
 func parseFlags(args []string) Config {
@@ -53,7 +92,7 @@ func parseFlags(args []string) Config {
 		Lang: language.Norwegian, // default language
 	}
 	fs := flag.NewFlagSet("sirkulatord", flag.ExitOnError)
-	fs.Func("lang", "language: 'no' for Norwegian or 'en' for English (default: 'no')", func(s string) error {
+	fs.Func("lang", "language of the user interface and search index text analysis: 'no' for Norwegian or 'en' for English (default: 'no')", func(s string) error {
 		switch strings.ToLower(s) {
 		case "no", "no-nb":
 			conf.Lang = language.Norwegian
@@ -67,35 +106,152 @@ func parseFlags(args []string) Config {
 	fs.IntVar(&conf.Port, "port", 9999, "port")
 	fs.StringVar(&conf.AssetsDir, "assets", "", "assets directory, overriding default embedded static assets")
 	fs.StringVar(&conf.DataDir, "db", "data", "data directory")
+	fs.BoolVar(&conf.DryRun, "migrate-dry-run", false, "list pending database migrations and exit")
+	fs.StringVar(&conf.Awards, "awards", "", "CSV file of literary awards to import (default: bundled seed data)")
+	fs.StringVar(&conf.Backup.Dir, "backup-dir", "", "directory of database backups (default: backup in data directory)")
+	fs.IntVar(&conf.Backup.Keep, "backup-keep", 7, "number of database backups to keep")
+	fs.StringVar(&conf.Backup.Restore, "restore", "", "restore databases from the given backup directory, rebuild the search index and exit")
+	fs.StringVar(&conf.SMTP.Addr, "smtp", "", "SMTP server address (host:port) for sending notices (default: write notices to data directory)")
+	fs.StringVar(&conf.SMTP.From, "smtp-from", "", "sender address of notices")
+	fs.StringVar(&conf.SMTP.User, "smtp-user", "", "SMTP username")
+	fs.StringVar(&conf.SMTP.Password, "smtp-password", "", "SMTP password")
 	fs.Parse(args)
+	if conf.Backup.Dir == "" {
+		conf.Backup.Dir = filepath.Join(conf.DataDir, "backup")
+	}
 	return conf
 }
 
+// ensureAdmin creates an admin user with a random password if there are no
+// users, so that it is possible to log in on a fresh installation. The password
+// is printed to stdout, and should be changed after logging in.
+func ensureAdmin(db *sqlitex.Pool) error {
+	conn := db.Get(context.Background())
+	defer db.Put(conn)
+
+	n, err := sql.CountUsers(conn)
+	if err != nil || n > 0 {
+		return err
+	}
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+	user := sirkulator.User{Username: "admin", Name: "Administrator", Role: sirkulator.RoleAdmin}
+	if _, err := sql.SaveUser(conn, user, password); err != nil {
+		return err
+	}
+	fmt.Printf("created user %q with password %q\n", user.Username, password)
+	return nil
+}
+
+// restore restores the databases from the backup given in the config, and
+// rebuilds the search index from the restored databases.
+func restore(conf Config) error {
+	if err := sql.Restore(conf.Backup.Restore, conf.DataDir); err != nil {
+		return err
+	}
+	fmt.Printf("restored databases from %s\n", conf.Backup.Restore)
+
+	// Open the restored databases, which also runs any pending migrations.
+	db, err := sql.OpenAt(conf.DataDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := os.RemoveAll(filepath.Join(conf.DataDir, "index")); err != nil {
+		return err
+	}
+	idx, err := search.Open(conf.DataDir+"/index", conf.Lang)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	indexer := search.Indexer{DB: db, Idx: idx, BatchSize: 100}
+	return indexer.Run(context.Background(), os.Stdout)
+}
+
 func main() {
 	// Parse flags into a valid Config, will exit(1) on errors.
 	conf := parseFlags(os.Args[1:])
 
-	// Create databases and init connection pool
+	if conf.DryRun {
+		todo, err := sql.PendingMigrations(conf.DataDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(todo) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		for _, m := range todo {
+			fmt.Println(m)
+		}
+		return
+	}
+
+	if conf.Backup.Restore != "" {
+		if err := restore(conf); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Create databases, run migrations and init connection pool
 	db, err := sql.OpenAt(conf.DataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := ensureAdmin(db); err != nil {
+		log.Fatal(err)
+	}
+
 	// Setup search index
-	idx, err := search.Open(conf.DataDir + "/index")
+	idx, err := search.Open(conf.DataDir+"/index", conf.Lang)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Setup transport for notices to patrons
+	var notices notice.Transport = &notice.Dir{Path: filepath.Join(conf.DataDir, "notices")}
+	if conf.SMTP.Addr != "" {
+		t := &notice.SMTP{Addr: conf.SMTP.Addr, From: conf.SMTP.From}
+		if conf.SMTP.User != "" {
+			host, _, _ := net.SplitHostPort(conf.SMTP.Addr)
+			t.Auth = smtp.PlainAuth("", conf.SMTP.User, conf.SMTP.Password, host)
+		}
+		notices = t
+	}
+
 	// Set up base context and shutdown signal handler.
 	ctx, cancel := context.WithCancel(context.Background())
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt)
 	go func() { <-shutdown; cancel() }()
 
+	// Rebuild the search index in the background if it was built with another
+	// text analysis, ex: after changing the language.
+	if idx.Stale() {
+		log.Println("search index is not built with the current text analysis; rebuilding")
+		go func() {
+			indexer := search.Indexer{DB: db, Idx: idx, BatchSize: 100}
+			if err := indexer.Run(ctx, io.Discard); err != nil {
+				log.Printf("rebuilding search index: %v", err)
+				return
+			}
+			log.Println("search index rebuilt")
+		}()
+	}
+
+	backup := &sql.BackupJob{DB: db, Dir: conf.Backup.Dir, Keep: conf.Backup.Keep}
+	awards := &etl.ImportAwardsJob{DB: db, File: conf.Awards}
+
 	m := Main{
 		Config:     conf,
-		HTTPServer: http.NewServer(ctx, conf.AssetsDir, db, idx),
+		HTTPServer: http.NewServer(ctx, conf.AssetsDir, db, idx, notices, backup, awards),
 		DB:         db,
 	}
 
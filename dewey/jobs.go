@@ -6,19 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"crawshaw.io/sqlite/sqlitex"
 	"github.com/knakk/rdf"
 	"github.com/knakk/sirkulator"
 	"github.com/knakk/sirkulator/http/client"
-	"github.com/knakk/sirkulator/search"
+	"github.com/knakk/sirkulator/sql"
 	"github.com/knakk/sirkulator/vocab"
 	"github.com/knakk/sparql"
 )
@@ -51,13 +49,11 @@ func (f FusekiDescribeNT) GenRequest(endpoint string) (*http.Request, error) {
 }
 
 type ImportJob struct {
-	wg        sync.WaitGroup // keeping track of indexing TODO consider sync.ErrGroup
 	peek      *rdf.Triple
 	peekParts []string
 
 	// Setup:
 	DB        *sqlitex.Pool
-	Idx       *search.Index
 	BatchSize int // Number of resources to persist to DB at a time
 	Update    bool
 }
@@ -145,7 +141,6 @@ func (j *ImportJob) Run(ctx context.Context, w io.Writer) error {
 		}
 		c += len(batch.Resources)
 
-		go j.index(batch.Resources)
 		w.Write([]byte("."))
 	}
 	w.Write([]byte("\n"))
@@ -181,9 +176,6 @@ func (j *ImportJob) Run(ctx context.Context, w io.Writer) error {
 		fmt.Fprintf(w, "failed to delete duplicate relations: %v\n", err)
 	}
 
-	// Wait for indexing to complete
-	j.wg.Wait()
-
 	if j.Update {
 		fmt.Fprintf(w, "done updating/importing %d dewey numbers\n", c)
 		// TODO list numbers if less than < 30?
@@ -363,6 +355,9 @@ func (j *ImportJob) persist(ctx context.Context, batch importBatch) (err error)
 	defer sqlitex.Save(conn)(&err)
 
 	for _, res := range batch.Resources {
+		if err := sql.RecordEdit(conn, res, j.Name(), time.Now()); err != nil {
+			return err
+		}
 		stmt := conn.Prep(`
 			INSERT INTO resource (type, id, label, data, created_at, updated_at, archived_at)
 				VALUES ($type, $id, $label, $data, $created, $updated, $archived)
@@ -428,16 +423,3 @@ func (j *ImportJob) persist(ctx context.Context, batch importBatch) (err error)
 
 	return nil
 }
-
-func (j *ImportJob) index(batch []sirkulator.Resource) {
-	j.wg.Add(1)
-	defer j.wg.Done()
-
-	docs := make([]search.Document, 0, len(batch))
-	for _, r := range batch {
-		docs = append(docs, r.Document())
-	}
-	if err := j.Idx.Store(docs...); err != nil {
-		log.Printf("ImportJob.index: %v", err) // TODO remove, or write to w
-	}
-}
@@ -1,9 +1,10 @@
 package sirkulator
 
-import "fmt"
-
-// TODO read this:
-// https://blog.carlmjohnson.net/post/2020/working-with-errors-as/
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 // Application error codes, which map nicely to http status codes
 const (
@@ -12,10 +13,10 @@ const (
 	CodeInvalid      = "invalid"      // 400 user errors
 	CodeNotFound     = "not_found"    // 404
 	CodeUnauthorized = "unauthorized" // 401
-
-	// TODO theese also?
-	// ErrTimeout	      = "timeout"            504 http.StatusGatewayTimeout
-	// ErrTempUnavailable = "temp_unavaialable"  503 http.StatusServiceUnavailable
+	CodeForbidden    = "forbidden"    // 403
+	CodePrecondition = "precondition" // 412 resource changed since it was read
+	CodeTimeout      = "timeout"      // 504 operation did not complete in time
+	CodeUnavailable  = "unavailable"  // 503 temporarily unavailable, ie. no database connection
 )
 
 var (
@@ -24,21 +25,101 @@ var (
 	ErrInvalid      = Error{Code: CodeInvalid}
 	ErrNotFound     = Error{Code: CodeNotFound}
 	ErrUnauthorized = Error{Code: CodeUnauthorized}
+	ErrForbidden    = Error{Code: CodeForbidden}
+	ErrTimeout      = Error{Code: CodeTimeout}
+	ErrUnavailable  = Error{Code: CodeUnavailable}
 )
 
+// Error is an application error. Code classifies the error, and Message is
+// meant to be read by the end user. Err is the underlying error, if any.
 type Error struct {
 	Code    string
 	Message string
+	Err     error
 }
 
 func (e Error) Error() string {
+	if e.Err != nil && e.Message == "" {
+		return fmt.Sprintf("sirkulator: code=%s err=%v", e.Code, e.Err)
+	}
 	return fmt.Sprintf("sirkulator: code=%s message=%s", e.Code, e.Message)
 }
 
-// Errorf returns an Error with the given code and formatted message.
+// Unwrap returns the underlying error.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is an Error with the same code, so that
+// errors.Is(err, ErrNotFound) holds for any not found error. If the target
+// has a message, it must also match.
+func (e Error) Is(target error) bool {
+	var t Error
+	switch v := target.(type) {
+	case Error:
+		t = v
+	case *Error:
+		if v == nil {
+			return false
+		}
+		t = *v
+	default:
+		return false
+	}
+	return t.Code == e.Code && (t.Message == "" || t.Message == e.Message)
+}
+
+// Errorf returns an Error with the given code and formatted message. As with
+// fmt.Errorf, an error argument can be wrapped with the %w verb.
 func Errorf(code string, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
 	return &Error{
 		Code:    code,
-		Message: fmt.Sprintf(format, args...),
+		Message: err.Error(),
+		Err:     errors.Unwrap(err),
+	}
+}
+
+// WrapError returns an Error with the given code wrapping err. The
+// message is left empty, since the underlying error is not meant for end users.
+func WrapError(code string, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+// asError finds the first Error in err's chain, either as value or pointer.
+func asError(err error) (Error, bool) {
+	var ptr *Error
+	if errors.As(err, &ptr) && ptr != nil {
+		return *ptr, true
+	}
+	var e Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return e, false
+}
+
+// ErrorCode returns the code of the given error. Errors which are not an
+// Error are reported as CodeInternal, except for context deadlines, which
+// are reported as CodeTimeout.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if e, ok := asError(err); ok {
+		return e.Code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CodeTimeout
+	}
+	return CodeInternal
+}
+
+// ErrorMessage returns the message of the given error, which is safe to show
+// to end users. It is empty for internal errors, and for errors without a message.
+func ErrorMessage(err error) string {
+	if e, ok := asError(err); ok && e.Code != CodeInternal {
+		return e.Message
 	}
+	return ""
 }
@@ -0,0 +1,50 @@
+package sirkulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	err := fmt.Errorf("loading: %w", Errorf(CodeNotFound, "no item with barcode %s", "0301"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("errors.Is(err, ErrNotFound) = false; want true")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Error("errors.Is(err, ErrConflict) = true; want false")
+	}
+	if got := ErrorCode(err); got != CodeNotFound {
+		t.Errorf("ErrorCode = %q; want %q", got, CodeNotFound)
+	}
+	if got := ErrorMessage(err); got != "no item with barcode 0301" {
+		t.Errorf("ErrorMessage = %q; want %q", got, "no item with barcode 0301")
+	}
+
+	wrapped := Errorf(CodeInvalid, "invalid data: %w", io.ErrUnexpectedEOF)
+	if !errors.Is(wrapped, io.ErrUnexpectedEOF) {
+		t.Error("Errorf with %w does not wrap the error")
+	}
+
+	tests := []struct {
+		err  error
+		code string
+		msg  string
+	}{
+		{ErrNotFound, CodeNotFound, ""},
+		{WrapError(CodeUnavailable, io.EOF), CodeUnavailable, ""},
+		{Errorf(CodeInternal, "secret details"), CodeInternal, ""},
+		{errors.New("boom"), CodeInternal, ""},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), CodeTimeout, ""},
+	}
+	for _, test := range tests {
+		if got := ErrorCode(test.err); got != test.code {
+			t.Errorf("ErrorCode(%v) = %q; want %q", test.err, got, test.code)
+		}
+		if got := ErrorMessage(test.err); got != test.msg {
+			t.Errorf("ErrorMessage(%v) = %q; want %q", test.err, got, test.msg)
+		}
+	}
+}
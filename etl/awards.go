@@ -0,0 +1,256 @@
+package etl
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/sql"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+//go:embed assets/literary_awards.csv
+var seedAwards []byte
+
+// ImportAwardsJob imports literary awards, with their organizers, nominees
+// and winners, from a CSV file.
+type ImportAwardsJob struct {
+	DB   *sqlitex.Pool
+	File string // CSV file to import, or the bundled seed data if empty
+}
+
+func (j *ImportAwardsJob) Name() string {
+	return "import_literary_awards"
+}
+
+func (j *ImportAwardsJob) Run(ctx context.Context, w io.Writer) error {
+	var r io.Reader = bytes.NewReader(seedAwards)
+	if j.File != "" {
+		f, err := os.Open(j.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		fmt.Fprintln(w, "importing bundled literary awards seed data")
+	}
+
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	res, err := ImportAwards(conn, r, "sirkulator")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "created %d awards and %d relations, of which %d are queued for review\n",
+		len(res.Awards), res.Relations, res.Reviews)
+	return nil
+}
+
+// AwardImport sums up the result of importing literary awards.
+type AwardImport struct {
+	Awards    []sirkulator.Resource // created awards
+	Relations int                   // number of created relations
+	Reviews   int                   // number of created relations without a matching resource
+}
+
+// awardColumns are the columns of a CSV file of literary awards. Each row
+// has the name of the award, and optionally its organizer (a corporation)
+// and the year it was established, and/or a nomination: the year, the result
+// (winner or nominee), the type of the nominee (person or publication) and
+// its name or title.
+var awardColumns = []string{"award", "organizer", "established", "year", "result", "type", "name"}
+
+// ImportAwards imports literary awards from the given CSV. Awards are matched
+// by name, and created if they don't exist. Organizers and nominees are
+// matched by name against existing corporations, persons and publications;
+// when there is not exactly one match, the relation is queued for review.
+// Relations which already exist are not duplicated, so the same file can be
+// imported more than once.
+func ImportAwards(conn *sqlite.Conn, r io.Reader, actor string) (res AwardImport, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(awardColumns)
+	header, err := cr.Read()
+	if err != nil {
+		return res, fmt.Errorf("etl.ImportAwards: %w", err)
+	}
+	for i, col := range awardColumns {
+		if strings.TrimSpace(header[i]) != col {
+			return res, fmt.Errorf("etl.ImportAwards: column %d is %q; want %q", i+1, header[i], col)
+		}
+	}
+
+	awards := make(map[string]string) // lowercased name -> ID
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("etl.ImportAwards: %w", err)
+		}
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		line, _ := cr.FieldPos(0)
+		name, organizer, established, year, result, typ, nominee := row[0], row[1], row[2], row[3], row[4], row[5], row[6]
+		if name == "" {
+			return res, fmt.Errorf("etl.ImportAwards: line %d: missing award", line)
+		}
+
+		awardID, ok := awards[strings.ToLower(name)]
+		if !ok {
+			awardID, err = matchResource(conn, sirkulator.TypeLiteraryAward, "name", name)
+			if err != nil {
+				return res, err
+			}
+		}
+		if awardID == "" {
+			award := sirkulator.LiteraryAward{
+				Name:      name,
+				YearRange: sirkulator.YearRange{From: json.Number(established)},
+			}
+			if !award.YearRange.Valid() {
+				return res, fmt.Errorf("etl.ImportAwards: line %d: invalid year established: %q", line, established)
+			}
+			a := sirkulator.Resource{
+				ID:    sirkulator.GetNewID(),
+				Type:  sirkulator.TypeLiteraryAward,
+				Label: award.Label(),
+				Data:  award,
+			}
+			if err := sql.CreateResource(conn, a, actor); err != nil {
+				return res, err
+			}
+			awardID = a.ID
+			res.Awards = append(res.Awards, a)
+		}
+		awards[strings.ToLower(name)] = awardID
+
+		if organizer != "" {
+			rel := sirkulator.Relation{FromID: awardID, Type: vocab.RelationOrganizedBy.String()}
+			if err := importAwardRelation(conn, &res, rel, sirkulator.TypeCorporation, organizer); err != nil {
+				return res, err
+			}
+		}
+
+		if nominee == "" {
+			continue
+		}
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return res, fmt.Errorf("etl.ImportAwards: line %d: invalid year: %q", line, year)
+		}
+		rel := sirkulator.Relation{FromID: awardID, Data: map[string]any{"year": y}}
+		switch result {
+		case "winner":
+			rel.Type = vocab.RelationHasWinner.String()
+		case "nominee":
+			rel.Type = vocab.RelationHasNominee.String()
+		default:
+			return res, fmt.Errorf("etl.ImportAwards: line %d: result must be winner or nominee; got %q", line, result)
+		}
+		t := sirkulator.ParseResourceType(typ)
+		if !vocab.ParseRelation(rel.Type).Allowed(sirkulator.TypeLiteraryAward.String(), t.String()) {
+			return res, fmt.Errorf("etl.ImportAwards: line %d: nominee must be a person or publication; got %q", line, typ)
+		}
+		if err := importAwardRelation(conn, &res, rel, t, nominee); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// importAwardRelation creates the given relation to the resource of the given
+// type and name, or a review if there is no such resource, unless the
+// relation already exists.
+func importAwardRelation(conn *sqlite.Conn, res *AwardImport, rel sirkulator.Relation, t sirkulator.ResourceType, name string) error {
+	prop := "name"
+	if t == sirkulator.TypePublication {
+		prop = "title"
+	}
+	toID, err := matchResource(conn, t, prop, name)
+	if err != nil {
+		return err
+	}
+	if toID == "" {
+		if rel.Data == nil {
+			rel.Data = make(map[string]any)
+		}
+		rel.Data["label"] = name
+	}
+	rel.ToID = toID
+
+	stmt := conn.Prep(`
+		SELECT count(*) FROM relation
+		 WHERE from_id=$from_id AND type=$type
+		   AND coalesce(json_extract(data, '$.year'), 0)=$year
+		   AND (to_id=$to_id OR (to_id IS NULL AND json_extract(data, '$.label')=$label))`)
+	stmt.SetText("$from_id", rel.FromID)
+	stmt.SetText("$type", rel.Type)
+	year, _ := rel.Data["year"].(int)
+	stmt.SetInt64("$year", int64(year))
+	stmt.SetText("$to_id", toID)
+	stmt.SetText("$label", name)
+	n, err := sqlitex.ResultInt(stmt)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := sql.CreateRelation(conn, rel); err != nil {
+		return err
+	}
+	res.Relations++
+	if toID == "" {
+		res.Reviews++
+	}
+	return nil
+}
+
+// matchResource returns the ID of the only resource of the given type where
+// the given data property equals name, ignoring case, or "" if there are none
+// or more than one.
+func matchResource(conn *sqlite.Conn, t sirkulator.ResourceType, prop, name string) (string, error) {
+	stmt := conn.Prep(`
+		SELECT id FROM resource
+		 WHERE type=$type AND lower(json_extract(data, '$.' || $prop))=lower($name) AND archived_at IS NULL
+		 LIMIT 2`)
+	stmt.SetText("$type", t.String())
+	stmt.SetText("$prop", prop)
+	stmt.SetText("$name", name)
+	var ids []string
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return "", fmt.Errorf("etl.matchResource: %w", err)
+		}
+		if !hasRow {
+			break
+		}
+		ids = append(ids, stmt.GetText("id"))
+	}
+	if len(ids) != 1 {
+		return "", nil
+	}
+	return ids[0], nil
+}
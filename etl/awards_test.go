@@ -0,0 +1,66 @@
+package etl
+
+import (
+	"strings"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func TestImportAwards(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('c1', 'corporation', 'Norsk kritikerlag', '{"name": "Norsk kritikerlag"}', 0, 0),
+			       ('b1', 'publication', 'Petterson, Per - Ut og stjæle hester (2003)', '{"title": "Ut og stjæle hester"}', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	const data = `award,organizer,established,year,result,type,name
+Kritikerprisen,Norsk kritikerlag,1950,,,,
+kritikerprisen,,,2003,winner,publication,ut og stjæle hester
+Kritikerprisen,,,2003,nominee,person,"Fosse, Jon"
+`
+	res, err := ImportAwards(conn, strings.NewReader(data), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Awards) != 1 || res.Relations != 3 || res.Reviews != 1 {
+		t.Fatalf("ImportAwards = %d awards, %d relations, %d reviews; want 1, 3, 1", len(res.Awards), res.Relations, res.Reviews)
+	}
+
+	noms, err := sql.GetResourceAwards(conn, "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(noms) != 1 || noms[0].Award.ID != res.Awards[0].ID || noms[0].Year != 2003 || !noms[0].Won {
+		t.Errorf("GetResourceAwards(b1) = %+v; want Kritikerprisen won in 2003", noms)
+	}
+
+	// Importing again creates nothing new.
+	res, err = ImportAwards(conn, strings.NewReader(data), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Awards) != 0 || res.Relations != 0 {
+		t.Errorf("ImportAwards again = %d awards, %d relations; want none", len(res.Awards), res.Relations)
+	}
+
+	// The bundled seed data is well-formed.
+	if _, err := ImportAwards(conn, strings.NewReader(string(seedAwards)), "test"); err != nil {
+		t.Errorf("ImportAwards(seed data): %v", err)
+	}
+
+	if _, err := ImportAwards(conn, strings.NewReader("award,organizer,established,year,result,type,name\nX,,,2003,won,person,Y\n"), "test"); err == nil {
+		t.Error("ImportAwards with invalid result succeeded; want error")
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"github.com/knakk/sirkulator/isbn"
 	"github.com/knakk/sirkulator/marc"
 	"github.com/knakk/sirkulator/oai"
-	"github.com/knakk/sirkulator/search"
 	"github.com/knakk/sirkulator/sql"
 	"github.com/knakk/sirkulator/vocab"
 	"golang.org/x/image/draw"
@@ -30,14 +29,14 @@ import (
 
 type Ingestor struct {
 	db     *sqlitex.Pool
-	idx    *search.Index
 	idFunc func() string
 
 	// Options
-	UseRemote     bool // if true, use external sources in additin to local
-	ImageDownload bool // if true, download images found in imported records
-	ImageAsync    bool // if true, download images after IngestISBN has returned
-	ImageWidth    int  // scale to this with, calculating width to preserve aspect ratio
+	UseRemote     bool   // if true, use external sources in additin to local
+	ImageDownload bool   // if true, download images found in imported records
+	ImageAsync    bool   // if true, download images after IngestISBN has returned
+	ImageWidth    int    // scale to this with, calculating width to preserve aspect ratio
+	Actor         string // recorded as author of persisted resources in their edit history
 	//ImageWebp  bool // convert to webp before storing
 }
 
@@ -52,10 +51,9 @@ type FileFetch struct {
 	URL        string
 }
 
-func NewIngestor(db *sqlitex.Pool, idx *search.Index) *Ingestor {
+func NewIngestor(db *sqlitex.Pool) *Ingestor {
 	return &Ingestor{
 		db:         db,
-		idx:        idx,
 		ImageWidth: 300,                 // default resize width
 		idFunc:     sirkulator.GetNewID, // can be overwritten with a deterministic function in tests
 	}
@@ -382,13 +380,17 @@ func (ig *Ingestor) remoteRecord(ctx context.Context, idtype, id string) (Ingest
 // the given data is assumed to be valid at this point, as not to
 // trigger any SQL constraint errors when inserting into DB.
 //
-// CreatedAt/UpdatedAt timestamps on resources will be set here.
-func persistIngestion(conn *sqlite.Conn, data Ingestion) (err error) {
+// CreatedAt/UpdatedAt timestamps on resources will be set here, and the
+// creation is recorded in the edit history of each resource, with the given actor.
+func persistIngestion(conn *sqlite.Conn, data Ingestion, actor string) (err error) {
 	defer sqlitex.Save(conn)(&err)
 
 	now := time.Now()
 
 	for i, res := range data.Resources {
+		if err := sql.RecordEdit(conn, res, actor, now); err != nil {
+			return err
+		}
 		stmt := conn.Prep(`
 			INSERT INTO resource (type, id, label, data, created_at, updated_at)
 				VALUES ($type, $id, $label, $data, $now, $now)
@@ -433,6 +435,8 @@ func persistIngestion(conn *sqlite.Conn, data Ingestion) (err error) {
 	}
 
 	for _, rel := range data.Relations {
+		// Relations to a merged resource are redirected to the resource it was merged
+		// into. Relations to other archived or missing resources are queued for review.
 		stmt := conn.Prep(`
 			WITH v(from_id, type, data) AS (VALUES ($from_id, $type, $data))
 			INSERT INTO relation (from_id, to_id, type, data, queued_at)
@@ -443,7 +447,9 @@ func persistIngestion(conn *sqlite.Conn, data Ingestion) (err error) {
 				JSON_PATCH(v.data, IIF(res.id IS NULL,
 					IIF($to_id != '', JSON_OBJECT('label', $to_id), '{}'), '{}')) AS data,
 				IIF(res.id IS NULL, $queued_at, NULL) AS queued_at
-			FROM v LEFT JOIN resource res ON (res.id = $to_id AND res.archived_at IS NULL)
+			FROM v LEFT JOIN resource res ON (
+				res.id = COALESCE((SELECT to_id FROM resource_redirect WHERE from_id = $to_id), $to_id)
+				AND res.archived_at IS NULL)
 		`)
 		// TODO fix query, queued_at is NULL when rel.to_id IS NULL
 
@@ -535,6 +541,80 @@ func unmappedPublishedBy(id string, rs []sirkulator.Relation) string {
 	return ""
 }
 
+// matchSeries returns the ID of the local series with the same title as the
+// given series, and with publications by the same publisher as the publications
+// in it. Without a publisher to tell them apart, a single series with the same
+// title is a match. If there are local series with the same title, but none or
+// several of them match, the match is reported as ambiguous.
+func matchSeries(conn *sqlite.Conn, series sirkulator.Resource, data Ingestion) (id string, ambiguous bool, err error) {
+	var publisher string
+	for _, rel := range data.Relations {
+		if rel.ToID != series.ID || rel.Type != vocab.RelationInSeries.String() {
+			continue
+		}
+		if publisher, err = publisherName(conn, rel.FromID, data); err != nil || publisher != "" {
+			break
+		}
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("etl.matchSeries: %w", err)
+	}
+
+	var titleMatches, ids []string
+	fn := func(stmt *sqlite.Stmt) error {
+		titleMatches = append(titleMatches, stmt.ColumnText(0))
+		if stmt.ColumnInt(1) == 1 {
+			ids = append(ids, stmt.ColumnText(0))
+		}
+		return nil
+	}
+	const q = `
+		SELECT s.id, EXISTS (
+		       SELECT 1 FROM relation r
+		         JOIN relation pb ON (pb.from_id=r.from_id AND pb.type='published_by')
+		         JOIN resource p ON (p.id=pb.to_id)
+		        WHERE r.to_id=s.id AND r.type='in_series' AND lower(p.label)=lower(?2))
+		  FROM resource s
+		 WHERE s.type='series' AND lower(s.label)=lower(?1) AND s.archived_at IS NULL`
+	if err := sqlitex.Exec(conn, q, fn, series.Label, publisher); err != nil {
+		return "", false, fmt.Errorf("etl.matchSeries: %w", err)
+	}
+	if len(ids) == 1 {
+		return ids[0], false, nil
+	}
+	if publisher == "" && len(titleMatches) == 1 {
+		return titleMatches[0], false, nil
+	}
+	return "", len(titleMatches) > 0, nil
+}
+
+// publisherName returns the name of the publisher of the publication with the
+// given ID in the ingestion, or "" if it has none.
+func publisherName(conn *sqlite.Conn, pubID string, data Ingestion) (string, error) {
+	for _, rel := range data.Relations {
+		if rel.FromID != pubID || rel.Type != vocab.RelationPublishedBy.String() {
+			continue
+		}
+		if rel.ToID == "" {
+			label, _ := rel.Data["label"].(string)
+			return label, nil
+		}
+		for _, res := range data.Resources {
+			if res.ID == rel.ToID {
+				return res.Label, nil
+			}
+		}
+		stmt := conn.Prep("SELECT label FROM resource WHERE id=$id")
+		stmt.SetText("$id", rel.ToID)
+		label, err := sqlitex.ResultText(stmt)
+		if err != nil && !errors.Is(err, sqlitex.ErrNoResults) {
+			return "", err
+		}
+		return label, nil
+	}
+	return "", nil
+}
+
 // Ingest will merge the ingestion with locally matching resources before storing the data to db
 // and trigger indexing of documents.
 // if persist=false, nothing is persisted, and the returnet results represents a preview of which resources
@@ -637,6 +717,32 @@ func (ig *Ingestor) Ingest(ctx context.Context, data Ingestion, persist bool) ([
 			stmt.Reset()
 		}
 
+		if newResource && res.Type == sirkulator.TypeSeries {
+			// Series seldom have identifiers, so we match them on title and
+			// publisher, as different publishers can have series with the same title.
+			id, ambiguous, err := matchSeries(conn, res, data)
+			if err != nil {
+				return nil, err
+			}
+			if id != "" || ambiguous {
+				newResource = false
+				data.Resources = append(data.Resources[:i], data.Resources[i+1:]...)
+				for j, rel := range data.Relations {
+					if rel.ToID != res.ID {
+						continue
+					}
+					data.Relations[j].ToID = id
+					if ambiguous {
+						// Leave it to review which of the series it is, if any.
+						if rel.Data == nil {
+							data.Relations[j].Data = make(map[string]any)
+						}
+						data.Relations[j].Data["label"] = res.Label
+					}
+				}
+			}
+		}
+
 		if newResource {
 			// check if an authority record is present in local oai db, and use that instead
 			for _, link := range res.Links {
@@ -721,7 +827,7 @@ func (ig *Ingestor) Ingest(ctx context.Context, data Ingestion, persist bool) ([
 	}
 
 	// Store all resources and relations in a transaction:
-	if err := persistIngestion(conn, data); err != nil {
+	if err := persistIngestion(conn, data, ig.Actor); err != nil {
 		return nil, err // TODO annotate
 	}
 
@@ -733,29 +839,5 @@ func (ig *Ingestor) Ingest(ctx context.Context, data Ingestion, persist bool) ([
 		}
 	}
 
-	// Index documents asynchronously
-	go ig.indexResources(data.Resources)
-
 	return results, nil
 }
-
-func (ig *Ingestor) indexResources(res []sirkulator.Resource) {
-	if ig.idx == nil {
-		return
-	}
-
-	var docs []search.Document
-	for _, r := range res {
-		docs = append(docs, search.Document{
-			ID:        r.ID,
-			Type:      r.Type.String(),
-			Label:     r.Label,
-			Gain:      1.0,
-			CreatedAt: r.CreatedAt,
-			UpdatedAt: r.UpdatedAt,
-		})
-	}
-	if err := ig.idx.Store(docs...); err != nil {
-		log.Println(err) // TODO or not
-	}
-}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -274,7 +275,7 @@ func TestIngestISBN(t *testing.T) {
 	}
 
 	// Ingest by ISBN number
-	ing := NewIngestor(db, nil)
+	ing := NewIngestor(db)
 	ing.idFunc = testID()
 	ing.ImageDownload = true
 	if entry := ing.IngestISBN(context.Background(), "8202018560", true); entry.Error != "" {
@@ -409,6 +410,145 @@ func TestIngestISBN(t *testing.T) {
 	}
 }
 
+func TestPersistIngestionFollowsRedirect(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{}', 0, 0),
+			       ('p2', 'person', 'Hamsun, Knut', '{}', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sql.MergeResources(conn, "p2", "p1", "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := Ingestion{
+		Resources: []sirkulator.Resource{
+			{
+				Type:  sirkulator.TypePublication,
+				ID:    "b1",
+				Label: "Sult",
+				Data:  &sirkulator.Publication{Title: "Sult"},
+			},
+		},
+		Relations: []sirkulator.Relation{
+			{FromID: "b1", ToID: "p2", Type: "has_contributor", Data: map[string]any{"role": "aut"}},
+		},
+	}
+	if err := persistIngestion(conn, data, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	toID, err := sqlitex.ResultText(conn.Prep("SELECT to_id FROM relation WHERE from_id='b1'"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toID != "p1" {
+		t.Errorf("relation to merged resource got to_id=%q; want %q", toID, "p1")
+	}
+}
+
+func TestIngestMatchesSeriesByTitleAndPublisher(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	// Two series with the same title, by different publishers.
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('s1', 'series', 'Ulvegutten Tal', '{"title": "Ulvegutten Tal"}', 0, 0),
+			       ('s2', 'series', 'Ulvegutten Tal', '{"title": "Ulvegutten Tal"}', 0, 0),
+			       ('s3', 'series', 'Lanterne-bøkene', '{"title": "Lanterne-bøkene"}', 0, 0),
+			       ('c1', 'corporation', 'Cappelen', '{"name": "Cappelen"}', 0, 0),
+			       ('c2', 'corporation', 'Gyldendal', '{"name": "Gyldendal"}', 0, 0),
+			       ('x1', 'publication', 'Ulvegutten', '{"title": "Ulvegutten"}', 0, 0),
+			       ('x2', 'publication', 'Ulvegutten', '{"title": "Ulvegutten"}', 0, 0);
+		INSERT INTO relation (from_id, to_id, type)
+			VALUES ('x1', 's1', 'in_series'), ('x1', 'c1', 'published_by'),
+			       ('x2', 's2', 'in_series'), ('x2', 'c2', 'published_by');`); err != nil {
+		t.Fatal(err)
+	}
+
+	ingestion := func(pubID, seriesID, publisher string) Ingestion {
+		return Ingestion{
+			Resources: []sirkulator.Resource{
+				{
+					Type:  sirkulator.TypePublication,
+					ID:    pubID,
+					Label: "Den glemte byen",
+					Data:  sirkulator.Publication{Title: "Den glemte byen"},
+				},
+				{
+					Type:  sirkulator.TypeSeries,
+					ID:    seriesID,
+					Label: "ulvegutten tal",
+					Data:  sirkulator.Series{Title: "ulvegutten tal", Numbered: true},
+				},
+			},
+			Relations: []sirkulator.Relation{
+				{FromID: pubID, ToID: seriesID, Type: "in_series", Data: map[string]any{"number": 8}},
+				{FromID: pubID, Type: "published_by", Data: map[string]any{"label": publisher}},
+			},
+		}
+	}
+	ing := NewIngestor(db)
+	if _, err := ing.Ingest(context.Background(), ingestion("b1", "b2", "cappelen"), true); err != nil {
+		t.Fatal(err)
+	}
+	pubs, err := sql.GetSeriesPublications(conn, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pubs) != 2 || pubs[0].ID != "b1" || pubs[0].Number != "8" {
+		t.Errorf("GetSeriesPublications(s1) = %+v; want b1 as number 8", pubs)
+	}
+	if _, err := sql.GetResource(conn, sirkulator.TypeSeries, "b2"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("series matching existing was stored: %v", err)
+	}
+
+	// A publisher without a series of the title leaves it to review which
+	// of the series with the same title it is, if any.
+	if _, err := ing.Ingest(context.Background(), ingestion("b3", "b4", "Aschehoug"), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sql.GetResource(conn, sirkulator.TypeSeries, "b4"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("series with ambiguous match was stored: %v", err)
+	}
+	stmt := conn.Prep("SELECT count(*) FROM relation WHERE from_id='b3' AND type='in_series' AND to_id IS NULL AND json_extract(data, '$.label')='ulvegutten tal'")
+	if n, err := sqlitex.ResultInt(stmt); err != nil || n != 1 {
+		t.Errorf("got %d in_series reviews of b3, err=%v; want 1", n, err)
+	}
+
+	// Without a publisher, the only series with the same title is a match.
+	noPublisher := ingestion("b5", "b6", "")
+	noPublisher.Resources[1].Label = "Lanterne-bøkene"
+	noPublisher.Resources[1].Data = sirkulator.Series{Title: "Lanterne-bøkene"}
+	noPublisher.Relations = noPublisher.Relations[:1]
+	if _, err := ing.Ingest(context.Background(), noPublisher, true); err != nil {
+		t.Fatal(err)
+	}
+	pubs, err = sql.GetSeriesPublications(conn, "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pubs) != 1 || pubs[0].ID != "b5" {
+		t.Errorf("GetSeriesPublications(s3) = %+v; want b5", pubs)
+	}
+}
+
 const bibsys90294124 = `
 <marc:record format="MARC21" type="Authority" id="90294124" xmlns:marc="info:lc/xmlns/marcxchange-v1">
     <marc:leader>99999nz  a2299999n  4500</marc:leader>
@@ -496,7 +636,7 @@ func TestIngestPersonFromLocalOAI(t *testing.T) {
 	}
 
 	// Ingest by ISBN number
-	ing := NewIngestor(db, nil)
+	ing := NewIngestor(db)
 	ing.idFunc = testID()
 	if entry := ing.IngestISBN(context.Background(), "8202018560", true); entry.Error != "" {
 		t.Fatal(entry.Error)
@@ -549,7 +689,7 @@ func TestIngestRemote(t *testing.T) {
 			t.Error(err)
 		}
 	}()
-	ing := NewIngestor(db, nil)
+	ing := NewIngestor(db)
 	ing.idFunc = testID()
 	ing.UseRemote = true
 	if entry := ing.IngestISBN(context.Background(), "9788253043203", true); entry.Error != "" {
@@ -277,27 +277,18 @@ func ingestMarcRecord(source string, rec marc.Record, idFunc func() string) (Ing
 			label = fmt.Sprintf("%s (%s)", label, year)
 		}
 	}
-	// Publisher series
+	// Publisher series statements. The series are created after the agents,
+	// merged with the controlled series entries in 830.
+	var series []seriesEntry
 	for _, f := range rec.DataFieldsAt("490") {
-		if series := f.ValueAt("a"); series != "" {
-			p.Series = append(p.Series, series)
-			data := map[string]any{"label": series}
-			if num := f.ValueAt("v"); num != "" {
-				n, err := strconv.Atoi(num)
-				if err == nil {
-					data["number"] = n
-				}
-			}
-			if publisher != "" {
-				data["publisher"] = publisher
-			}
-			relations = append(relations, sirkulator.Relation{
-				FromID: pID,
-				Type:   "in_series",
-				Data:   data,
+		if title := f.ValueAt("a"); title != "" {
+			p.Series = append(p.Series, title)
+			series = addSeriesEntry(series, seriesEntry{
+				title:  cleanSeriesTitle(title),
+				issn:   f.ValueAt("x"),
+				number: cleanSeriesNumber(f.ValueAt("v")),
 			})
 		}
-
 	}
 	// Physical properties
 	if f, ok := rec.DataFieldAt("300"); ok {
@@ -458,6 +449,35 @@ classLoop:
 		}
 	}
 
+	// 830 Series added entry
+	for _, f := range rec.DataFieldsAt("830") {
+		if title := f.ValueAt("a"); title != "" {
+			series = addSeriesEntry(series, seriesEntry{
+				title:  cleanSeriesTitle(title),
+				issn:   f.ValueAt("x"),
+				number: cleanSeriesNumber(f.ValueAt("v")),
+			})
+		}
+	}
+	var seriesResources []sirkulator.Resource
+	for _, entry := range series {
+		s := entry.resource(idFunc)
+		seriesResources = append(seriesResources, s)
+		var data map[string]any
+		if entry.number != "" {
+			data = map[string]any{"number": entry.number}
+			if n, err := strconv.Atoi(entry.number); err == nil {
+				data["number"] = n
+			}
+		}
+		relations = append(relations, sirkulator.Relation{
+			FromID: pID,
+			ToID:   s.ID,
+			Type:   vocab.RelationInSeries.String(),
+			Data:   data,
+		})
+	}
+
 	res := sirkulator.Resource{
 		ID:    pID,
 		Type:  sirkulator.TypePublication,
@@ -499,6 +519,7 @@ classLoop:
 
 	ing.Resources = append(ing.Resources, res)
 	ing.Resources = append(ing.Resources, agents...)
+	ing.Resources = append(ing.Resources, seriesResources...)
 	ing.Relations = relations
 
 	var covers []FileFetch
@@ -649,6 +670,57 @@ scanning:
 	return res
 }
 
+// seriesEntry is a series statement or added entry of a MARC record.
+type seriesEntry struct {
+	title  string
+	issn   string
+	number string
+}
+
+// addSeriesEntry appends the entry to the given entries, unless there is
+// already one with the same title, in which case the missing ISSN and number
+// of the existing entry are filled in from the new one.
+func addSeriesEntry(entries []seriesEntry, entry seriesEntry) []seriesEntry {
+	for i, e := range entries {
+		if strings.EqualFold(e.title, entry.title) {
+			if e.issn == "" {
+				entries[i].issn = entry.issn
+			}
+			if e.number == "" {
+				entries[i].number = entry.number
+			}
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// resource creates a Series resource from the entry.
+func (e seriesEntry) resource(idFunc func() string) sirkulator.Resource {
+	res := sirkulator.Resource{
+		ID:    idFunc(),
+		Type:  sirkulator.TypeSeries,
+		Label: e.title,
+		Data: sirkulator.Series{
+			Title:    e.title,
+			ISSN:     e.issn,
+			Numbered: e.number != "",
+		},
+	}
+	if e.issn != "" {
+		res.Links = [][2]string{{"issn", e.issn}}
+	}
+	return res
+}
+
+func cleanSeriesTitle(s string) string {
+	return strings.TrimRight(s, " ;,.")
+}
+
+func cleanSeriesNumber(s string) string {
+	return strings.TrimRight(strings.TrimSpace(s), " ;,.")
+}
+
 func cleanTitle(s string) string {
 	s = strings.TrimSuffix(s, " :")
 	s = strings.TrimSuffix(s, " : ")
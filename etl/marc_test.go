@@ -1528,6 +1528,15 @@ func TestIngestOAIRecord(t *testing.T) {
 						YearRange: sirkulator.YearRange{From: "1976"},
 					},
 				},
+				{
+					Type:  sirkulator.TypeSeries,
+					ID:    "t4",
+					Label: "Søstrene Proxy blogger om verden",
+					Data: sirkulator.Series{
+						Title:    "Søstrene Proxy blogger om verden",
+						Numbered: true,
+					},
+				},
 			},
 			Relations: []sirkulator.Relation{
 				{
@@ -1535,16 +1544,6 @@ func TestIngestOAIRecord(t *testing.T) {
 					Type:   "published_by",
 					Data:   map[string]any{"label": "Gyldendal"},
 				},
-				{
-					FromID: "t1",
-					Type:   "in_series",
-					Data: map[string]any{
-						"label":     "Søstrene Proxy blogger om verden",
-						"number":    1,
-						"publisher": "Gyldendal",
-						//	"author": "Bjørn Ingvaldsen",
-					},
-				},
 				{
 					FromID: "t1",
 					ToID:   "t2",
@@ -1563,6 +1562,12 @@ func TestIngestOAIRecord(t *testing.T) {
 					Type:   "has_contributor",
 					Data:   map[string]any{"role": "bjd"},
 				},
+				{
+					FromID: "t1",
+					ToID:   "t4",
+					Type:   "in_series",
+					Data:   map[string]any{"number": 1},
+				},
 			},
 		}
 
@@ -1764,29 +1769,27 @@ func TestIngestOAIRecord(t *testing.T) {
 						YearRange: sirkulator.YearRange{From: "1991"},
 					},
 				},
-			},
-			Relations: []sirkulator.Relation{
 				{
-					FromID: "t1",
-					Type:   "published_by",
-					Data:   map[string]any{"label": "Cappelen Damm"},
+					Type:  sirkulator.TypeSeries,
+					ID:    "t4",
+					Label: "Ulvegutten Tal",
+					Data: sirkulator.Series{
+						Title:    "Ulvegutten Tal",
+						Numbered: true,
+					},
 				},
 				{
-					FromID: "t1",
-					Type:   "in_series",
-					Data: map[string]any{
-						"label":     "Ulvegutten Tal",
-						"number":    8,
-						"publisher": "Cappelen Damm",
-					},
+					Type:  sirkulator.TypeSeries,
+					ID:    "t5",
+					Label: "Min første leseløve",
+					Data:  sirkulator.Series{Title: "Min første leseløve"},
 				},
+			},
+			Relations: []sirkulator.Relation{
 				{
 					FromID: "t1",
-					Type:   "in_series",
-					Data: map[string]any{
-						"label":     "Min første leseløve",
-						"publisher": "Cappelen Damm",
-					},
+					Type:   "published_by",
+					Data:   map[string]any{"label": "Cappelen Damm"},
 				},
 				{
 					FromID: "t1",
@@ -1806,6 +1809,17 @@ func TestIngestOAIRecord(t *testing.T) {
 					Type:   "has_contributor",
 					Data:   map[string]any{"role": "ill"},
 				},
+				{
+					FromID: "t1",
+					ToID:   "t4",
+					Type:   "in_series",
+					Data:   map[string]any{"number": 8},
+				},
+				{
+					FromID: "t1",
+					ToID:   "t5",
+					Type:   "in_series",
+				},
 			},
 		}
 
@@ -17,6 +17,7 @@ require (
 	github.com/knakk/rdf v0.0.0-20190304171630-8521bf4c5042
 	github.com/knakk/sparql v0.0.0-20220326141742-15797a7da0ca
 	github.com/robfig/cron/v3 v3.0.1
+	golang.org/x/crypto v0.0.0-20220314234659-1baeb1ce4c0b
 	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 )
 
@@ -0,0 +1,676 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/sql"
+)
+
+// APIAuth is a middleware authenticating requests to the JSON API, either with
+// HTTP basic authentication, or with a session as set up by WithSession. When
+// using a session, state-changing requests must carry the CSRF token of the
+// session, as in the rest of the application. With basic authentication, only
+// requests which could be sent cross-site must carry it, see crossSiteSendable.
+// The user must have any of the given roles.
+func (s *Server) APIAuth(roles ...sirkulator.Role) func(next http.Handler) http.Handler {
+	csrf := CSRFProtect()
+	return func(next http.Handler) http.Handler {
+		withCSRF := csrf(next)
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			h := withCSRF
+			user, ok := r.Context().Value("user").(sirkulator.User)
+			if username, password, basic := r.BasicAuth(); basic {
+				conn := s.db.Get(r.Context())
+				if conn == nil {
+					renderError(w, r, sirkulator.ErrUnavailable)
+					return
+				}
+				u, err := sql.Authenticate(conn, username, password)
+				s.db.Put(conn)
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", `Basic realm="sirkulator"`)
+					renderError(w, r, err)
+					return
+				}
+				user, ok = u, true
+				r = r.WithContext(context.WithValue(r.Context(), "user", user))
+				if !crossSiteSendable(r) {
+					h = next
+				}
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="sirkulator"`)
+				renderError(w, r, sirkulator.Errorf(sirkulator.CodeUnauthorized, "authentication required"))
+				return
+			}
+			if len(roles) > 0 && !user.Can(roles...) {
+				renderError(w, r, sirkulator.Errorf(sirkulator.CodeForbidden, "missing required role"))
+				return
+			}
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// crossSiteSendable reports whether the request could have been sent by a form
+// or script on another site without a CORS preflight, ie. a POST with a simple
+// content type. Browsers resend cached basic credentials with such requests,
+// so they must carry a CSRF token even when authenticated by basic auth.
+// Requests with other methods or a JSON body trigger a preflight, which the
+// API doesn't allow.
+func crossSiteSendable(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json", "application/merge-patch+json":
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields.
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "invalid JSON: %w", err)
+	}
+	return nil
+}
+
+// mergePatch applies the JSON merge patch (RFC 7386) to the target document.
+func mergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var t, p any
+	dec := json.NewDecoder(bytes.NewReader(target))
+	dec.UseNumber()
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	dec = json.NewDecoder(bytes.NewReader(patch))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return nil, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid JSON: %w", err)
+	}
+	return json.Marshal(applyPatch(t, p))
+}
+
+func applyPatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = applyPatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// apiResource is the JSON representation of a sirkulator.Resource.
+type apiResource struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Label      string          `json:"label"`
+	Data       json.RawMessage `json:"data"`
+	Links      []apiLink       `json:"links"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	ArchivedAt *time.Time      `json:"archived_at,omitempty"`
+}
+
+type apiLink struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func toAPILinks(links [][2]string) []apiLink {
+	res := make([]apiLink, 0, len(links))
+	for _, l := range links {
+		res = append(res, apiLink{Type: l[0], ID: l[1]})
+	}
+	return res
+}
+
+// apiRelation is the JSON representation of a sirkulator.Relation.
+// A relation without to_id is queued for review.
+type apiRelation struct {
+	ID     int64          `json:"id"`
+	FromID string         `json:"from_id"`
+	ToID   string         `json:"to_id,omitempty"`
+	Type   string         `json:"type"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+func toAPIRelation(rel sirkulator.Relation) apiRelation {
+	return apiRelation(rel)
+}
+
+// etag returns the entity tag of the given resource, which changes whenever
+// the resource is updated. The update time is only stored with second precision,
+// so the tag is a hash of it along with the label, data, links and archival
+// of the resource, to tell apart updates within the same second.
+func etag(res sirkulator.Resource) string {
+	data, _ := json.Marshal(res.Data)
+	links, _ := json.Marshal(res.Links)
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%s\x00", res.UpdatedAt.Unix(), res.ArchivedAt.Unix(), res.Label)
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write(links)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// relationETag returns the entity tag of the given relation. Relations have
+// no update time, so the tag is a hash of everything that can be changed.
+func relationETag(rel sirkulator.Relation) string {
+	data, _ := json.Marshal(rel.Data)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", rel.FromID, rel.ToID, rel.Type)
+	h.Write(data)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// checkIfMatch verifies that the request's If-Match header matches the entity
+// tag of the current version, to prevent overwriting concurrent changes.
+func checkIfMatch(r *http.Request, current string) error {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		return sirkulator.Errorf(sirkulator.CodePrecondition, "If-Match header is required")
+	}
+	if match != "*" && match != current {
+		return sirkulator.Errorf(sirkulator.CodePrecondition, "resource has been updated by someone else")
+	}
+	return nil
+}
+
+// atomically runs fn, which checks a precondition before writing, in a single
+// transaction, so that no other connection can write in between. If two
+// connections read and then write concurrently, SQLite aborts one of them,
+// which is reported as a failed precondition.
+func atomically(conn *sqlite.Conn, fn func() error) (err error) {
+	defer func() {
+		var serr sqlite.Error
+		if !errors.As(err, &serr) {
+			return
+		}
+		switch serr.Code {
+		case sqlite.SQLITE_LOCKED, sqlite.SQLITE_LOCKED_SHAREDCACHE, sqlite.SQLITE_BUSY_SNAPSHOT:
+			err = sirkulator.Errorf(sirkulator.CodePrecondition, "resource has been updated by someone else")
+		}
+	}()
+	defer sqlitex.Save(conn)(&err)
+	return fn()
+}
+
+// apiGetResource loads the resource identified by the type and id URL parameters.
+func apiGetResource(r *http.Request, conn *sqlite.Conn) (sirkulator.Resource, error) {
+	t := sirkulator.ParseResourceType(chi.URLParam(r, "type"))
+	if t.NewData() == nil {
+		return sirkulator.Resource{}, sirkulator.Errorf(sirkulator.CodeNotFound, "unsupported resource type: %q", chi.URLParam(r, "type"))
+	}
+	return sql.GetResource(conn, t, chi.URLParam(r, "id"))
+}
+
+func writeAPIResource(w http.ResponseWriter, r *http.Request, res sirkulator.Resource) {
+	data, err := json.Marshal(res.Data)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	v := apiResource{
+		ID:        res.ID,
+		Type:      res.Type.String(),
+		Label:     res.Label,
+		Data:      data,
+		Links:     toAPILinks(res.Links),
+		CreatedAt: res.CreatedAt,
+		UpdatedAt: res.UpdatedAt,
+	}
+	if !res.ArchivedAt.IsZero() {
+		v.ArchivedAt = &res.ArchivedAt
+	}
+	w.Header().Set("ETag", etag(res))
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) apiResource(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	res, err := apiGetResource(r, conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	writeAPIResource(w, r, res)
+}
+
+// apiResourceBody is the label and data of a resource, as given in PUT requests,
+// or as the target of the JSON merge patch in PATCH requests.
+type apiResourceBody struct {
+	Label string          `json:"label"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// resourceUpdate returns the label and data of the resource when updated with
+// the request body, which is a PATCH merge patch or a PUT replacement.
+func resourceUpdate(r *http.Request, res sirkulator.Resource, reqBody json.RawMessage) (label string, data any, err error) {
+	var body apiResourceBody
+	if r.Method == http.MethodPatch {
+		current, err := json.Marshal(res.Data)
+		if err != nil {
+			return "", nil, err
+		}
+		current, err = json.Marshal(apiResourceBody{Label: res.Label, Data: current})
+		if err != nil {
+			return "", nil, err
+		}
+		patched, err := mergePatch(current, reqBody)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := json.Unmarshal(patched, &body); err != nil {
+			return "", nil, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid patch: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(reqBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&body); err != nil {
+			return "", nil, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid JSON: %w", err)
+		}
+	}
+
+	data = res.Type.NewData()
+	dec := json.NewDecoder(bytes.NewReader(body.Data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(data); err != nil {
+		return "", nil, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid data: %w", err)
+	}
+	if p, ok := data.(sirkulator.Persistable); ok && !p.Valid() {
+		// Same checks as when the resource is edited in the UI.
+		return "", nil, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid data: missing or malformed %s properties", res.Type)
+	}
+	label = body.Label
+	if l, ok := data.(interface{ Label() string }); ok && label == "" && res.Type != sirkulator.TypePublication {
+		// The label of publications cannot be synthesized from its data alone.
+		label = l.Label()
+	}
+	if label == "" {
+		return "", nil, sirkulator.Errorf(sirkulator.CodeInvalid, "label is required")
+	}
+	return label, data, nil
+}
+
+// apiSaveResource handles both PUT, which replaces the label and data of the resource,
+// and PATCH, which applies a JSON merge patch to the label and data.
+func (s *Server) apiSaveResource(w http.ResponseWriter, r *http.Request) {
+	var reqBody json.RawMessage
+	if err := decodeJSON(r, &reqBody); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	var res sirkulator.Resource
+	err := atomically(conn, func() (err error) {
+		if res, err = apiGetResource(r, conn); err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, etag(res)); err != nil {
+			return err
+		}
+		label, data, err := resourceUpdate(r, res, reqBody)
+		if err != nil {
+			return err
+		}
+		return sql.UpdateResource(conn, sirkulator.Resource{ID: res.ID, Type: res.Type, Data: data}, label, currentUser(r).Username)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	res, err = sql.GetResource(conn, res.Type, res.ID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	writeAPIResource(w, r, res)
+}
+
+// apiDeleteResource archives the resource.
+func (s *Server) apiDeleteResource(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	err := atomically(conn, func() error {
+		res, err := apiGetResource(r, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, etag(res)); err != nil {
+			return err
+		}
+		return sql.ArchiveResource(conn, res.ID, currentUser(r).Username)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) apiResourceRelations(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	res, err := apiGetResource(r, conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	rels, err := sql.GetRelations(conn, res.ID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	v := make([]apiRelation, 0, len(rels))
+	for _, rel := range rels {
+		v = append(v, toAPIRelation(rel))
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) apiResourceLinks(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	res, err := apiGetResource(r, conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPILinks(res.Links))
+}
+
+// apiSetLinks replaces all the links of the resource. Like other changes to
+// the resource, it requires the If-Match header.
+func (s *Server) apiSetLinks(w http.ResponseWriter, r *http.Request) {
+	var links []apiLink
+	if err := decodeJSON(r, &links); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	set := make([][2]string, 0, len(links))
+	for _, l := range links {
+		set = append(set, [2]string{l.Type, l.ID})
+	}
+	var res sirkulator.Resource
+	err := atomically(conn, func() (err error) {
+		if res, err = apiGetResource(r, conn); err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, etag(res)); err != nil {
+			return err
+		}
+		return sql.UpdateLinks(conn, res.ID, set, currentUser(r).Username)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if res, err = sql.GetResource(conn, res.Type, res.ID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(res))
+	writeJSON(w, http.StatusOK, toAPILinks(res.Links))
+}
+
+func (s *Server) apiDeleteLink(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	err := atomically(conn, func() error {
+		res, err := apiGetResource(r, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, etag(res)); err != nil {
+			return err
+		}
+		return sql.DeleteLink(conn, res.ID, chi.URLParam(r, "linktype"), chi.URLParam(r, "linkid"), currentUser(r).Username)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func relationID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, sirkulator.Errorf(sirkulator.CodeNotFound, "relation not found")
+	}
+	return id, nil
+}
+
+func (s *Server) apiRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	rel, err := sql.GetRelation(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", relationETag(rel))
+	writeJSON(w, http.StatusOK, toAPIRelation(rel))
+}
+
+func (s *Server) apiCreateRelation(w http.ResponseWriter, r *http.Request) {
+	var v apiRelation
+	if err := decodeJSON(r, &v); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	rel, err := sql.CreateRelation(conn, sirkulator.Relation(v))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", relationETag(rel))
+	writeJSON(w, http.StatusCreated, toAPIRelation(rel))
+}
+
+// relationUpdate returns the relation when updated with the request body,
+// which is a PATCH merge patch or a PUT replacement.
+func relationUpdate(r *http.Request, rel sirkulator.Relation, reqBody json.RawMessage) (sirkulator.Relation, error) {
+	var v apiRelation
+	if r.Method == http.MethodPatch {
+		current, err := json.Marshal(toAPIRelation(rel))
+		if err != nil {
+			return rel, err
+		}
+		patched, err := mergePatch(current, reqBody)
+		if err != nil {
+			return rel, err
+		}
+		if err := json.Unmarshal(patched, &v); err != nil {
+			return rel, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid patch: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(reqBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&v); err != nil {
+			return rel, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid JSON: %w", err)
+		}
+	}
+	v.ID = rel.ID
+	return sirkulator.Relation(v), nil
+}
+
+// apiSaveRelation handles both PUT, which replaces the relation, and PATCH,
+// which applies a JSON merge patch to it. Both require the If-Match header.
+func (s *Server) apiSaveRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	var reqBody json.RawMessage
+	if err := decodeJSON(r, &reqBody); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	err = atomically(conn, func() error {
+		rel, err := sql.GetRelation(conn, id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, relationETag(rel)); err != nil {
+			return err
+		}
+		if rel, err = relationUpdate(r, rel, reqBody); err != nil {
+			return err
+		}
+		return sql.UpdateRelation(conn, rel)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	rel, err := sql.GetRelation(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", relationETag(rel))
+	writeJSON(w, http.StatusOK, toAPIRelation(rel))
+}
+
+// apiDeleteRelation deletes the relation. It requires the If-Match header.
+func (s *Server) apiDeleteRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	err = atomically(conn, func() error {
+		rel, err := sql.GetRelation(conn, id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(r, relationETag(rel)); err != nil {
+			return err
+		}
+		return sql.DeleteRelation(conn, id)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -87,4 +87,13 @@ ready(function() {
     document.body.addEventListener('htmx:afterSwap', function() {
         initSearchSelect();
     });
+
+    // Error responses are not swapped in by htmx by default, but the server
+    // renders them as fragments meant to be shown in place.
+    document.body.addEventListener('htmx:beforeSwap', function(event) {
+        if (event.detail.isError && event.detail.xhr.getResponseHeader('X-Error')) {
+            event.detail.shouldSwap = true;
+            event.detail.isError = false;
+        }
+    });
 });
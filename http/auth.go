@@ -0,0 +1,304 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+)
+
+const sessionCookie = "sirkulator_session"
+
+// WithSession is a middleware which looks up the session identified by the
+// session cookie, and if valid, stores the session and its user in the request context.
+func (s *Server) WithSession() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			c, err := r.Cookie(sessionCookie)
+			if err != nil || c.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			conn := s.db.Get(r.Context())
+			if conn == nil {
+				renderError(w, r, sirkulator.ErrUnavailable)
+				return
+			}
+			session, user, err := sql.GetSession(conn, c.Value, time.Now())
+			s.db.Put(conn)
+			if errors.Is(err, sirkulator.ErrNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			} else if err != nil {
+				renderError(w, r, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "session", session)
+			ctx = context.WithValue(ctx, "user", user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireRole is a middleware which only lets through requests from users
+// with any of the given roles, or any logged in user if no roles are given.
+// Requests without a valid session are redirected to the login page.
+func RequireRole(roles ...sirkulator.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value("user").(sirkulator.User)
+			if !ok {
+				loginURL := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+				if r.Header.Get("HX-Request") != "" {
+					// Let htmx redirect the whole page, not swap in the login page.
+					w.Header().Set("HX-Redirect", loginURL)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				http.Redirect(w, r, loginURL, http.StatusSeeOther)
+				return
+			}
+			if len(roles) > 0 && !user.Can(roles...) {
+				renderError(w, r, sirkulator.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CSRFProtect is a middleware which rejects state-changing requests not
+// carrying the CSRF token of the session, either in the X-CSRF-Token header,
+// which htmx sends with every request, or in the csrf_token form field.
+func CSRFProtect() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			session, _ := r.Context().Value("session").(sirkulator.Session)
+			token := r.Header.Get("X-CSRF-Token")
+			if token == "" {
+				token = r.PostFormValue("csrf_token")
+			}
+			if session.CSRFToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+				renderError(w, r, sirkulator.Errorf(sirkulator.CodeForbidden, "invalid CSRF token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// currentUser returns the logged in user making the request.
+func currentUser(r *http.Request) sirkulator.User {
+	user, _ := r.Context().Value("user").(sirkulator.User)
+	return user
+}
+
+// safeRedirect returns the given path if it is local to the server, otherwise "/".
+func safeRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+func (s *Server) pageLogin(w http.ResponseWriter, r *http.Request) {
+	tmpl := html.LoginTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+		Next: safeRedirect(r.URL.Query().Get("next")),
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+// login authenticates the user and starts a new session. It is not CSRF
+// protected, as there is no session yet; the session cookie is SameSite=Lax,
+// which protects the other handlers from cross-site requests as well.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	next := safeRedirect(r.PostForm.Get("next"))
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	user, err := sql.Authenticate(conn, strings.TrimSpace(r.PostForm.Get("username")), r.PostForm.Get("password"))
+	if err != nil {
+		var sErr *sirkulator.Error
+		if !errors.As(err, &sErr) {
+			renderError(w, r, err)
+			return
+		}
+		l, _ := r.Context().Value("localizer").(localizer.Localizer)
+		tmpl := html.LoginTemplate{
+			Page: html.Page{
+				Lang: s.Lang,
+				Path: r.URL.Path,
+			},
+			Next:  next,
+			Error: l.Translate("Wrong username or password"),
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	session, err := sql.CreateSession(conn, user.ID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if session, ok := r.Context().Value("session").(sirkulator.Session); ok {
+		conn := s.db.Get(r.Context())
+		if conn == nil {
+			renderError(w, r, sirkulator.ErrUnavailable)
+			return
+		}
+		defer s.db.Put(conn)
+
+		if err := sql.DeleteSession(conn, session.Token); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if r.Header.Get("HX-Request") != "" {
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (s *Server) viewUsers(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	users, err := sql.GetUsers(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewUsers{
+		Users: users,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) saveUser(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	var user sirkulator.User
+	if id := r.PostForm.Get("id"); id != "" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			renderError(w, r, sirkulator.ErrInvalid)
+			return
+		}
+		user.ID = n
+	}
+	user.Username = strings.TrimSpace(r.PostForm.Get("username"))
+	user.Name = strings.TrimSpace(r.PostForm.Get("name"))
+	user.Role = sirkulator.Role(r.PostForm.Get("role"))
+
+	if currentUser(r).ID == user.ID && user.Role != sirkulator.RoleAdmin {
+		flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "you cannot remove your own admin role"))
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if _, err := sql.SaveUser(conn, user, r.PostForm.Get("password")); err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			renderError(w, r, sirkulator.ErrNotFound)
+			return
+		}
+		flashMessage(w, r, "", err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "usersChanged")
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+	if currentUser(r).ID == id {
+		renderError(w, r, sirkulator.Errorf(sirkulator.CodeInvalid, "cannot delete own user"))
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.DeleteUser(conn, id); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "usersChanged")
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+func (s *Server) pageLiteraryAward(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypeLiteraryAward, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.LiteraryAwardTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+		Resource: res,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+// viewAwardNominations shows the organizers of a literary award, and its
+// nominees and winners year by year.
+func (s *Server) viewAwardNominations(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	var tmpl html.ViewAwardNominations
+	rels, err := sql.GetRelations(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	for _, rel := range rels {
+		if rel.FromID != id || rel.ToID == "" || rel.Type != vocab.RelationOrganizedBy.String() {
+			continue
+		}
+		org, err := sql.GetSimpleResource(conn, rel.ToID)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		tmpl.Organizers = append(tmpl.Organizers, org)
+	}
+	tmpl.Nominations, err = sql.GetAwardNominations(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) saveLiteraryAward(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypeLiteraryAward, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	oldA := res.Data.(*sirkulator.LiteraryAward)
+	data, valid := oldA.Validate(r.PostForm)
+	newA := data.(sirkulator.LiteraryAward)
+	form := html.LiteraryAwardForm{
+		Award:     &newA,
+		UpdatedAt: res.UpdatedAt.Unix(),
+		Localizer: l,
+	}
+
+	if !valid {
+		form.SaveMessage = l.Translate("Validation failed. Check input fields.")
+		form.Render(r.Context(), w)
+		return
+	}
+	if cmp.Diff(oldA, &newA) == "" {
+		form.SaveMessage = l.Translate("No changes.")
+		form.Render(r.Context(), w)
+		return
+	}
+
+	// Check that resource hasn't been updated by some other process
+	updatedAt, err := strconv.ParseInt(r.PostForm.Get("updated_at"), 10, 0)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	if updatedAt != res.UpdatedAt.Unix() {
+		var b bytes.Buffer
+		io.WriteString(&b, l.Translate("Not saved. Resource has been updated by some else."))
+		io.WriteString(&b, `<a href="/metadata/literary_award/`+id+`" target="_blank">`)
+		io.WriteString(&b, l.Translate("Open this page in a new tab"))
+		io.WriteString(&b, "</a> ")
+		io.WriteString(&b, l.Translate("to verify and redo your changes."))
+		form.UpdatedAt = updatedAt
+		form.SaveMessage = b.String()
+		form.Render(r.Context(), w)
+		return
+	}
+
+	if err := sql.UpdateResource(conn, sirkulator.Resource{
+		ID:   id,
+		Type: sirkulator.TypeLiteraryAward,
+		Data: newA,
+	}, newA.Label(), currentUser(r).Username); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	res, err = sql.GetResource(conn, sirkulator.TypeLiteraryAward, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	form.Award = res.Data.(*sirkulator.LiteraryAward)
+	form.UpdatedAt = res.UpdatedAt.Unix()
+	form.SaveMessage = l.Translate("OK, saved.")
+	form.Render(r.Context(), w)
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/calendar"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/sql"
+)
+
+var rxpTimeOfDay = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+func (s *Server) viewCalendar(w http.ResponseWriter, r *http.Request) {
+	branch := strings.TrimSpace(r.URL.Query().Get("branch"))
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	branches, err := sql.GetBranches(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewCalendar{
+		Branches: branches,
+	}
+	if branch != "" {
+		cal, err := sql.GetCalendar(conn, branch)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		tmpl.Calendar = cal
+		tmpl.Holidays = calendar.NorwegianHolidays(time.Now().Year())
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) saveOpeningHours(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	branch := strings.TrimSpace(r.PostForm.Get("branch"))
+	if branch == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	var hours []calendar.Hours
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		opens := r.PostForm.Get("opens_" + strconv.Itoa(int(day)))
+		closes := r.PostForm.Get("closes_" + strconv.Itoa(int(day)))
+		if opens == "" && closes == "" {
+			continue // closed
+		}
+		if !rxpTimeOfDay.MatchString(opens) || !rxpTimeOfDay.MatchString(closes) || opens >= closes {
+			flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "invalid opening hours on %s: %s–%s", day, opens, closes))
+			return
+		}
+		hours = append(hours, calendar.Hours{Weekday: day, Opens: opens, Closes: closes})
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.SaveOpeningHours(conn, branch, hours); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "calendarChanged")
+}
+
+func (s *Server) saveCalendarException(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	branch := strings.TrimSpace(r.PostForm.Get("branch"))
+	e := calendar.Exception{
+		Date:   r.PostForm.Get("date"),
+		Opens:  r.PostForm.Get("opens"),
+		Closes: r.PostForm.Get("closes"),
+		Note:   strings.TrimSpace(r.PostForm.Get("note")),
+	}
+	if branch == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	if _, err := time.Parse(calendar.DateFormat, e.Date); err != nil {
+		flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "invalid date: %q", e.Date))
+		return
+	}
+	if !e.Closed() && (!rxpTimeOfDay.MatchString(e.Opens) || !rxpTimeOfDay.MatchString(e.Closes) || e.Opens >= e.Closes) {
+		flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "invalid opening hours: %s–%s", e.Opens, e.Closes))
+		return
+	}
+	if e.Closed() {
+		e.Closes = ""
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.SaveCalendarException(conn, branch, e); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "calendarChanged")
+}
+
+func (s *Server) deleteCalendarException(w http.ResponseWriter, r *http.Request) {
+	branch := r.URL.Query().Get("branch")
+	date := r.URL.Query().Get("date")
+	if branch == "" || date == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.DeleteCalendarException(conn, branch, date); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "calendarChanged")
+}
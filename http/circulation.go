@@ -0,0 +1,419 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func (s *Server) pageCirculation(w http.ResponseWriter, r *http.Request) {
+	tmpl := html.CircTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	card := strings.TrimSpace(r.PostForm.Get("card_number"))
+	barcode := strings.TrimSpace(r.PostForm.Get("barcode"))
+	if card == "" || barcode == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+	loan, err := sql.Checkout(conn, barcode, card)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "loansChanged, holdsChanged")
+	flashMessage(w, r, barcode+": "+l.Translate("Checked out, due %s", loan.DueAt.Format("2006-01-02")), nil)
+}
+
+func (s *Server) checkin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	barcode := strings.TrimSpace(r.PostForm.Get("barcode"))
+	if barcode == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+	_, hold, err := sql.Checkin(conn, barcode)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	msg := barcode + ": " + l.Translate("Checked in")
+	if hold != nil {
+		w.Header().Add("HX-Trigger", "loansChanged, holdsChanged")
+		msg += ". " + l.Translate("Set aside for %s (%s), pickup at %s", hold.PatronName, hold.PatronCard, hold.PickupBranch)
+	} else {
+		w.Header().Add("HX-Trigger", "loansChanged")
+	}
+	flashMessage(w, r, msg, nil)
+}
+
+func (s *Server) renew(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	barcode := strings.TrimSpace(r.PostForm.Get("barcode"))
+	if barcode == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+	loan, err := sql.Renew(conn, barcode)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "loansChanged")
+	flashMessage(w, r, barcode+": "+l.Translate("Renewed, due %s", loan.DueAt.Format("2006-01-02")), nil)
+}
+
+func (s *Server) viewRecentLoans(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 20 // default size
+	}
+
+	loans, err := sql.GetRecentLoans(conn, limit)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewLoans{
+		Loans:      loans,
+		ShowPatron: true,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) createPatron(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	p := sirkulator.Patron{
+		CardNumber: strings.TrimSpace(r.PostForm.Get("card_number")),
+		Name:       strings.TrimSpace(r.PostForm.Get("name")),
+		Email:      strings.TrimSpace(r.PostForm.Get("email")),
+		Phone:      strings.TrimSpace(r.PostForm.Get("phone")),
+		Category:   strings.TrimSpace(r.PostForm.Get("category")),
+		Branch:     strings.TrimSpace(r.PostForm.Get("branch")),
+		Lang:       r.PostForm.Get("lang"),
+	}
+	if p.CardNumber == "" || p.Name == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	p, err := sql.CreatePatron(conn, p)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Redirect", "/circulation/patron/"+strconv.FormatInt(p.ID, 10))
+}
+
+func (s *Server) pagePatron(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	patron, err := sql.GetPatron(conn, id)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.PatronTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+		Patron: patron,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) viewPatronLoans(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	loans, err := sql.GetPatronLoans(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewLoans{
+		Loans:     loans,
+		ShowRenew: true,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) placeHold(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	publicationID := strings.TrimSpace(r.PostForm.Get("publication_id"))
+	card := strings.TrimSpace(r.PostForm.Get("card_number"))
+	branch := strings.TrimSpace(r.PostForm.Get("pickup_branch"))
+	if publicationID == "" || card == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+	hold, err := sql.PlaceHold(conn, publicationID, card, branch, time.Now().Add(sirkulator.DefaultHoldExpiry))
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "holdsChanged")
+	flashMessage(w, r, card+": "+l.Translate("Hold placed, position %d in queue", hold.Position), nil)
+}
+
+func (s *Server) cancelHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.CancelHold(conn, id); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "holdsChanged")
+}
+
+func (s *Server) viewReadyHolds(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	holds, err := sql.GetReadyHolds(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewHolds{
+		Holds:           holds,
+		ShowPatron:      true,
+		ShowPublication: true,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) viewPatronHolds(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	holds, err := sql.GetPatronHolds(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewHolds{
+		Holds:           holds,
+		ShowPublication: true,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) viewPatronLedger(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	entries, err := sql.GetLedger(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewLedger{
+		PatronID: id,
+		Entries:  entries,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) addLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	typ := sirkulator.LedgerType(r.PostForm.Get("type"))
+	if typ != sirkulator.LedgerFee && typ != sirkulator.LedgerPayment && typ != sirkulator.LedgerWaiver {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	amount, err := sirkulator.ParseAmount(r.PostForm.Get("amount"))
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if _, err := sql.GetPatron(conn, id); errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	_, err = sql.AddLedgerEntry(conn, sirkulator.LedgerEntry{
+		PatronID: id,
+		Type:     typ,
+		Amount:   amount,
+		Note:     strings.TrimSpace(r.PostForm.Get("note")),
+	})
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "ledgerChanged")
+}
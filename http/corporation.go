@@ -1,12 +1,17 @@
 package http
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/go-cmp/cmp"
 	"github.com/knakk/sirkulator"
 	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
 	"github.com/knakk/sirkulator/sql"
 )
 
@@ -14,28 +19,31 @@ func (s *Server) pageCorporation(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypeCorporation, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	contrib, err := sql.GetAgentContributions(conn, id, "year", false)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	relations, err := sql.GetRelationsAsObject(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -50,3 +58,108 @@ func (s *Server) pageCorporation(w http.ResponseWriter, r *http.Request) {
 	}
 	tmpl.Render(r.Context(), w)
 }
+
+func (s *Server) saveCorporation(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	// Load resource
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypeCorporation, id)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	// Validate input
+	oldC := res.Data.(*sirkulator.Corporation)
+	data, valid := oldC.Validate(r.PostForm)
+	newC := data.(sirkulator.Corporation)
+	changed := cmp.Diff(oldC, &newC) != ""
+
+	if !valid {
+		tmpl := html.CorporationForm{
+			Corporation: &newC,
+			UpdatedAt:   res.UpdatedAt.Unix(),
+			Localizer:   l,
+			SaveMessage: l.Translate("Validation failed. Check input fields."),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	if !changed {
+		// No changes to resource, no point in saving to DB
+		tmpl := html.CorporationForm{
+			Corporation: &newC,
+			UpdatedAt:   res.UpdatedAt.Unix(),
+			Localizer:   l,
+			SaveMessage: l.Translate("No changes."),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	// Check that resource hasn't been updated by some other process
+	updatedAtStr := r.PostForm.Get("updated_at")
+	updatedAt, err := strconv.ParseInt(updatedAtStr, 10, 0)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	if updatedAt != res.UpdatedAt.Unix() {
+		var b bytes.Buffer
+		io.WriteString(&b, l.Translate("Not saved. Resource has been updated by some else."))
+		io.WriteString(&b, `<a href="/metadata/corporation/`+id+`" target="_blank">`)
+		io.WriteString(&b, l.Translate("Open this page in a new tab"))
+		io.WriteString(&b, "</a> ")
+		io.WriteString(&b, l.Translate("to verify and redo your changes."))
+		tmpl := html.CorporationForm{
+			Corporation: &newC,
+			UpdatedAt:   updatedAt,
+			Localizer:   l,
+			SaveMessage: b.String(),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	// Validation passed, save resource
+	if err := sql.UpdateResource(conn, sirkulator.Resource{
+		ID:   id,
+		Type: sirkulator.TypeCorporation,
+		Data: newC,
+	}, newC.Label(), currentUser(r).Username); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	// Load resource from DB again
+	res, err = sql.GetResource(conn, sirkulator.TypeCorporation, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.CorporationForm{
+		Corporation: res.Data.(*sirkulator.Corporation),
+		UpdatedAt:   res.UpdatedAt.Unix(),
+		Localizer:   l,
+		SaveMessage: l.Translate("OK, saved."),
+	}
+	tmpl.Render(r.Context(), w)
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+)
+
+// newResources holds the empty data of the resource types which can be
+// created manually.
+var newResources = map[sirkulator.ResourceType]sirkulator.Persistable{
+	sirkulator.TypePublication:   sirkulator.Publication{},
+	sirkulator.TypePublisher:     sirkulator.Publisher{},
+	sirkulator.TypePerson:        sirkulator.Person{},
+	sirkulator.TypeCorporation:   sirkulator.Corporation{},
+	sirkulator.TypeSeries:        sirkulator.Series{},
+	sirkulator.TypeLiteraryAward: sirkulator.LiteraryAward{},
+	sirkulator.TypeDewey:         sirkulator.Dewey{},
+}
+
+// creatableTypes returns the resource types which can be created manually.
+func creatableTypes() []sirkulator.ResourceType {
+	var res []sirkulator.ResourceType
+	for _, t := range sirkulator.AllResourceTypes() {
+		if _, ok := newResources[t]; ok {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// newResourceData returns the empty data of a new resource of the type
+// given by the type URL parameter.
+func newResourceData(r *http.Request) (sirkulator.ResourceType, sirkulator.Persistable, error) {
+	t := sirkulator.ParseResourceType(chi.URLParam(r, "type"))
+	data, ok := newResources[t]
+	if !ok {
+		return t, nil, sirkulator.Errorf(sirkulator.CodeNotFound, "resources of type %q cannot be created", chi.URLParam(r, "type"))
+	}
+	return t, data, nil
+}
+
+// resourceForm returns the form for editing the given resource data.
+func resourceForm(data sirkulator.Persistable, l localizer.Localizer, msg string) interface {
+	Render(context.Context, io.Writer)
+} {
+	switch d := data.(type) {
+	case sirkulator.Person:
+		return &html.PersonForm{Person: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.Publication:
+		return &html.PublicationForm{Publication: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.Corporation:
+		return &html.CorporationForm{Corporation: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.Publisher:
+		return &html.PublisherForm{Publisher: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.Series:
+		return &html.SeriesForm{Series: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.LiteraryAward:
+		return &html.LiteraryAwardForm{Award: &d, Localizer: l, SaveMessage: msg}
+	case sirkulator.Dewey:
+		return &html.DeweyForm{Dewey: &d, Localizer: l, SaveMessage: msg}
+	default:
+		panic(fmt.Sprintf("resourceForm: no form for %T", data))
+	}
+}
+
+func (s *Server) pageNewResource(w http.ResponseWriter, r *http.Request) {
+	t, data, err := newResourceData(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	tmpl := html.NewResourceTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+		Type: t,
+		Form: resourceForm(data, l, ""),
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) createResource(w http.ResponseWriter, r *http.Request) {
+	t, data, err := newResourceData(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	data, valid := data.Validate(r.PostForm)
+	if !valid {
+		resourceForm(data, l, l.Translate("Validation failed. Check input fields.")).Render(r.Context(), w)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	res := sirkulator.Resource{
+		ID:    sirkulator.GetNewID(),
+		Type:  t,
+		Label: data.Label(),
+		Data:  data,
+	}
+	if d, ok := data.(sirkulator.Dewey); ok {
+		// Dewey numbers are identified by the number itself, as when imported.
+		res.ID = d.Number
+	}
+	if err := sql.CreateResource(conn, res, currentUser(r).Username); errors.Is(err, sirkulator.ErrConflict) && t == sirkulator.TypeDewey {
+		resourceForm(data, l, l.Translate("Not saved. The Dewey number already exists.")).Render(r.Context(), w)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Set("HX-Redirect", "/metadata/"+t.String()+"/"+res.ID)
+}
@@ -15,52 +15,55 @@ func (s *Server) pageDewey(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypeDewey, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	parents, err := sql.GetDeweyParents(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	children, err := sql.GetDeweyChildren(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	parts, err := sql.GetDeweyParts(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	numPartsOf, err := sql.GetDeweyPartsOfCount(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	pubCount, err := sql.GetDeweyPublicationsCount(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	pubSubCount, err := sql.GetDeweySubPublicationsCount(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -83,7 +86,7 @@ func (s *Server) pageDewey(w http.ResponseWriter, r *http.Request) {
 func (s *Server) viewDeweyPartsOf(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -102,7 +105,7 @@ func (s *Server) viewDeweyPartsOf(w http.ResponseWriter, r *http.Request) {
 
 	partsOf, hasMore, err := sql.GetDeweyPartsOf(conn, id, limit, offset)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -118,7 +121,7 @@ func (s *Server) viewDeweyPartsOf(w http.ResponseWriter, r *http.Request) {
 func (s *Server) viewDeweyPublications(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -145,7 +148,7 @@ func (s *Server) viewDeweyPublications(w http.ResponseWriter, r *http.Request) {
 
 	publications, hasMore, err := sql.GetDeweyPublications(conn, id, params)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
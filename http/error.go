@@ -0,0 +1,154 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+)
+
+// errorStatus returns the HTTP status code corresponding to the given error code.
+func errorStatus(code string) int {
+	switch code {
+	case sirkulator.CodeConflict:
+		return http.StatusConflict
+	case sirkulator.CodeInvalid:
+		return http.StatusBadRequest
+	case sirkulator.CodeNotFound:
+		return http.StatusNotFound
+	case sirkulator.CodeUnauthorized:
+		return http.StatusUnauthorized
+	case sirkulator.CodeForbidden:
+		return http.StatusForbidden
+	case sirkulator.CodePrecondition:
+		return http.StatusPreconditionFailed
+	case sirkulator.CodeTimeout:
+		return http.StatusGatewayTimeout
+	case sirkulator.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorText returns a generic, localized description of the given error code,
+// used for errors without a message.
+func errorText(l localizer.Localizer, code string) string {
+	switch code {
+	case sirkulator.CodeConflict:
+		return l.Translate("The request conflicts with the current state")
+	case sirkulator.CodeInvalid:
+		return l.Translate("Invalid request")
+	case sirkulator.CodeNotFound:
+		return l.Translate("Not found")
+	case sirkulator.CodeUnauthorized:
+		return l.Translate("You must log in")
+	case sirkulator.CodeForbidden:
+		return l.Translate("You do not have access to this")
+	case sirkulator.CodePrecondition:
+		return l.Translate("The resource has been changed by someone else")
+	case sirkulator.CodeTimeout:
+		return l.Translate("The operation took too long to complete")
+	case sirkulator.CodeUnavailable:
+		return l.Translate("The service is temporarily unavailable, please try again")
+	default:
+		return l.Translate("Something went wrong")
+	}
+}
+
+// wantsJSON reports whether the error response to the request should be JSON,
+// which is the case for the API, and for clients asking for it.
+func wantsJSON(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") || strings.Contains(accept, "application/problem+json")
+}
+
+// renderError responds to the request with the given error, with a status code
+// according to its error code. API clients get a problem+json document (RFC 7807),
+// htmx requests a localized HTML fragment, and other requests plain text.
+//
+// Internal errors are logged with the request ID, which is also shown to the
+// user, so that it can be reported and found in the logs. Details of internal
+// errors are never shown to the user.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	code := sirkulator.ErrorCode(err)
+	status := errorStatus(code)
+	reqID := middleware.GetReqID(r.Context())
+	if status >= 500 && code != sirkulator.CodeUnavailable {
+		log.Printf("[%s] %s %s: %v", reqID, r.Method, r.URL.Path, err)
+	} else {
+		reqID = "" // only relevant when the error is logged
+	}
+
+	l, ok := r.Context().Value("localizer").(localizer.Localizer)
+	if !ok {
+		l = localizer.GetFromAcceptLang(r.Header.Get("Accept-Language"))
+	}
+	msg := sirkulator.ErrorMessage(err)
+	if msg == "" {
+		msg = errorText(l, code)
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	switch {
+	case wantsJSON(r):
+		problem := map[string]any{
+			"type":   "about:blank",
+			"title":  http.StatusText(status),
+			"status": status,
+			"detail": msg,
+			"code":   code,
+		}
+		if reqID != "" {
+			problem["request_id"] = reqID
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		writeJSON(w, status, problem)
+	case r.Header.Get("HX-Request") != "":
+		// The client is configured to swap in error responses marked with this header.
+		w.Header().Set("X-Error", code)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		tmpl := html.ViewError{Message: msg}
+		if reqID != "" {
+			tmpl.Reference = l.Translate("reference: %s", reqID)
+		}
+		tmpl.Render(r.Context(), w)
+	default:
+		if reqID != "" {
+			msg += " (" + l.Translate("reference: %s", reqID) + ")"
+		}
+		http.Error(w, msg, status)
+	}
+}
+
+// flashMessage renders a short message in response to an action, ex: a
+// circulation transaction or a saved form, to be swapped into the page by htmx.
+// Errors of type sirkulator.Error are considered user errors, and their message
+// is displayed, while all other errors are rendered by renderError.
+func flashMessage(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if err != nil {
+		code := sirkulator.ErrorCode(err)
+		if errorStatus(code) >= 500 {
+			renderError(w, r, err)
+			return
+		}
+		msg = sirkulator.ErrorMessage(err)
+		if msg == "" {
+			l, _ := r.Context().Value("localizer").(localizer.Localizer)
+			msg = errorText(l, code)
+		}
+	}
+	tmpl := html.ViewMessage{
+		Message: msg,
+		Error:   err != nil,
+	}
+	tmpl.Render(r.Context(), w)
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func (s *Server) viewResourceHistory(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	id := chi.URLParam(r, "id")
+	edits, err := sql.GetResourceHistory(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewHistory{
+		ResourceID: id,
+		Edits:      edits,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) revertResource(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	editID, err := strconv.ParseInt(chi.URLParam(r, "edit"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	_, err = sql.RevertResource(conn, id, editID, currentUser(r).Username)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	// Reload the page, as the resource form and label has changed.
+	w.Header().Set("HX-Refresh", "true")
+}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/knakk/sirkulator"
 	"github.com/knakk/sirkulator/http/html"
 	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/search"
+	"github.com/knakk/sirkulator/sql"
 )
 
 func (s *Server) pageMaintenance(w http.ResponseWriter, r *http.Request) {
@@ -32,7 +34,7 @@ func (s *Server) viewJobRuns(w http.ResponseWriter, r *http.Request) {
 
 	runs, err := s.runner.JobRuns(r.Context(), limit)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -47,7 +49,7 @@ func (s *Server) viewSchedules(w http.ResponseWriter, r *http.Request) {
 
 	schedules, err := s.runner.Schedules(r.Context())
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -61,26 +63,25 @@ func (s *Server) viewSchedules(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) scheduleJob(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
 	jobName := r.PostForm.Get("job_name")
 	cronExpr := r.PostForm.Get("cron_expr")
 	if jobName == "" || cronExpr == "" {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
 	_, err := s.runner.ParseCron(cronExpr)
 	if err != nil {
-		log.Println(err) // TODO investigate what kind of errors
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		renderError(w, r, sirkulator.Errorf(sirkulator.CodeInvalid, "invalid cron expression: %w", err))
 		return
 	}
 
 	if err := s.runner.ScheduleJob(r.Context(), jobName, cronExpr); err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 	}
 	w.Header().Add("HX-Trigger", "jobScheduled")
 }
@@ -89,15 +90,15 @@ func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request) {
 	idparam := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idparam)
 	if err != nil {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
 
 	if err := s.runner.DeleteSchedule(r.Context(), int64(id)); err != nil {
 		if errors.Is(err, sirkulator.ErrNotFound) {
-			http.NotFound(w, r)
+			renderError(w, r, sirkulator.ErrNotFound)
 		} else {
-			ServerError(w, err)
+			renderError(w, r, err)
 		}
 		return
 	}
@@ -108,15 +109,15 @@ func (s *Server) viewJobRunOutput(w http.ResponseWriter, r *http.Request) {
 	idparam := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idparam)
 	if err != nil {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
 	run, err := s.runner.GetJobRun(r.Context(), int64(id))
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 	w.Header().Add("Content-Type", "text/plain")
@@ -125,19 +126,173 @@ func (s *Server) viewJobRunOutput(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) runJob(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
 	jobName := r.PostForm.Get("job_name")
 	if jobName == "" {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
 	if _, _, err := s.runner.RunJob(context.Background(), jobName); err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 	w.Header().Add("HX-Trigger", "runTriggered")
 }
+
+func (s *Server) viewLoanRules(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	policy, err := sql.GetLoanPolicy(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewLoanRules{
+		Rules: policy,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) saveLoanRule(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	var rule sirkulator.LoanRule
+	if id := r.PostForm.Get("id"); id != "" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			renderError(w, r, sirkulator.ErrInvalid)
+			return
+		}
+		rule.ID = n
+	}
+	rule.PatronCategory = strings.TrimSpace(r.PostForm.Get("patron_category"))
+	rule.ItemType = strings.TrimSpace(r.PostForm.Get("item_type"))
+	rule.Branch = strings.TrimSpace(r.PostForm.Get("branch"))
+	rule.HoldsAllowed = r.PostForm.Get("holds_allowed") == "true"
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{
+		{"loan_days", &rule.LoanDays},
+		{"max_renewals", &rule.MaxRenewals},
+		{"max_loans", &rule.MaxLoans},
+	} {
+		n, err := strconv.Atoi(r.PostForm.Get(f.name))
+		if err != nil || n < 0 {
+			renderError(w, r, sirkulator.ErrInvalid)
+			return
+		}
+		*f.dst = n
+	}
+	for _, f := range []struct {
+		name string
+		dst  *int64
+	}{
+		{"fine_per_day", &rule.FinePerDay},
+		{"max_fine", &rule.MaxFine},
+		{"max_balance", &rule.MaxBalance},
+	} {
+		n, err := sirkulator.ParseAmount(r.PostForm.Get(f.name))
+		if err != nil {
+			flashMessage(w, r, "", err)
+			return
+		}
+		*f.dst = n
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if _, err := sql.SaveLoanRule(conn, rule); err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			renderError(w, r, sirkulator.ErrNotFound)
+			return
+		}
+		flashMessage(w, r, "", err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "loanRulesChanged")
+}
+
+func (s *Server) deleteLoanRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.DeleteLoanRule(conn, id); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	w.Header().Add("HX-Trigger", "loanRulesChanged")
+}
+
+func (s *Server) viewNotices(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 50 // default size
+	}
+
+	notices, err := sql.GetNotices(conn, limit)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewNotices{
+		Notices: notices,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) viewIndexQueue(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	status, err := search.GetQueueStatus(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewIndexQueue{
+		Status: status,
+	}
+	tmpl.Render(r.Context(), w)
+}
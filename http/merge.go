@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"github.com/go-chi/chi/v5"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func (s *Server) viewMerge(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	id := chi.URLParam(r, "id")
+	candidates, err := sql.GetMergeCandidates(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewMerge{
+		ResourceID: id,
+		Candidates: candidates,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) mergeResource(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	target := strings.TrimSpace(r.PostForm.Get("target"))
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	res, err := sql.MergeResources(conn, id, target, currentUser(r).Username)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/metadata/"+res.Type.String()+"/"+res.ID)
+}
+
+// redirectMerged responds with a permanent redirect to the resource which the
+// resource with the given ID has been merged into, and reports whether it did so.
+func redirectMerged(w http.ResponseWriter, r *http.Request, conn *sqlite.Conn, id string) bool {
+	to, err := sql.GetRedirect(conn, id)
+	if err != nil {
+		return false
+	}
+	http.Redirect(w, r, "/metadata/"+to.Type.String()+"/"+to.ID, http.StatusMovedPermanently)
+	return true
+}
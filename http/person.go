@@ -2,13 +2,10 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/go-cmp/cmp"
@@ -16,30 +13,12 @@ import (
 	"github.com/knakk/sirkulator/http/html"
 	"github.com/knakk/sirkulator/internal/localizer"
 	"github.com/knakk/sirkulator/sql"
-	"github.com/knakk/sirkulator/vocab"
 )
 
-func splitAndClean(s string) []string {
-	var res []string
-	for _, line := range strings.Split(s, "\n") {
-		if entry := strings.TrimSpace(line); entry != "" {
-			res = append(res, entry)
-		}
-	}
-	return res
-}
-
-func joinWith(ss []string, prefix string) []string {
-	for i, s := range ss {
-		ss[i] = prefix + s
-	}
-	return ss
-}
-
 func (s *Server) savePerson(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
@@ -47,49 +26,26 @@ func (s *Server) savePerson(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypePerson, id)
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	l, _ := r.Context().Value("localizer").(localizer.Localizer)
 
 	// Validate input
-	valid := true
-	changed := false
-	var newP sirkulator.Person
 	oldP := res.Data.(*sirkulator.Person)
-
-	newP.Name = strings.TrimSpace(r.PostFormValue("name"))
-	if newP.Name == "" {
-		valid = false
-	}
-	newP.Description = strings.TrimSpace(r.PostFormValue("description"))
-	newP.NameVariations = splitAndClean(r.PostFormValue("name_variations"))
-	newP.YearRange.From = json.Number(strings.TrimSpace(r.PostFormValue("year_range.from")))
-	newP.YearRange.To = json.Number(strings.TrimSpace(r.PostFormValue("year_range.to")))
-	if r.PostFormValue("year_range.approx") == "on" {
-		newP.YearRange.Approx = true
-	}
-	if !newP.YearRange.Valid() {
-		valid = false
-	}
-	newP.Gender = vocab.ParseGender(r.PostFormValue("gender"))
-	newP.Countries = joinWith(r.PostForm["countries"], "iso3166/")
-	newP.Nationalities = joinWith(r.PostForm["nationalities"], "bs/")
-
-	if diff := cmp.Diff(oldP, &newP); diff != "" {
-		changed = true
-		fmt.Println(diff) // TODO remove
-	}
+	data, valid := oldP.Validate(r.PostForm)
+	newP := data.(sirkulator.Person)
+	changed := cmp.Diff(oldP, &newP) != ""
 
 	if !valid {
 		//w.WriteHeader(http.StatusBadRequest)
@@ -119,7 +75,7 @@ func (s *Server) savePerson(w http.ResponseWriter, r *http.Request) {
 	updatedAtStr := r.PostForm.Get("updated_at")
 	updatedAt, err := strconv.ParseInt(updatedAtStr, 10, 0)
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 	if updatedAt != res.UpdatedAt.Unix() {
@@ -144,8 +100,8 @@ func (s *Server) savePerson(w http.ResponseWriter, r *http.Request) {
 		ID:   id,
 		Type: sirkulator.TypePerson,
 		Data: newP,
-	}, newP.Label()); err != nil {
-		ServerError(w, err)
+	}, newP.Label(), currentUser(r).Username); err != nil {
+		renderError(w, r, err)
 		return
 	}
 
@@ -153,10 +109,9 @@ func (s *Server) savePerson(w http.ResponseWriter, r *http.Request) {
 	// TODO or make sql.UpdateResource return updated resource?
 	res, err = sql.GetResource(conn, sirkulator.TypePerson, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
-	go s.indexResources([]sirkulator.Resource{res})
 
 	tmpl := html.PersonForm{
 		Person:      res.Data.(*sirkulator.Person),
@@ -171,28 +126,37 @@ func (s *Server) pagePerson(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypePerson, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	contrib, err := sql.GetAgentContributions(conn, id, "year", false)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	relations, err := sql.GetRelationsAsObject(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
+		return
+	}
+
+	awards, err := sql.GetResourceAwards(conn, id)
+	if err != nil {
+		renderError(w, r, err)
 		return
 	}
 
@@ -204,6 +168,7 @@ func (s *Server) pagePerson(w http.ResponseWriter, r *http.Request) {
 		Resource:      res,
 		Contributions: contrib,
 		Relations:     relations,
+		Awards:        awards,
 	}
 	tmpl.Render(r.Context(), w)
 }
@@ -211,13 +176,13 @@ func (s *Server) pagePerson(w http.ResponseWriter, r *http.Request) {
 // TODO also used by corporation - move out to agent.go?
 func (s *Server) viewContributions(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -230,7 +195,7 @@ func (s *Server) viewContributions(w http.ResponseWriter, r *http.Request) {
 
 	contrib, err := sql.GetAgentContributions(conn, id, sortBy, sortAsc)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -1,12 +1,17 @@
 package http
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/go-cmp/cmp"
 	"github.com/knakk/sirkulator"
 	"github.com/knakk/sirkulator/http/html"
 	"github.com/knakk/sirkulator/internal/localizer"
@@ -20,22 +25,31 @@ func (s *Server) pagePublication(w http.ResponseWriter, r *http.Request) {
 
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 
 	res, err := sql.GetResource(conn, sirkulator.TypePublication, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	img, _ := sql.GetImage(conn, id) // img is nil if err != nil TODO log err if err != ErrNotFound?
 
+	awards, err := sql.GetResourceAwards(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
 	tmpl := html.PublicationTemplate{
 		Page: html.Page{
 			Lang: s.Lang,
@@ -43,6 +57,119 @@ func (s *Server) pagePublication(w http.ResponseWriter, r *http.Request) {
 		},
 		Resource: res,
 		Image:    img,
+		Awards:   awards,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) savePublication(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	// Load resource
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypePublication, id)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
+		return
+	} else if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	// Validate input
+	oldP := res.Data.(*sirkulator.Publication)
+	data, valid := oldP.Validate(r.PostForm)
+	newP := data.(sirkulator.Publication)
+	changed := cmp.Diff(oldP, &newP) != ""
+
+	if !valid {
+		tmpl := html.PublicationForm{
+			Publication: &newP,
+			UpdatedAt:   res.UpdatedAt.Unix(),
+			Localizer:   l,
+			SaveMessage: l.Translate("Validation failed. Check input fields."),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	if !changed {
+		// No changes to resource, no point in saving to DB
+		tmpl := html.PublicationForm{
+			Publication: &newP,
+			UpdatedAt:   res.UpdatedAt.Unix(),
+			Localizer:   l,
+			SaveMessage: l.Translate("No changes."),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	// Check that resource hasn't been updated by some other process
+	updatedAtStr := r.PostForm.Get("updated_at")
+	updatedAt, err := strconv.ParseInt(updatedAtStr, 10, 0)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	if updatedAt != res.UpdatedAt.Unix() {
+		var b bytes.Buffer
+		io.WriteString(&b, l.Translate("Not saved. Resource has been updated by some else."))
+		io.WriteString(&b, `<a href="/metadata/publication/`+id+`" target="_blank">`)
+		io.WriteString(&b, l.Translate("Open this page in a new tab"))
+		io.WriteString(&b, "</a> ")
+		io.WriteString(&b, l.Translate("to verify and redo your changes."))
+		tmpl := html.PublicationForm{
+			Publication: &newP,
+			UpdatedAt:   updatedAt,
+			Localizer:   l,
+			SaveMessage: b.String(),
+		}
+		tmpl.Render(r.Context(), w)
+		return
+	}
+
+	// The main author is part of the label, but not of the publication data,
+	// so we keep whatever precedes the old label derived from the data.
+	label := newP.Label()
+	if prefix := strings.TrimSuffix(res.Label, oldP.Label()); prefix != res.Label {
+		label = prefix + label
+	}
+
+	// Validation passed, save resource
+	if err := sql.UpdateResource(conn, sirkulator.Resource{
+		ID:   id,
+		Type: sirkulator.TypePublication,
+		Data: newP,
+	}, label, currentUser(r).Username); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	// Load resource from DB again
+	res, err = sql.GetResource(conn, sirkulator.TypePublication, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.PublicationForm{
+		Publication: res.Data.(*sirkulator.Publication),
+		UpdatedAt:   res.UpdatedAt.Unix(),
+		Localizer:   l,
+		SaveMessage: l.Translate("OK, saved."),
 	}
 	tmpl.Render(r.Context(), w)
 }
@@ -53,14 +180,14 @@ func (s *Server) viewPublicationRelations(w http.ResponseWriter, r *http.Request
 
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 
 	rel, err := sql.GetPublicationRelations(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -109,3 +236,84 @@ func (s *Server) viewPublicationRelations(w http.ResponseWriter, r *http.Request
 	}
 	tmpl.Render(r.Context(), w)
 }
+
+func (s *Server) viewPublicationItems(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	items, err := sql.GetPublicationItems(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewItems{
+		PublicationID: id,
+		Items:         items,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) createItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	item := sirkulator.Item{
+		Barcode:       strings.TrimSpace(r.PostForm.Get("barcode")),
+		PublicationID: id,
+		Type:          strings.TrimSpace(r.PostForm.Get("type")),
+		Branch:        strings.TrimSpace(r.PostForm.Get("branch")),
+		Shelfmark:     strings.TrimSpace(r.PostForm.Get("shelfmark")),
+	}
+	if item.Barcode == "" {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if _, err := sql.CreateItem(conn, item); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "itemsChanged")
+}
+
+func (s *Server) viewPublicationHolds(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	holds, err := sql.GetPublicationHolds(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewHolds{
+		PublicationID: id,
+		Holds:         holds,
+		ShowPatron:    true,
+	}
+	tmpl.Render(r.Context(), w)
+}
@@ -2,13 +2,10 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/go-cmp/cmp"
@@ -22,22 +19,25 @@ func (s *Server) pagePublisher(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypePublisher, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	pubs, err := sql.GetPublisherPublications(conn, id, "year", false)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -55,7 +55,7 @@ func (s *Server) pagePublisher(w http.ResponseWriter, r *http.Request) {
 func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
@@ -63,47 +63,26 @@ func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
 	res, err := sql.GetResource(conn, sirkulator.TypePublisher, id)
 	if errors.Is(err, sirkulator.ErrNotFound) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	} else if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
 	l, _ := r.Context().Value("localizer").(localizer.Localizer)
 
 	// Validate input
-	valid := true
-	changed := false
-	var newP sirkulator.Publisher
 	oldP := res.Data.(*sirkulator.Publisher)
-
-	newP.Name = strings.TrimSpace(r.PostFormValue("name"))
-	if newP.Name == "" {
-		valid = false
-	}
-	newP.Notes = splitAndClean(r.PostFormValue("notes"))
-	newP.Description = strings.TrimSpace(r.PostFormValue("description"))
-	newP.NameVariations = splitAndClean(r.PostFormValue("name_variations"))
-	newP.YearRange.From = json.Number(strings.TrimSpace(r.PostFormValue("year_range.from")))
-	newP.YearRange.To = json.Number(strings.TrimSpace(r.PostFormValue("year_range.to")))
-	if r.PostFormValue("year_range.approx") == "on" {
-		newP.YearRange.Approx = true
-	}
-	if !newP.YearRange.Valid() {
-		valid = false
-	}
-
-	if diff := cmp.Diff(oldP, &newP); diff != "" {
-		changed = true
-		fmt.Println(diff) // TODO remove
-	}
+	data, valid := oldP.Validate(r.PostForm)
+	newP := data.(sirkulator.Publisher)
+	changed := cmp.Diff(oldP, &newP) != ""
 
 	if !valid {
 		//w.WriteHeader(http.StatusBadRequest)
@@ -133,7 +112,7 @@ func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 	updatedAtStr := r.PostForm.Get("updated_at")
 	updatedAt, err := strconv.ParseInt(updatedAtStr, 10, 0)
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 	if updatedAt != res.UpdatedAt.Unix() {
@@ -158,8 +137,8 @@ func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 		ID:   id,
 		Type: sirkulator.TypePublisher,
 		Data: newP,
-	}, newP.Label()); err != nil {
-		ServerError(w, err)
+	}, newP.Label(), currentUser(r).Username); err != nil {
+		renderError(w, r, err)
 		return
 	}
 
@@ -167,10 +146,9 @@ func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 	// TODO or make sql.UpdateResource return updated resource?
 	res, err = sql.GetResource(conn, sirkulator.TypePublisher, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
-	go s.indexResources([]sirkulator.Resource{res})
 
 	tmpl := html.PublisherForm{
 		Publisher:   res.Data.(*sirkulator.Publisher),
@@ -183,13 +161,13 @@ func (s *Server) savePublisher(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) viewPublisherPublications(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 	id := chi.URLParam(r, "id")
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -202,7 +180,7 @@ func (s *Server) viewPublisherPublications(w http.ResponseWriter, r *http.Reques
 
 	pubs, err := sql.GetPublisherPublications(conn, id, sortBy, sortAsc)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/marc"
+	"github.com/knakk/sirkulator/search"
+	"github.com/knakk/sirkulator/sql"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+// relationForm renders the form for editing the given relation, which is
+// new if its ID is 0.
+func relationForm(conn *sqlite.Conn, w http.ResponseWriter, r *http.Request, rel sirkulator.Relation) {
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	from, err := sql.GetSimpleResource(conn, rel.FromID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	var to sirkulator.SimpleResource
+	if rel.ToID != "" {
+		to, err = sql.GetSimpleResource(conn, rel.ToID)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	tmpl := html.ViewRelationForm{
+		Relation: rel,
+		To:       to,
+		Types:    vocab.RelationOptions(l.Lang, from.Type.String()),
+		Roles:    marc.RelatorOptions(l.Lang),
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) viewNewRelation(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	relationForm(conn, w, r, sirkulator.Relation{FromID: r.URL.Query().Get("from_id")})
+}
+
+func (s *Server) viewRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	rel, err := sql.GetRelation(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	relationForm(conn, w, r, rel)
+}
+
+// viewRelationCandidates searches for resources which the relation
+// being edited can point to, given its type and the type of the resource
+// it goes from.
+func (s *Server) viewRelationCandidates(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	relType := vocab.ParseRelation(r.URL.Query().Get("type"))
+	if q == "" || s.idx == nil {
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	from, err := sql.GetSimpleResource(conn, r.URL.Query().Get("from_id"))
+	s.db.Put(conn)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	const limit = 10
+	var hits []search.Hit
+	for _, t := range relType.Targets(from.Type.String()) {
+		res, err := s.idx.Suggest(r.Context(), q, t, limit)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		hits = append(hits, res...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	var tmpl html.ViewRelationCandidates
+	for _, h := range hits {
+		if h.ID == from.ID {
+			continue // a resource cannot relate to itself
+		}
+		tmpl.Candidates = append(tmpl.Candidates, sirkulator.SimpleResource{
+			Type:  sirkulator.ParseResourceType(h.Type),
+			ID:    h.ID,
+			Label: h.Label,
+		})
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+// relationFromForm sets the type, target, role and year of the given relation
+// from the posted form, keeping any other data of the relation.
+func relationFromForm(rel sirkulator.Relation, r *http.Request) (sirkulator.Relation, error) {
+	if err := r.ParseForm(); err != nil {
+		return rel, sirkulator.ErrInvalid
+	}
+	rel.Type = r.PostForm.Get("type")
+	if toID := r.PostForm.Get("to_id"); toID != "" {
+		rel.ToID = toID
+	}
+	if rel.ToID == "" {
+		return rel, sirkulator.Errorf(sirkulator.CodeInvalid, "no resource to connect to")
+	}
+
+	data := make(map[string]any, len(rel.Data)+1)
+	for k, v := range rel.Data {
+		data[k] = v
+	}
+	delete(data, "role")
+	role := r.PostForm.Get("role")
+	if rel.Type == string(vocab.RelationHasContributor) {
+		if role == "" {
+			return rel, sirkulator.Errorf(sirkulator.CodeInvalid, "contributor must have a role")
+		}
+		data["role"] = role
+	}
+	delete(data, "year")
+	if vocab.ParseRelation(rel.Type).Yearly() {
+		year, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get("year")))
+		if err != nil {
+			return rel, sirkulator.Errorf(sirkulator.CodeInvalid, "%s must have a year", rel.Type)
+		}
+		data["year"] = year
+	}
+	rel.Data = data
+	if len(rel.Data) == 0 {
+		rel.Data = nil
+	}
+	return rel, nil
+}
+
+func (s *Server) createRelation(w http.ResponseWriter, r *http.Request) {
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	rel, err := relationFromForm(sirkulator.Relation{}, r)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+	rel.FromID = r.PostForm.Get("from_id")
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if _, err := sql.CreateRelation(conn, rel); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "relationsChanged")
+	flashMessage(w, r, l.Translate("Relation saved."), nil)
+}
+
+func (s *Server) saveRelation(w http.ResponseWriter, r *http.Request) {
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	rel, err := sql.GetRelation(conn, id)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+	rel, err = relationFromForm(rel, r)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+	if err := sql.UpdateRelation(conn, rel); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "relationsChanged")
+	flashMessage(w, r, l.Translate("Relation saved."), nil)
+}
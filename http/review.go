@@ -0,0 +1,243 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/search"
+	"github.com/knakk/sirkulator/sql"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+// viewReview shows a review with candidate resources to connect it to:
+// those with an identifier matching the review data, followed by those
+// found searching the index for the review label.
+func (s *Server) viewReview(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	review, err := sql.GetReview(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	candidates, err := sql.GetReviewCandidates(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	targets := vocab.ParseRelation(review.Type).Targets(review.From.Type.String())
+
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		seen[c.ID] = true
+	}
+	if s.idx != nil && review.Label != "" {
+		const limit = 10
+		var hits []search.Hit
+		for _, t := range targets {
+			res, err := s.idx.Search(r.Context(), review.Label, search.QueryOptions{Type: t, Limit: limit})
+			if err != nil {
+				renderError(w, r, err)
+				return
+			}
+			hits = append(hits, res.Hits...)
+		}
+		sort.SliceStable(hits, func(i, j int) bool {
+			return hits[i].Score > hits[j].Score
+		})
+		for _, h := range hits {
+			if len(candidates) >= limit {
+				break
+			}
+			if seen[h.ID] || h.ID == review.FromID {
+				continue
+			}
+			seen[h.ID] = true
+			candidates = append(candidates, sirkulator.SimpleResource{
+				Type:  sirkulator.ParseResourceType(h.Type),
+				ID:    h.ID,
+				Label: h.Label,
+			})
+		}
+	}
+
+	var newTypes []sirkulator.ResourceType
+	for _, t := range targets {
+		rt := sirkulator.ParseResourceType(t)
+		if _, ok := newResources[rt]; ok {
+			newTypes = append(newTypes, rt)
+		}
+	}
+
+	tmpl := html.ViewReview{
+		Review:     review,
+		Candidates: candidates,
+		NewTypes:   newTypes,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+// resolveReview connects a review to the resource given by the posted to_id.
+func (s *Server) resolveReview(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.ResolveReview(conn, id, r.PostForm.Get("to_id"), currentUser(r).Username); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "reviewsChanged")
+	flashMessage(w, r, l.Translate("Review resolved."), nil)
+}
+
+// reviewLinks returns the identifiers in the data of a review, ex: the ISNI
+// of a contributor, as links, ordered by link type.
+func reviewLinks(data map[string]any) [][2]string {
+	var links [][2]string
+	for k, v := range data {
+		if id, ok := v.(string); ok && id != "" && vocab.IsIdentifier(k) {
+			links = append(links, [2]string{k, id})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i][0] < links[j][0] })
+	return links
+}
+
+// createFromReview resolves a review by creating a new resource of the posted
+// type, named by the review label and with the identifiers of the review as
+// links, and connecting the review to it.
+func (s *Server) createFromReview(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	t := sirkulator.ParseResourceType(r.PostForm.Get("type"))
+	data, ok := newResources[t]
+	if !ok {
+		flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "resources of type %q cannot be created", r.PostForm.Get("type")))
+		return
+	}
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	review, err := sql.GetReview(conn, id)
+	if err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+	// Agents are named, publications have a title.
+	data, valid := data.Validate(url.Values{
+		"name":  {review.Label},
+		"title": {review.Label},
+	})
+	if !valid {
+		flashMessage(w, r, "", sirkulator.Errorf(sirkulator.CodeInvalid, "cannot create %s from review", t))
+		return
+	}
+	res := sirkulator.Resource{
+		ID:    sirkulator.GetNewID(),
+		Type:  t,
+		Label: data.Label(),
+		Data:  data,
+		Links: reviewLinks(review.Data),
+	}
+	if err := sql.ResolveReviewWithResource(conn, id, res, currentUser(r).Username); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "reviewsChanged")
+	flashMessage(w, r, l.Translate("Review resolved."), nil)
+}
+
+func (s *Server) dismissReview(w http.ResponseWriter, r *http.Request) {
+	id, err := relationID(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	if err := sql.DismissReview(conn, id, currentUser(r).Username); err != nil {
+		flashMessage(w, r, "", err)
+		return
+	}
+
+	w.Header().Add("HX-Trigger", "reviewsChanged")
+	flashMessage(w, r, l.Translate("Review dismissed."), nil)
+}
+
+func (s *Server) viewReviewResolutions(w http.ResponseWriter, r *http.Request) {
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 20 // default size
+	}
+	res, err := sql.GetReviewResolutions(conn, limit)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.ViewReviewResolutions{
+		Resolutions: res,
+	}
+	tmpl.Render(r.Context(), w)
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/http/html"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func (s *Server) pageSeries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypeSeries, id)
+	if (errors.Is(err, sirkulator.ErrNotFound) || !res.ArchivedAt.IsZero()) && redirectMerged(w, r, conn, id) {
+		return
+	}
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	pubs, err := sql.GetSeriesPublications(conn, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	tmpl := html.SeriesTemplate{
+		Page: html.Page{
+			Lang: s.Lang,
+			Path: r.URL.Path,
+		},
+		Resource:     res,
+		Publications: pubs,
+	}
+	tmpl.Render(r.Context(), w)
+}
+
+func (s *Server) saveSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	conn := s.db.Get(r.Context())
+	if conn == nil {
+		renderError(w, r, sirkulator.ErrUnavailable)
+		return
+	}
+	defer s.db.Put(conn)
+	res, err := sql.GetResource(conn, sirkulator.TypeSeries, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	l, _ := r.Context().Value("localizer").(localizer.Localizer)
+
+	oldS := res.Data.(*sirkulator.Series)
+	data, valid := oldS.Validate(r.PostForm)
+	newS := data.(sirkulator.Series)
+	form := html.SeriesForm{
+		Series:    &newS,
+		UpdatedAt: res.UpdatedAt.Unix(),
+		Localizer: l,
+	}
+
+	if !valid {
+		form.SaveMessage = l.Translate("Validation failed. Check input fields.")
+		form.Render(r.Context(), w)
+		return
+	}
+	if cmp.Diff(oldS, &newS) == "" {
+		form.SaveMessage = l.Translate("No changes.")
+		form.Render(r.Context(), w)
+		return
+	}
+
+	// Check that resource hasn't been updated by some other process
+	updatedAt, err := strconv.ParseInt(r.PostForm.Get("updated_at"), 10, 0)
+	if err != nil {
+		renderError(w, r, sirkulator.ErrInvalid)
+		return
+	}
+	if updatedAt != res.UpdatedAt.Unix() {
+		var b bytes.Buffer
+		io.WriteString(&b, l.Translate("Not saved. Resource has been updated by some else."))
+		io.WriteString(&b, `<a href="/metadata/series/`+id+`" target="_blank">`)
+		io.WriteString(&b, l.Translate("Open this page in a new tab"))
+		io.WriteString(&b, "</a> ")
+		io.WriteString(&b, l.Translate("to verify and redo your changes."))
+		form.UpdatedAt = updatedAt
+		form.SaveMessage = b.String()
+		form.Render(r.Context(), w)
+		return
+	}
+
+	if err := sql.UpdateResource(conn, sirkulator.Resource{
+		ID:   id,
+		Type: sirkulator.TypeSeries,
+		Data: newS,
+	}, newS.Label(), currentUser(r).Username); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	res, err = sql.GetResource(conn, sirkulator.TypeSeries, id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	form.Series = res.Data.(*sirkulator.Series)
+	form.UpdatedAt = res.UpdatedAt.Unix()
+	form.SaveMessage = l.Translate("OK, saved.")
+	form.Render(r.Context(), w)
+}
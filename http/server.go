@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,11 +16,13 @@ import (
 	"crawshaw.io/sqlite"
 	"crawshaw.io/sqlite/sqlitex"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/knakk/sirkulator"
 	"github.com/knakk/sirkulator/dewey"
 	"github.com/knakk/sirkulator/etl"
 	"github.com/knakk/sirkulator/http/html"
 	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/notice"
 	"github.com/knakk/sirkulator/oai"
 	"github.com/knakk/sirkulator/runner"
 	"github.com/knakk/sirkulator/search"
@@ -47,7 +50,9 @@ type Server struct {
 }
 
 // NewServer returns a new Server with the given database and index and assets settings.
-func NewServer(ctx context.Context, assetsDir string, db *sqlitex.Pool, idx *search.Index) *Server {
+// Notices to patrons are delivered using the given transport. Any additional jobs,
+// which depend on configuration not known to the Server, are registered with the job runner.
+func NewServer(ctx context.Context, assetsDir string, db *sqlitex.Pool, idx *search.Index, notices notice.Transport, jobs ...runner.Job) *Server {
 	s := Server{
 		Addr:   "localhost:0", // assign random port as default, useful for testing
 		db:     db,
@@ -55,8 +60,8 @@ func NewServer(ctx context.Context, assetsDir string, db *sqlitex.Pool, idx *sea
 		runner: runner.New(db),
 	}
 
-	s.runner.Register(&dewey.ImportJob{DB: db, Idx: idx, BatchSize: 100})
-	s.runner.Register(&dewey.ImportJob{DB: db, Idx: idx, BatchSize: 100, Update: true})
+	s.runner.Register(&dewey.ImportJob{DB: db, BatchSize: 100})
+	s.runner.Register(&dewey.ImportJob{DB: db, BatchSize: 100, Update: true})
 	s.runner.Register(&search.Indexer{DB: db, Idx: idx, BatchSize: 100})
 	s.runner.Register(&oai.HarvestJob{
 		Harvester: oai.Harvester{
@@ -91,6 +96,10 @@ func NewServer(ctx context.Context, assetsDir string, db *sqlitex.Pool, idx *sea
 		JobName: "oai_harvest_nasjonalbibliografien",
 	})
 	s.runner.Register(&sql.JanitorJob{DB: db, Idx: idx})
+	s.runner.Register(&sql.ExpireHoldsJob{DB: db})
+	s.runner.Register(&sql.AccrueFinesJob{DB: db})
+	s.runner.Register(&notice.OverdueJob{DB: db})
+	s.runner.Register(&notice.SendJob{DB: db, Transport: notices})
 	s.runner.Register(&oai.HarvestPublishersJob{DB: db}) // number of records: ca 18k
 	s.runner.Register(&etl.HarvestNBLinksJob{DB: db})
 	s.runner.Register(&etl.HarvestSNLLinksJob{DB: db})
@@ -98,12 +107,20 @@ func NewServer(ctx context.Context, assetsDir string, db *sqlitex.Pool, idx *sea
 	s.runner.Register(&etl.UpdateSNLDescriptions{DB: db})
 	s.runner.Register(&etl.HarvestWikipediaLinks{DB: db})
 	s.runner.Register(&etl.HarvestWikipediaSummaries{DB: db})
+	for _, job := range jobs {
+		s.runner.Register(job)
+	}
 
 	if err := s.runner.Start(ctx); err != nil {
 		// TODO consider setting up separatly and pass to NewServer as an argument, like db and idx.
 		log.Printf("NewServer start runner %v\n", err)
 	}
 
+	if idx != nil {
+		// Keep the index up to date with changes to resources, as queued by the database.
+		go (&search.QueueIndexer{DB: db, Idx: idx}).Run(ctx)
+	}
+
 	s.srv = &http.Server{
 		BaseContext:       func(net.Listener) context.Context { return ctx },
 		ReadTimeout:       5 * time.Second,
@@ -156,6 +173,7 @@ func WithLocalizer() func(next http.Handler) http.Handler {
 
 func (s *Server) router(assetsDir string) chi.Router {
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 
 	// Static assets
 	fs := http.FS(embeddedFS)
@@ -166,67 +184,172 @@ func (s *Server) router(assetsDir string) chi.Router {
 
 	r.Get("/image/{id}", s.image)
 
+	// JSON API
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(s.WithSession())
+		r.Use(s.APIAuth(sirkulator.RoleCataloguer))
+
+		r.Post("/relation", s.apiCreateRelation)
+		r.Get("/relation/{id}", s.apiRelation)
+		r.Put("/relation/{id}", s.apiSaveRelation)
+		r.Patch("/relation/{id}", s.apiSaveRelation)
+		r.Delete("/relation/{id}", s.apiDeleteRelation)
+
+		r.Get("/{type}/{id}", s.apiResource)
+		r.Put("/{type}/{id}", s.apiSaveResource)
+		r.Patch("/{type}/{id}", s.apiSaveResource)
+		r.Delete("/{type}/{id}", s.apiDeleteResource)
+		r.Get("/{type}/{id}/relations", s.apiResourceRelations)
+		r.Get("/{type}/{id}/links", s.apiResourceLinks)
+		r.Put("/{type}/{id}/links", s.apiSetLinks)
+		r.Delete("/{type}/{id}/links/{linktype}/{linkid}", s.apiDeleteLink)
+	})
+
 	// Main UI routes
 	r.Route("/", func(r chi.Router) {
 		r.Use(WithLocalizer())
-
-		r.Get("/", s.pageHome)
-		r.Get("/circulation", s.pageCirculation)
-
-		r.Route("/metadata", func(r chi.Router) {
-			r.Get("/", s.pageMetadata)
-			r.Get("/reviews", s.viewReviews)
-			r.Post("/import", s.importResources) // s.tmplImportResponse ?
-			r.Post("/preview", s.importPreview)
-			r.Post("/search", s.searchResources)
-
-			// Shared between all resources
-			r.Get("/text/{id}", s.viewResourceTexts)
-			r.Delete("/relation/{id}", s.deleteRelation)
-
-			// Person
-			r.Route("/person", func(r chi.Router) {
-				r.Post("/{id}", s.savePerson)
-				r.Get("/{id}", s.pagePerson)
-				r.Post("/{id}/contributions", s.viewContributions)
-			})
-
-			// Corporation
-			r.Route("/corporation", func(r chi.Router) {
-				r.Get("/{id}", s.pageCorporation)
-				r.Post("/{id}/contributions", s.viewContributions)
-			})
-
-			// Publication
-			r.Route("/publication", func(r chi.Router) {
-				r.Get("/{id}", s.pagePublication)
-				r.Get("/{id}/relations", s.viewPublicationRelations)
+		r.Use(s.WithSession())
+
+		r.Get("/login", s.pageLogin)
+		r.Post("/login", s.login)
+
+		// All other routes require a logged in user
+		r.Group(func(r chi.Router) {
+			r.Use(RequireRole())
+			r.Use(CSRFProtect())
+
+			r.Get("/", s.pageHome)
+			r.Post("/logout", s.logout)
+
+			r.Route("/circulation", func(r chi.Router) {
+				r.Use(RequireRole(sirkulator.RoleCirculation))
+
+				r.Get("/", s.pageCirculation)
+				r.Get("/loans", s.viewRecentLoans)
+				r.Post("/checkout", s.checkout)
+				r.Post("/checkin", s.checkin)
+				r.Post("/renew", s.renew)
+
+				// Patron
+				r.Post("/patron", s.createPatron)
+				r.Get("/patron/{id}", s.pagePatron)
+				r.Get("/patron/{id}/loans", s.viewPatronLoans)
+				r.Get("/patron/{id}/holds", s.viewPatronHolds)
+				r.Get("/patron/{id}/ledger", s.viewPatronLedger)
+				r.Post("/patron/{id}/ledger", s.addLedgerEntry)
+
+				// Holds
+				r.Get("/holds", s.viewReadyHolds)
+				r.Post("/hold", s.placeHold)
+				r.Delete("/hold/{id}", s.cancelHold)
 			})
 
-			// Dewey
-			r.Route("/dewey", func(r chi.Router) {
-				r.Get("/{id}", s.pageDewey)
-				r.Get("/{id}/partsof", s.viewDeweyPartsOf)
-				r.Get("/{id}/publications", s.viewDeweyPublications)
+			r.Route("/metadata", func(r chi.Router) {
+				r.Use(RequireRole(sirkulator.RoleCataloguer))
+
+				r.Get("/", s.pageMetadata)
+				r.Get("/reviews", s.viewReviews)
+				r.Get("/reviews/resolved", s.viewReviewResolutions)
+				r.Get("/review/{id}", s.viewReview)
+				r.Post("/review/{id}/resolve", s.resolveReview)
+				r.Post("/review/{id}/create", s.createFromReview)
+				r.Post("/review/{id}/dismiss", s.dismissReview)
+				r.Post("/import", s.importResources) // s.tmplImportResponse ?
+				r.Post("/preview", s.importPreview)
+				r.Post("/search", s.searchResources)
+				r.Get("/suggest", s.suggestResources)
+				r.Get("/new/{type}", s.pageNewResource)
+				r.Post("/new/{type}", s.createResource)
+
+				// Shared between all resources
+				r.Get("/text/{id}", s.viewResourceTexts)
+				r.Get("/history/{id}", s.viewResourceHistory)
+				r.Post("/history/{id}/{edit}", s.revertResource)
+				r.Get("/merge/{id}", s.viewMerge)
+				r.Post("/merge/{id}", s.mergeResource)
+				r.Get("/relation/new", s.viewNewRelation)
+				r.Get("/relation/candidates", s.viewRelationCandidates)
+				r.Post("/relation", s.createRelation)
+				r.Get("/relation/{id}", s.viewRelation)
+				r.Post("/relation/{id}", s.saveRelation)
+				r.Delete("/relation/{id}", s.deleteRelation)
+
+				// Person
+				r.Route("/person", func(r chi.Router) {
+					r.Post("/{id}", s.savePerson)
+					r.Get("/{id}", s.pagePerson)
+					r.Post("/{id}/contributions", s.viewContributions)
+				})
+
+				// Corporation
+				r.Route("/corporation", func(r chi.Router) {
+					r.Get("/{id}", s.pageCorporation)
+					r.Post("/{id}", s.saveCorporation)
+					r.Post("/{id}/contributions", s.viewContributions)
+				})
+
+				// Publication
+				r.Route("/publication", func(r chi.Router) {
+					r.Get("/{id}", s.pagePublication)
+					r.Post("/{id}", s.savePublication)
+					r.Get("/{id}/relations", s.viewPublicationRelations)
+					r.Get("/{id}/items", s.viewPublicationItems)
+					r.Post("/{id}/items", s.createItem)
+					r.Get("/{id}/holds", s.viewPublicationHolds)
+				})
+
+				// Dewey
+				r.Route("/dewey", func(r chi.Router) {
+					r.Get("/{id}", s.pageDewey)
+					r.Get("/{id}/partsof", s.viewDeweyPartsOf)
+					r.Get("/{id}/publications", s.viewDeweyPublications)
+				})
+
+				// Publisher
+				r.Route("/publisher", func(r chi.Router) {
+					r.Get("/{id}", s.pagePublisher)
+					r.Post("/{id}", s.savePublisher)
+					r.Post("/{id}/publications", s.viewPublisherPublications)
+				})
+
+				// Series
+				r.Route("/series", func(r chi.Router) {
+					r.Get("/{id}", s.pageSeries)
+					r.Post("/{id}", s.saveSeries)
+				})
+
+				// Literary award
+				r.Route("/literary_award", func(r chi.Router) {
+					r.Get("/{id}", s.pageLiteraryAward)
+					r.Post("/{id}", s.saveLiteraryAward)
+					r.Get("/{id}/nominations", s.viewAwardNominations)
+				})
 			})
 
-			// Publisher
-			r.Route("/publisher", func(r chi.Router) {
-				r.Get("/{id}", s.pagePublisher)
-				r.Post("/{id}", s.savePublisher)
-				r.Post("/{id}/publications", s.viewPublisherPublications)
-			})
-		})
-
-		r.Route("/maintenance", func(r chi.Router) {
-			r.Get("/", s.pageMaintenance)
-			r.Get("/runs", s.viewJobRuns)
-			r.Post("/schedule", s.scheduleJob)
-			r.Get("/schedules", s.viewSchedules)
-			r.Delete("/schedule/{id}", s.deleteSchedule)
-			r.Route("/run", func(r chi.Router) {
-				r.Post("/", s.runJob)
-				r.Get("/{id}/output", s.viewJobRunOutput)
+			r.Route("/maintenance", func(r chi.Router) {
+				r.Use(RequireRole(sirkulator.RoleAdmin))
+
+				r.Get("/", s.pageMaintenance)
+				r.Get("/runs", s.viewJobRuns)
+				r.Post("/schedule", s.scheduleJob)
+				r.Get("/schedules", s.viewSchedules)
+				r.Delete("/schedule/{id}", s.deleteSchedule)
+				r.Get("/loanrules", s.viewLoanRules)
+				r.Post("/loanrule", s.saveLoanRule)
+				r.Delete("/loanrule/{id}", s.deleteLoanRule)
+				r.Get("/calendar", s.viewCalendar)
+				r.Post("/calendar/hours", s.saveOpeningHours)
+				r.Post("/calendar/exception", s.saveCalendarException)
+				r.Delete("/calendar/exception", s.deleteCalendarException)
+				r.Get("/notices", s.viewNotices)
+				r.Get("/index", s.viewIndexQueue)
+				r.Get("/users", s.viewUsers)
+				r.Post("/user", s.saveUser)
+				r.Delete("/user/{id}", s.deleteUser)
+				r.Route("/run", func(r chi.Router) {
+					r.Post("/", s.runJob)
+					r.Get("/{id}/output", s.viewJobRunOutput)
+				})
 			})
 		})
 	})
@@ -256,24 +379,10 @@ func (s *Server) Close() error {
 	return s.srv.Shutdown(ctx)
 }
 
-func (s *Server) indexResources(res []sirkulator.Resource) {
-	if s.idx == nil {
-		return
-	}
-
-	var docs []search.Document
-	for _, r := range res {
-		docs = append(docs, r.Document())
-	}
-	if err := s.idx.Store(docs...); err != nil {
-		log.Println(err) // TODO or not
-	}
-}
-
 func (s *Server) image(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -288,16 +397,16 @@ func (s *Server) image(w http.ResponseWriter, r *http.Request) {
 	}
 	const q = "SELECT rowid, type FROM files.image WHERE id=?"
 	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
 	if rowID == 0 {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
 	blob, err := conn.OpenBlob("files", "image", "data", rowID, false)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 	defer blob.Close()
@@ -309,7 +418,7 @@ func (s *Server) image(w http.ResponseWriter, r *http.Request) {
 func (s *Server) pageHome(w http.ResponseWriter, r *http.Request) {
 	// 404 not found handler goes here
 	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
 
@@ -322,22 +431,13 @@ func (s *Server) pageHome(w http.ResponseWriter, r *http.Request) {
 	tmpl.Render(r.Context(), w)
 }
 
-func (s *Server) pageCirculation(w http.ResponseWriter, r *http.Request) {
-	tmpl := html.CircTemplate{
-		Page: html.Page{
-			Lang: s.Lang,
-			Path: r.URL.Path,
-		},
-	}
-	tmpl.Render(r.Context(), w)
-}
-
 func (s *Server) pageMetadata(w http.ResponseWriter, r *http.Request) {
 	tmpl := html.MetadataTemplate{
 		Page: html.Page{
 			Lang: s.Lang,
 			Path: r.URL.Path,
 		},
+		NewTypes: creatableTypes(),
 	}
 	tmpl.Render(r.Context(), w)
 }
@@ -345,7 +445,7 @@ func (s *Server) pageMetadata(w http.ResponseWriter, r *http.Request) {
 func (s *Server) viewResourceTexts(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -353,7 +453,7 @@ func (s *Server) viewResourceTexts(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	res, err := sql.GetResourceTexts(conn, id)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -366,7 +466,7 @@ func (s *Server) viewResourceTexts(w http.ResponseWriter, r *http.Request) {
 func (s *Server) viewReviews(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -378,7 +478,7 @@ func (s *Server) viewReviews(w http.ResponseWriter, r *http.Request) {
 
 	res, err := sql.GetAllReviews(conn, limit)
 	if err != nil {
-		ServerError(w, err)
+		renderError(w, r, err)
 		return
 	}
 
@@ -391,17 +491,18 @@ func (s *Server) viewReviews(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) importResources(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 	ids := r.PostForm.Get("identifiers")
 	if ids == "" {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
-	ing := etl.NewIngestor(s.db, s.idx)
+	ing := etl.NewIngestor(s.db)
 	ing.ImageDownload = true
 	ing.ImageAsync = true
+	ing.Actor = currentUser(r).Username
 	var res []html.ImportResultEntry
 	for _, id := range strings.Split(ids, "\n") {
 		if len(strings.TrimSpace(id)) < 10 {
@@ -424,13 +525,13 @@ func (s *Server) importResources(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) importPreview(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
 	ids := r.PostForm.Get("identifiers")
 	if ids == "" {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
@@ -464,7 +565,7 @@ func (s *Server) importPreview(w http.ResponseWriter, r *http.Request) {
 func (s *Server) searchResources(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		fmt.Println(err)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		renderError(w, r, sirkulator.ErrInvalid)
 		return
 	}
 
@@ -486,10 +587,13 @@ func (s *Server) searchResources(w http.ResponseWriter, r *http.Request) {
 		SortDir:      sortDir,
 		Limit:        10,
 		InclArchived: r.PostForm.Get("include_archived") != "",
+		Filters:      r.PostForm["filter"],
 	})
-	if err != nil {
-		// TODO do we filter out all user errors above in parseform?
-		ServerError(w, err)
+	if errors.Is(err, search.ErrInvalidQuery) {
+		renderError(w, r, sirkulator.Errorf(sirkulator.CodeInvalid, "%v", err))
+		return
+	} else if err != nil {
+		renderError(w, r, err)
 		return
 	}
 
@@ -501,27 +605,39 @@ func (s *Server) searchResources(w http.ResponseWriter, r *http.Request) {
 	tmpl.Render(r.Context(), w)
 }
 
-// ServerError logs the given error before responding to the request
-// with an Internal Server error.
-// TODO consider taking an error code and (optional) message,
-// which can be conveyed to client, ie.:
-//   "Error code: xyz. If the problem persist, please inform system administrator
-//   with references to the error code."
-func ServerError(w http.ResponseWriter, err error) {
-	// Internal Server errors are normally something that "should not happen",
-	// and therefor interesting to log.
-	log.Println(err)
-
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprintln(w, http.StatusText(http.StatusInternalServerError))
+// suggestResources returns the resources matching the prefix q as you type,
+// optionally of the given type, as JSON.
+func (s *Server) suggestResources(w http.ResponseWriter, r *http.Request) {
+	const defaultLimit, maxLimit = 10, 50
+	limit := defaultLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxLimit {
+		limit = l
+	}
+	type suggestion struct {
+		ID    string `json:"id"`
+		Type  string `json:"type"`
+		Label string `json:"label"`
+	}
+	res := []suggestion{}
+	if s.idx == nil {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	hits, err := s.idx.Suggest(r.Context(), r.URL.Query().Get("q"), r.URL.Query().Get("type"), limit)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	for _, h := range hits {
+		res = append(res, suggestion{ID: h.ID, Type: h.Type, Label: h.Label})
+	}
+	writeJSON(w, http.StatusOK, res)
 }
 
 func (s *Server) deleteRelation(w http.ResponseWriter, r *http.Request) {
 	conn := s.db.Get(r.Context())
 	if conn == nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		renderError(w, r, sirkulator.ErrUnavailable)
 		return
 	}
 	defer s.db.Put(conn)
@@ -529,17 +645,14 @@ func (s *Server) deleteRelation(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.NotFound(w, r)
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
 	}
-	stmt := conn.Prep("DELETE FROM relation WHERE id=$id returning id")
-	stmt.SetInt64("$id", int64(id))
-	defer stmt.Reset()
-	if ok, err := stmt.Step(); err != nil {
-		ServerError(w, err)
+	if err := sql.DeleteRelation(conn, int64(id)); errors.Is(err, sirkulator.ErrNotFound) {
+		renderError(w, r, sirkulator.ErrNotFound)
 		return
-	} else if !ok {
-		http.NotFound(w, r)
+	} else if err != nil {
+		renderError(w, r, err)
 		return
 	}
 
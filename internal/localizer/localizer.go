@@ -57,7 +57,7 @@ func SetDefaultLocale(lang language.Tag) {
 
 func Get(lang language.Tag) Localizer {
 	for _, locale := range locales {
-		if lang.Parent() == locale.Lang {
+		if lang == locale.Lang || lang.Parent() == locale.Lang {
 			return locale
 		}
 	}
@@ -39,116 +39,293 @@ func init() {
 }
 
 var messageKeyToIndex = map[string]int{
-	"%d hits (%v)":                          38,
-	"1 per line":                            16,
-	"About":                                 86,
-	"Actions":                               57,
-	"Add new schedule":                      94,
-	"Agent":                                 82,
-	"Already in catalogue":                  33,
-	"Archived":                              106,
-	"Are you sure?":                         84,
-	"Associated country/area":               63,
-	"Associated nationality":                64,
-	"Audience":                              76,
-	"Basic information":                     39,
-	"Binding":                               78,
-	"Birthyear":                             65,
-	"Broader terms":                         26,
-	"Cancel":                                58,
-	"Choose job":                            96,
-	"Circulation":                           1,
-	"Configuration":                         5,
-	"Content":                               70,
-	"Contributions and relations":           36,
-	"Cover-image":                           34,
-	"Created":                               104,
-	"Cron expression":                       97,
-	"Data":                                  93,
-	"Deathyear":                             66,
-	"Delete":                                85,
-	"Description (short)":                   61,
-	"Dewey number":                          53,
-	"Dewey numbers where %s is a component": 30,
-	"Discontinued":                          90,
-	"Disestablishment year":                 49,
-	"Established":                           89,
-	"Fiction":                               73,
-	"Foundation year":                       47,
-	"Gender":                                62,
-	"Genre and forms":                       75,
-	"Has components":                        28,
-	"Holdings":                              4,
-	"Home":                                  0,
-	"ISBN, ISSN or EAN":                     15,
-	"Identificators and links":              54,
-	"Identifiers":                           14,
-	"Import":                                13,
-	"Job":                                   95,
-	"Latest job runs":                       8,
-	"Lifespan":                              46,
-	"Local and external descriptions":       20,
-	"Main language":                         71,
-	"Maintenance":                           6,
-	"Metadata":                              3,
-	"Must be an integer":                    80,
-	"Name":                                  40,
-	"Name variations":                       43,
-	"Narrower terms":                        27,
-	"Next page":                             52,
-	"Nonfiction":                            74,
-	"Notes":                                 87,
-	"Number of pages":                       79,
-	"One entry per line":                    44,
-	"Orders":                                2,
-	"Other languages":                       72,
-	"Other relations":                       25,
-	"Parent name":                           45,
-	"Personalia":                            60,
-	"Physical characteristics":              77,
-	"Preview":                               17,
-	"Previous page":                         51,
-	"Properties":                            19,
-	"Publication":                           24,
-	"Publication cover-image":               35,
-	"Publications":                          37,
-	"Publications and contributions":        21,
-	"Publications classified with":          31,
-	"Reference terms":                       29,
-	"Relation":                              92,
-	"Required field":                        41,
-	"Resource":                              91,
-	"Role":                                  22,
-	"Role/relation":                         81,
-	"Run now (one-off)":                     99,
-	"Schedule job":                          98,
-	"Scheduled jobs":                        9,
-	"Schedules":                             100,
-	"Search and connect to resource":        83,
-	"Search/browse catalogue":               11,
-	"Short description":                     42,
-	"Show metadata for review":              10,
-	"Show recent transactions":              7,
-	"Started (duration)":                    55,
-	"Status":                                56,
-	"Subtitle":                              68,
-	"This resource is archived":             102,
-	"Title":                                 67,
-	"Uncertain":                             50,
-	"Updated":                               105,
-	"View output":                           59,
-	"Year":                                  23,
+	"%d hits (%v)": 38,
+	"%d resources failed to be indexed, and will be retried.":       268,
+	"%d resources waiting to be indexed, the oldest change %v ago.": 267,
+	"1 per line":                  16,
+	"About":                       86,
+	"Actions":                     57,
+	"Active":                      264,
+	"Add":                         192,
+	"Add exception":               158,
+	"Add item":                    126,
+	"Add new schedule":            94,
+	"Add relation":                236,
+	"Add rule":                    143,
+	"Add user":                    199,
+	"Agent":                       82,
+	"Already in catalogue":        33,
+	"Amount":                      186,
+	"Archived":                    106,
+	"Are you sure?":               84,
+	"Associated country/area":     63,
+	"Associated nationality":      64,
+	"Attempts":                    174,
+	"Audience":                    76,
+	"Award":                       253,
+	"Awards":                      252,
+	"Balance":                     183,
+	"Barcode":                     111,
+	"Basic information":           39,
+	"Binding":                     78,
+	"Birthyear":                   65,
+	"Branch":                      115,
+	"Broader terms":               26,
+	"Cancel":                      58,
+	"Candidates":                  240,
+	"Card number":                 110,
+	"Category":                    123,
+	"Changed by":                  206,
+	"Changes":                     207,
+	"Checked in":                  273,
+	"Checked out":                 119,
+	"Checked out, due %s":         272,
+	"Checkin":                     109,
+	"Checkout":                    108,
+	"Checkout and checkin":        107,
+	"Choose job":                  96,
+	"Circulation":                 1,
+	"Closed":                      156,
+	"Configuration":               5,
+	"Connect":                     241,
+	"Connected to":                232,
+	"Content":                     70,
+	"Contributions and relations": 36,
+	"Cover-image":                 34,
+	"Create new":                  243,
+	"Create patron":               116,
+	"Created":                     104,
+	"Cron expression":             97,
+	"Data":                        93,
+	"Date":                        184,
+	"Dear":                        167,
+	"Deathyear":                   66,
+	"Delete":                      85,
+	"Description (short)":         61,
+	"Dewey number":                53,
+	"Dewey number must be on the form 839.82 or T1--0285": 282,
+	"Dewey numbers where %s is a component":               30,
+	"Discontinued":                                        90,
+	"Disestablishment year":                               49,
+	"Dismiss":                                             244,
+	"Dismissed":                                           247,
+	"Due":                                                 120,
+	"Due date":                                            169,
+	"Edit":                                                237,
+	"Email":                                               113,
+	"Empty patron category, item type or branch matches any value. The most specific matching rule applies.": 136,
+	"Established":                        89,
+	"Exceptions":                         155,
+	"Expires":                            130,
+	"Failed":                             176,
+	"Fee":                                188,
+	"Fiction":                            73,
+	"Fiction/nonfiction":                 263,
+	"Final reminder: overdue loan":       165,
+	"Fine":                               187,
+	"Fine per day":                       180,
+	"Fines and fees":                     193,
+	"Foundation year":                    47,
+	"Friday":                             149,
+	"Gender":                             62,
+	"Genre and forms":                    75,
+	"Has components":                     28,
+	"History":                            204,
+	"Hold placed, position %d in queue":  276,
+	"Holdings":                           4,
+	"Holds":                              134,
+	"Holds allowed":                      142,
+	"Home":                               0,
+	"ID of resource to connect to":       242,
+	"ID of resource to merge into":       215,
+	"ISBN, ISSN or EAN":                  15,
+	"ISSN must be on the form 1234-567X": 251,
+	"Identificators and links":           54,
+	"Identifiers":                        14,
+	"Import":                             13,
+	"Invalid request":                    219,
+	"Item type":                          138,
+	"Items":                              127,
+	"Job":                                95,
+	"Kind regards, the library":          170,
+	"Label":                              208,
+	"Language":                           179,
+	"Latest job runs":                    8,
+	"Leave empty when closed. A branch without any opening hours is considered open every day.": 154,
+	"Leave opening hours empty when closed.":                                                    159,
+	"Leave the password empty to keep the existing password.":                                   200,
+	"Lifespan":                        46,
+	"Links":                           269,
+	"Loan days":                       139,
+	"Loan rules":                      135,
+	"Loans":                           124,
+	"Local and external descriptions": 20,
+	"Log in":                          195,
+	"Log out":                         194,
+	"Main language":                   71,
+	"Maintenance":                     6,
+	"Max balance":                     182,
+	"Max fine":                        181,
+	"Max loans":                       141,
+	"Max renewals":                    140,
+	"Merge":                           216,
+	"Merge into this":                 214,
+	"Merge this resource into another resource of the same type. All relations, identifiers, descriptions and images are moved to the other resource, and this resource is archived.": 211,
+	"Merge this resource into the selected resource?": 213,
+	"Merge with duplicate":                            217,
+	"Merged from":                                     270,
+	"Merged into":                                     271,
+	"Metadata":                                        3,
+	"Monday":                                          145,
+	"Must be an integer":                              80,
+	"Name":                                            40,
+	"Name variations":                                 43,
+	"Narrower terms":                                  27,
+	"New patron":                                      112,
+	"New resource":                                    230,
+	"New transaction":                                 191,
+	"Next page":                                       52,
+	"No":                                              279,
+	"No holds":                                        128,
+	"No loans":                                        117,
+	"No matches":                                      235,
+	"No notices":                                      171,
+	"No recorded changes":                             205,
+	"No resolved reviews":                             245,
+	"Nominated":                                       255,
+	"Nominee":                                         256,
+	"Nominees and winners":                            257,
+	"Nonfiction":                                      74,
+	"Not connected, described as":                     233,
+	"Not found":                                       220,
+	"Not saved. The Dewey number already exists.": 283,
+	"Note":                                157,
+	"Notes":                               87,
+	"Notices":                             178,
+	"Number":                              280,
+	"Number of pages":                     79,
+	"Numbered":                            250,
+	"One entry per line":                  44,
+	"Only for award nominees and winners": 260,
+	"Only for contributors":               231,
+	"Opening hours":                       144,
+	"Orders":                              2,
+	"Organized by":                        258,
+	"Other languages":                     72,
+	"Other relations":                     25,
+	"Parent name":                         45,
+	"Password":                            197,
+	"Patron":                              118,
+	"Patron category":                     137,
+	"Payment":                             189,
+	"Pending":                             177,
+	"Personalia":                          60,
+	"Phone":                               114,
+	"Physical characteristics":            77,
+	"Pickup branch":                       129,
+	"Place hold":                          133,
+	"Please return it, or renew the loan, as soon as possible.": 162,
+	"Possible duplicates":            212,
+	"Preview":                        17,
+	"Previous page":                  51,
+	"Properties":                     19,
+	"Public holidays":                160,
+	"Publication":                    24,
+	"Publication cover-image":        35,
+	"Publication year":               262,
+	"Publications":                   37,
+	"Publications and contributions": 21,
+	"Publications classified with":   31,
+	"Ready for pickup":               131,
+	"Recently resolved":              239,
+	"Recipient":                      172,
+	"Reference terms":                29,
+	"Relation":                       92,
+	"Relation saved.":                238,
+	"Reminder: overdue loan":         161,
+	"Renew":                          122,
+	"Renewed, due %s":                275,
+	"Required field":                 41,
+	"Resolution":                     246,
+	"Resource":                       91,
+	"Returned":                       121,
+	"Revert all later changes?":      209,
+	"Revert to this version":         210,
+	"Review dismissed.":              249,
+	"Review resolved.":               248,
+	"Role":                           22,
+	"Role/relation":                  81,
+	"Run now (one-off)":              99,
+	"Saturday":                       150,
+	"Schedule job":                   98,
+	"Scheduled jobs":                 9,
+	"Schedules":                      100,
+	"Search and connect to resource": 83,
+	"Search by field: isbn:, id:, name:, contributor:, publisher:, series:, year:1990..2000, language:, dewey:839*": 261,
+	"Search for resource to connect to":   234,
+	"Search index":                        265,
+	"Search/browse catalogue":             11,
+	"Second reminder: overdue loan":       163,
+	"Sent":                                175,
+	"Series":                              228,
+	"Set aside for %s (%s), pickup at %s": 274,
+	"Shelfmark":                           125,
+	"Short description":                   42,
+	"Show":                                152,
+	"Show metadata for review":            10,
+	"Show recent transactions":            7,
+	"Something went wrong":                226,
+	"Started (duration)":                  55,
+	"Status":                              56,
+	"Subject":                             173,
+	"Subtitle":                            68,
+	"Sunday":                              151,
+	"Terms":                               281,
+	"The following loan is overdue:":      168,
+	"The operation took too long to complete":                                                         224,
+	"The request conflicts with the current state":                                                    218,
+	"The resource has been changed by someone else":                                                   223,
+	"The search index is up to date.":                                                                 266,
+	"The service is temporarily unavailable, please try again":                                        225,
+	"This is the final reminder. If the item is not returned, you will be charged a replacement fee.": 166,
+	"This is the second reminder. Please return it as soon as possible.":                              164,
+	"This resource is archived":                                                                       102,
+	"Thursday":                                                                                        148,
+	"Title":                                                                                           67,
+	"Tuesday":                                                                                         146,
+	"Type":                                                                                            185,
+	"Uncertain":                                                                                       50,
+	"Updated":                                                                                         105,
+	"Username":                                                                                        196,
+	"Users":                                                                                           198,
+	"View output":                                                                                     59,
+	"Waiting":                                                                                         132,
+	"Waiver":                                                                                          190,
+	"Wednesday":                                                                                       147,
+	"Weekly opening hours":                                                                            153,
+	"Winner":                                                                                          254,
+	"Wrong username or password":                                                                      277,
+	"Year":                                                                                            23,
 	"Year must be a 1-4 digit number. Negative numbers signify BCE.": 48,
 	"Year must be a 4-digit number":                                  69,
+	"Years awarded":                                                  259,
 	"Years of activity":                                              88,
+	"Yes":                                                            278,
+	"You do not have access to this":                                 222,
+	"You must log in":                                                221,
+	"admin":                                                          203,
+	"cataloguer":                                                     201,
+	"circulation":                                                    202,
+	"create":                                                         229,
 	"include archived":                                               12,
 	"include narrower numbers":                                       32,
+	"reference: %s":                                                  227,
 	"restore":                                                        103,
 	"save":                                                           101,
 	"wait...":                                                        18,
 }
 
-var enIndex = []uint32{ // 108 elements
+var enIndex = []uint32{ // 285 elements
 	// Entry 0 - 1F
 	0x00000000, 0x00000005, 0x00000011, 0x00000018,
 	0x00000021, 0x0000002a, 0x00000038, 0x00000044,
@@ -180,9 +357,59 @@ var enIndex = []uint32{ // 108 elements
 	0x000005a7, 0x000005b2, 0x000005c2, 0x000005cf,
 	0x000005e1, 0x000005eb, 0x000005f0, 0x0000060a,
 	0x00000612, 0x0000061a, 0x00000622, 0x0000062b,
-} // Size: 456 bytes
+	0x00000640, 0x00000649, 0x00000651, 0x0000065d,
+	0x00000665, 0x00000670, 0x00000676, 0x0000067c,
+	0x00000683, 0x00000691, 0x0000069a, 0x000006a1,
+	0x000006ad, 0x000006b1, 0x000006ba, 0x000006c0,
+	0x000006c9, 0x000006cf, 0x000006d9, 0x000006e2,
+	// Entry 80 - 9F
+	0x000006e8, 0x000006f1, 0x000006ff, 0x00000707,
+	0x00000718, 0x00000720, 0x0000072b, 0x00000731,
+	0x0000073c, 0x000007a3, 0x000007b3, 0x000007bd,
+	0x000007c7, 0x000007d4, 0x000007de, 0x000007ec,
+	0x000007f5, 0x00000803, 0x0000080a, 0x00000812,
+	0x0000081c, 0x00000825, 0x0000082c, 0x00000835,
+	0x0000083c, 0x00000841, 0x00000856, 0x000008b0,
+	0x000008bb, 0x000008c2, 0x000008c7, 0x000008d5,
+	// Entry A0 - BF
+	0x000008fc, 0x0000090c, 0x00000923, 0x0000095d,
+	0x0000097b, 0x000009be, 0x000009db, 0x00000a3b,
+	0x00000a40, 0x00000a5f, 0x00000a68, 0x00000a82,
+	0x00000a8d, 0x00000a97, 0x00000a9f, 0x00000aa8,
+	0x00000aad, 0x00000ab4, 0x00000abc, 0x00000ac4,
+	0x00000acd, 0x00000ada, 0x00000ae3, 0x00000aef,
+	0x00000af7, 0x00000afc, 0x00000b01, 0x00000b08,
+	0x00000b0d, 0x00000b11, 0x00000b19, 0x00000b20,
+	// Entry C0 - DF
+	0x00000b30, 0x00000b34, 0x00000b43, 0x00000b4b,
+	0x00000b52, 0x00000b5b, 0x00000b64, 0x00000b6a,
+	0x00000b73, 0x00000bab, 0x00000bb6, 0x00000bc2,
+	0x00000bc8, 0x00000bd0, 0x00000be4, 0x00000bef,
+	0x00000bf7, 0x00000bfd, 0x00000c17, 0x00000c2e,
+	0x00000cde, 0x00000cf2, 0x00000d22, 0x00000d32,
+	0x00000d4f, 0x00000d55, 0x00000d6a, 0x00000d97,
+	0x00000da7, 0x00000db1, 0x00000dc1, 0x00000de0,
+	// Entry E0 - FF
+	0x00000e0e, 0x00000e36, 0x00000e6f, 0x00000e84,
+	0x00000e92, 0x00000e99, 0x00000ea0, 0x00000ead,
+	0x00000ec3, 0x00000ed0, 0x00000eec, 0x00000f0e,
+	0x00000f19, 0x00000f26, 0x00000f2b, 0x00000f3b,
+	0x00000f4d, 0x00000f58, 0x00000f60, 0x00000f7d,
+	0x00000f88, 0x00000f90, 0x00000fa4, 0x00000faf,
+	0x00000fb9, 0x00000fca, 0x00000fdc, 0x00000fe5,
+	0x00001008, 0x0000100f, 0x00001015, 0x0000101c,
+	// Entry 100 - 11F
+	0x00001026, 0x0000102e, 0x00001043, 0x00001050,
+	0x0000105e, 0x00001082, 0x000010f0, 0x00001101,
+	0x00001114, 0x0000111b, 0x00001128, 0x00001148,
+	0x00001186, 0x000011be, 0x000011c4, 0x000011d0,
+	0x000011dc, 0x000011f0, 0x000011fb, 0x0000121f,
+	0x0000122f, 0x00001251, 0x0000126c, 0x00001270,
+	0x00001273, 0x0000127a, 0x00001280, 0x000012b4,
+	0x000012e0,
+} // Size: 1164 bytes
 
-const enData string = "" + // Size: 1579 bytes
+const enData string = "" + // Size: 4832 bytes
 	"\x02Home\x02Circulation\x02Orders\x02Metadata\x02Holdings\x02Configurati" +
 	"on\x02Maintenance\x02Show recent transactions\x02Latest job runs\x02Sche" +
 	"duled jobs\x02Show metadata for review\x02Search/browse catalogue\x02inc" +
@@ -209,9 +436,62 @@ const enData string = "" + // Size: 1579 bytes
 	"tablished\x02Discontinued\x02Resource\x02Relation\x02Data\x02Add new sch" +
 	"edule\x02Job\x02Choose job\x02Cron expression\x02Schedule job\x02Run now" +
 	" (one-off)\x02Schedules\x02save\x02This resource is archived\x02restore" +
-	"\x02Created\x02Updated\x02Archived"
+	"\x02Created\x02Updated\x02Archived\x02Checkout and checkin\x02Checkout" +
+	"\x02Checkin\x02Card number\x02Barcode\x02New patron\x02Email\x02Phone" +
+	"\x02Branch\x02Create patron\x02No loans\x02Patron\x02Checked out\x02Due" +
+	"\x02Returned\x02Renew\x02Category\x02Loans\x02Shelfmark\x02Add item\x02I" +
+	"tems\x02No holds\x02Pickup branch\x02Expires\x02Ready for pickup\x02Wait" +
+	"ing\x02Place hold\x02Holds\x02Loan rules\x02Empty patron category, item " +
+	"type or branch matches any value. The most specific matching rule applie" +
+	"s.\x02Patron category\x02Item type\x02Loan days\x02Max renewals\x02Max l" +
+	"oans\x02Holds allowed\x02Add rule\x02Opening hours\x02Monday\x02Tuesday" +
+	"\x02Wednesday\x02Thursday\x02Friday\x02Saturday\x02Sunday\x02Show\x02Wee" +
+	"kly opening hours\x02Leave empty when closed. A branch without any openi" +
+	"ng hours is considered open every day.\x02Exceptions\x02Closed\x02Note" +
+	"\x02Add exception\x02Leave opening hours empty when closed.\x02Public ho" +
+	"lidays\x02Reminder: overdue loan\x02Please return it, or renew the loan," +
+	" as soon as possible.\x02Second reminder: overdue loan\x02This is the se" +
+	"cond reminder. Please return it as soon as possible.\x02Final reminder: " +
+	"overdue loan\x02This is the final reminder. If the item is not returned," +
+	" you will be charged a replacement fee.\x02Dear\x02The following loan is" +
+	" overdue:\x02Due date\x02Kind regards, the library\x02No notices\x02Reci" +
+	"pient\x02Subject\x02Attempts\x02Sent\x02Failed\x02Pending\x02Notices\x02" +
+	"Language\x02Fine per day\x02Max fine\x02Max balance\x02Balance\x02Date" +
+	"\x02Type\x02Amount\x02Fine\x02Fee\x02Payment\x02Waiver\x02New transactio" +
+	"n\x02Add\x02Fines and fees\x02Log out\x02Log in\x02Username\x02Password" +
+	"\x02Users\x02Add user\x02Leave the password empty to keep the existing p" +
+	"assword.\x02cataloguer\x02circulation\x02admin\x02History\x02No recorded" +
+	" changes\x02Changed by\x02Changes\x02Label\x02Revert all later changes?" +
+	"\x02Revert to this version\x02Merge this resource into another resource " +
+	"of the same type. All relations, identifiers, descriptions and images ar" +
+	"e moved to the other resource, and this resource is archived.\x02Possibl" +
+	"e duplicates\x02Merge this resource into the selected resource?\x02Merge" +
+	" into this\x02ID of resource to merge into\x02Merge\x02Merge with duplic" +
+	"ate\x02The request conflicts with the current state\x02Invalid request" +
+	"\x02Not found\x02You must log in\x02You do not have access to this\x02Th" +
+	"e resource has been changed by someone else\x02The operation took too lo" +
+	"ng to complete\x02The service is temporarily unavailable, please try aga" +
+	"in\x02Something went wrong\x02reference: %s\x02Series\x02create\x02New r" +
+	"esource\x02Only for contributors\x02Connected to\x02Not connected, descr" +
+	"ibed as\x02Search for resource to connect to\x02No matches\x02Add relati" +
+	"on\x02Edit\x02Relation saved.\x02Recently resolved\x02Candidates\x02Conn" +
+	"ect\x02ID of resource to connect to\x02Create new\x02Dismiss\x02No resol" +
+	"ved reviews\x02Resolution\x02Dismissed\x02Review resolved.\x02Review dis" +
+	"missed.\x02Numbered\x02ISSN must be on the form 1234-567X\x02Awards\x02A" +
+	"ward\x02Winner\x02Nominated\x02Nominee\x02Nominees and winners\x02Organi" +
+	"zed by\x02Years awarded\x02Only for award nominees and winners\x02Search" +
+	" by field: isbn:, id:, name:, contributor:, publisher:, series:, year:19" +
+	"90..2000, language:, dewey:839*\x02Publication year\x02Fiction/nonfictio" +
+	"n\x02Active\x02Search index\x02The search index is up to date.\x02%d res" +
+	"ources waiting to be indexed, the oldest change %v ago.\x02%d resources " +
+	"failed to be indexed, and will be retried.\x02Links\x02Merged from\x02Me" +
+	"rged into\x02Checked out, due %s\x02Checked in\x02Set aside for %s (%s)," +
+	" pickup at %s\x02Renewed, due %s\x02Hold placed, position %d in queue" +
+	"\x02Wrong username or password\x02Yes\x02No\x02Number\x02Terms\x02Dewey " +
+	"number must be on the form 839.82 or T1--0285\x02Not saved. The Dewey nu" +
+	"mber already exists."
 
-var noIndex = []uint32{ // 108 elements
+var noIndex = []uint32{ // 285 elements
 	// Entry 0 - 1F
 	0x00000000, 0x00000005, 0x00000011, 0x0000001e,
 	0x00000027, 0x0000002f, 0x0000003d, 0x00000049,
@@ -243,9 +523,59 @@ var noIndex = []uint32{ // 108 elements
 	0x000005aa, 0x000005b4, 0x000005c1, 0x000005ca,
 	0x000005de, 0x000005f3, 0x000005f9, 0x00000615,
 	0x00000621, 0x0000062b, 0x00000632, 0x0000063b,
-} // Size: 456 bytes
+	0x00000651, 0x00000658, 0x00000664, 0x0000066f,
+	0x00000679, 0x00000683, 0x0000068a, 0x00000692,
+	0x00000699, 0x000006a8, 0x000006b3, 0x000006ba,
+	0x000006c2, 0x000006ca, 0x000006d1, 0x000006d7,
+	0x000006e0, 0x000006e5, 0x000006f3, 0x00000706,
+	// Entry 80 - 9F
+	0x00000712, 0x00000726, 0x00000730, 0x00000739,
+	0x0000074a, 0x00000751, 0x0000075a, 0x00000768,
+	0x00000776, 0x000007ef, 0x000007fe, 0x0000080c,
+	0x0000081d, 0x0000082d, 0x00000837, 0x00000845,
+	0x00000854, 0x00000862, 0x00000869, 0x00000871,
+	0x00000878, 0x00000880, 0x00000887, 0x0000088f,
+	0x00000897, 0x0000089b, 0x000008b3, 0x00000907,
+	0x0000090e, 0x00000915, 0x0000091d, 0x0000092d,
+	// Entry A0 - BF
+	0x00000955, 0x00000961, 0x0000097b, 0x000009c1,
+	0x000009e1, 0x00000a2e, 0x00000a4e, 0x00000ab0,
+	0x00000ab7, 0x00000ad3, 0x00000ae0, 0x00000afb,
+	0x00000b09, 0x00000b12, 0x00000b17, 0x00000b1f,
+	0x00000b25, 0x00000b2c, 0x00000b33, 0x00000b3b,
+	0x00000b42, 0x00000b50, 0x00000b5b, 0x00000b66,
+	0x00000b6c, 0x00000b71, 0x00000b76, 0x00000b7d,
+	0x00000b88, 0x00000b8e, 0x00000b9a, 0x00000ba7,
+	// Entry C0 - DF
+	0x00000bb6, 0x00000bbf, 0x00000bc7, 0x00000bcf,
+	0x00000bd8, 0x00000be3, 0x00000beb, 0x00000bf3,
+	0x00000c03, 0x00000c3f, 0x00000c4d, 0x00000c59,
+	0x00000c67, 0x00000c71, 0x00000c8d, 0x00000c97,
+	0x00000ca1, 0x00000ca9, 0x00000ccd, 0x00000cee,
+	0x00000da6, 0x00000db8, 0x00000dee, 0x00000e04,
+	0x00000e2f, 0x00000e3b, 0x00000e54, 0x00000e88,
+	0x00000e9d, 0x00000ea9, 0x00000eba, 0x00000ed8,
+	// Entry E0 - FF
+	0x00000efa, 0x00000f17, 0x00000f4b, 0x00000f59,
+	0x00000f67, 0x00000f6d, 0x00000f75, 0x00000f80,
+	0x00000f95, 0x00000fa0, 0x00000fbb, 0x00000fdb,
+	0x00000fe7, 0x00000ff9, 0x00001001, 0x00001017,
+	0x00001027, 0x00001032, 0x0000103c, 0x00001058,
+	0x00001063, 0x00001069, 0x00001088, 0x0000108f,
+	0x00001096, 0x000010b2, 0x000010cb, 0x000010d5,
+	0x000010f9, 0x00001100, 0x00001105, 0x0000110c,
+	// Entry 100 - 11F
+	0x00001115, 0x0000111e, 0x00001133, 0x0000113f,
+	0x0000114a, 0x00001171, 0x000011db, 0x000011e9,
+	0x00001200, 0x00001206, 0x00001212, 0x0000122e,
+	0x00001276, 0x000012b8, 0x000012bf, 0x000012d1,
+	0x000012e3, 0x000012f7, 0x00001301, 0x00001324,
+	0x00001338, 0x00001362, 0x00001380, 0x00001383,
+	0x00001387, 0x0000138e, 0x000013a0, 0x000013d7,
+	0x00001403,
+} // Size: 1164 bytes
 
-const noData string = "" + // Size: 1595 bytes
+const noData string = "" + // Size: 5123 bytes
 	"\x02Hjem\x02Sirkulasjon\x02Bestillinger\x02Metadata\x02Bestand\x02Konfig" +
 	"urasjon\x02Vedlikehold\x02Vis siste transaksjoner\x02Siste kjøringer\x02" +
 	"Planlagte kjøringer\x02Vis opplysninger til gjennomsyn\x02Søk/bla i kata" +
@@ -272,6 +602,61 @@ const noData string = "" + // Size: 1595 bytes
 	"\x02Ressurs\x02Relasjon\x02Data\x02Sett opp ny kjøring\x02Jobb\x02Velg j" +
 	"obb\x02Cron-uttrykk\x02Legg til\x02Kjør nå (en gang)\x02Planlagte kjørin" +
 	"ger\x02lagre\x02Denne ressursen er akrivert\x02gjenopprett\x02Opprettet" +
-	"\x02Endret\x02Arkivert"
+	"\x02Endret\x02Arkivert\x02Utlån og innlevering\x02Utlån\x02Innlevering" +
+	"\x02Kortnummer\x02Strekkode\x02Ny låner\x02E-post\x02Telefon\x02Filial" +
+	"\x02Opprett låner\x02Ingen lån\x02Låner\x02Utlånt\x02Forfall\x02Levert" +
+	"\x02Forny\x02Kategori\x02Lån\x02Hyllesignatur\x02Legg til eksemplar\x02E" +
+	"ksemplarer\x02Ingen reserveringer\x02Hentested\x02Utløper\x02Klar til he" +
+	"nting\x02Venter\x02Reserver\x02Reserveringer\x02Utlånsregler\x02Tom låne" +
+	"rkategori, eksemplartype eller filial gjelder for alle verdier. Den mest" +
+	" spesifikke regelen som passer gjelder.\x02Lånerkategori\x02Eksemplartyp" +
+	"e\x02Lånetid (dager)\x02Maks fornyelser\x02Maks lån\x02Kan reservere\x02" +
+	"Legg til regel\x02Åpningstider\x02Mandag\x02Tirsdag\x02Onsdag\x02Torsdag" +
+	"\x02Fredag\x02Lørdag\x02Søndag\x02Vis\x02Ukentlige åpningstider\x02La st" +
+	"å tomt når stengt. En filial uten åpningstider regnes som åpen alle dag" +
+	"er.\x02Unntak\x02Stengt\x02Merknad\x02Legg til unntak\x02La åpningstider" +
+	" stå tomt når stengt.\x02Helligdager\x02Påminnelse: forfalt lån\x02Vennl" +
+	"igst lever det tilbake, eller forny lånet, så snart som mulig.\x02Andre " +
+	"påminnelse: forfalt lån\x02Dette er andre påminnelse. Vennligst lever de" +
+	"t tilbake så snart som mulig.\x02Siste påminnelse: forfalt lån\x02Dette " +
+	"er siste påminnelse. Dersom det ikke leveres tilbake, vil du bli fakture" +
+	"rt for erstatning.\x02Kjære\x02Følgende lån har forfalt:\x02Forfallsdato" +
+	"\x02Vennlig hilsen biblioteket\x02Ingen varsler\x02Mottaker\x02Emne\x02F" +
+	"orsøk\x02Sendt\x02Feilet\x02Venter\x02Varsler\x02Språk\x02Gebyr per dag" +
+	"\x02Maks gebyr\x02Maks saldo\x02Saldo\x02Dato\x02Type\x02Beløp\x02Purreg" +
+	"ebyr\x02Gebyr\x02Innbetaling\x02Ettergivelse\x02Ny transaksjon\x02Legg t" +
+	"il\x02Gebyrer\x02Logg ut\x02Logg inn\x02Brukernavn\x02Passord\x02Brukere" +
+	"\x02Legg til bruker\x02La passordet stå tomt for å beholde eksisterende " +
+	"passord.\x02katalogisator\x02sirkulasjon\x02administrator\x02Historikk" +
+	"\x02Ingen registrerte endringer\x02Endret av\x02Endringer\x02Etikett\x02" +
+	"Tilbakestill alle senere endringer?\x02Tilbakestill til denne versjonen" +
+	"\x02Slå sammen denne ressursen med en annen ressurs av samme type. Alle " +
+	"relasjoner, identifikatorer, beskrivelser og bilder flyttes til den andr" +
+	"e ressursen, og denne ressursen arkiveres.\x02Mulige duplikater\x02Slå s" +
+	"ammen denne ressursen med den valgte ressursen?\x02Slå sammen med denne" +
+	"\x02ID til ressursen det skal slås sammen med\x02Slå sammen\x02Slå samme" +
+	"n med duplikat\x02Forespørselen er i konflikt med gjeldende tilstand\x02" +
+	"Ugyldig forespørsel\x02Ikke funnet\x02Du må logge inn\x02Du har ikke til" +
+	"gang til dette\x02Ressursen er endret av noen andre\x02Operasjonen tok f" +
+	"or lang tid\x02Tjenesten er midlertidig utilgjengelig, prøv igjen\x02Noe" +
+	" gikk galt\x02referanse: %s\x02Serie\x02opprett\x02Ny ressurs\x02Kun for" +
+	" bidragsytere\x02Koblet til\x02Ikke koblet, beskrevet som\x02Søk etter r" +
+	"essurs å koble til\x02Ingen treff\x02Legg til relasjon\x02Rediger\x02Rel" +
+	"asjonen er lagret.\x02Nylig behandlet\x02Kandidater\x02Koble til\x02ID t" +
+	"il ressurs å koble til\x02Opprett ny\x02Avvis\x02Ingen behandlede gjenno" +
+	"mganger\x02Utfall\x02Avvist\x02Gjennomgangen er behandlet.\x02Gjennomgan" +
+	"gen er avvist.\x02Nummerert\x02ISSN må være på formen 1234-567X\x02Prise" +
+	"r\x02Pris\x02Vinner\x02Nominert\x02Nominert\x02Nominerte og vinnere\x02D" +
+	"eles ut av\x02År utdelt\x02Kun for nominerte og vinnere av priser\x02Søk" +
+	" i felt: isbn:, id:, name:, contributor:, publisher:, series:, year:1990" +
+	"..2000, language:, dewey:839*\x02Utgivelsesår\x02Skjønn-/faglitteratur" +
+	"\x02Aktiv\x02Søkeindeks\x02Søkeindeksen er oppdatert.\x02%d ressurser ve" +
+	"nter på indeksering, den eldste endringen for %v siden.\x02%d ressurser " +
+	"kunne ikke indekseres, og vil bli forsøkt på nytt.\x02Lenker\x02Slått sa" +
+	"mmen fra\x02Slått sammen med\x02Utlånt, forfall %s\x02Innlevert\x02Lagt " +
+	"av til %s (%s), hentes på %s\x02Fornyet, forfall %s\x02Reservasjon regis" +
+	"trert, nummer %d i køen\x02Feil brukernavn eller passord\x02Ja\x02Nei" +
+	"\x02Nummer\x02Henvisningstermer\x02Deweynummer må være på formen 839.82 " +
+	"eller T1--0285\x02Ikke lagret. Deweynummeret finnes allerede."
 
-	// Total table size 4086 bytes (3KiB); checksum: F4861F79
+	// Total table size 12283 bytes (11KiB); checksum: 4AE04E60
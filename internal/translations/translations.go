@@ -1,3 +1,3 @@
 package translations
 
-//go:generate go run golang.org/x/text/cmd/gotext -srclang=en update -out=catalog.go -lang=en,no github.com/knakk/sirkulator/http/html
+//go:generate go run golang.org/x/text/cmd/gotext -srclang=en update -out=catalog.go -lang=en,no github.com/knakk/sirkulator/http/html github.com/knakk/sirkulator/notice
@@ -2,6 +2,7 @@ package marc
 
 import (
 	"errors"
+	"sort"
 
 	"github.com/knakk/sirkulator/internal/localizer"
 	"golang.org/x/text/language"
@@ -112,3 +113,16 @@ func (r Relator) Label(tag language.Tag) string {
 	}
 	return relators[r.code][0]
 }
+
+// RelatorOptions returns all known relators as pairs of code and label in
+// the desired language, sorted by label.
+func RelatorOptions(tag language.Tag) (res [][2]string) {
+	for code := range relators {
+		r := Relator{code: code}
+		res = append(res, [2]string{code, r.Label(tag)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i][1] < res[j][1]
+	})
+	return res
+}
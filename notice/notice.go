@@ -0,0 +1,218 @@
+package notice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/internal/localizer"
+	"github.com/knakk/sirkulator/sql"
+	"golang.org/x/text/language"
+)
+
+// Reminder is a level of overdue notice.
+type Reminder struct {
+	Type        string
+	DaysOverdue int // minimum number of days overdue before notice is sent
+}
+
+// Reminders are the overdue notices sent to patrons, in increasing order of severity.
+var Reminders = []Reminder{
+	{Type: "overdue_1", DaysOverdue: 1},
+	{Type: "overdue_2", DaysOverdue: 14},
+	{Type: "overdue_final", DaysOverdue: 28},
+}
+
+// reminderFor returns the most severe reminder due for a loan overdue since the given time,
+// and false if the loan is not overdue long enough for any reminders.
+func reminderFor(dueAt, now time.Time) (Reminder, bool) {
+	days := int(now.Sub(dueAt).Hours() / 24)
+	for i := len(Reminders) - 1; i >= 0; i-- {
+		if days >= Reminders[i].DaysOverdue {
+			return Reminders[i], true
+		}
+	}
+	return Reminder{}, false
+}
+
+var tmplOverdue = template.Must(template.New("overdue").Parse(`{{.Greeting}} {{.Name}},
+
+{{.Intro}}
+
+    {{.Title}} ({{.Barcode}})
+    {{.DueLabel}}: {{.Due}}
+
+{{.Outro}}
+
+{{.Signature}}
+`))
+
+// compose returns the subject and body of an overdue notice, translated to the
+// given language.
+func compose(lang language.Tag, r Reminder, loan sirkulator.LoanExp) (string, string, error) {
+	l := localizer.Get(lang)
+
+	var subject, outro string
+	switch r.Type {
+	case "overdue_1":
+		subject = l.Translate("Reminder: overdue loan")
+		outro = l.Translate("Please return it, or renew the loan, as soon as possible.")
+	case "overdue_2":
+		subject = l.Translate("Second reminder: overdue loan")
+		outro = l.Translate("This is the second reminder. Please return it as soon as possible.")
+	default:
+		subject = l.Translate("Final reminder: overdue loan")
+		outro = l.Translate("This is the final reminder. If the item is not returned, you will be charged a replacement fee.")
+	}
+
+	var b strings.Builder
+	err := tmplOverdue.Execute(&b, struct {
+		Greeting, Name, Intro, Title, Barcode, DueLabel, Due, Outro, Signature string
+	}{
+		Greeting:  l.Translate("Dear"),
+		Name:      loan.PatronName,
+		Intro:     l.Translate("The following loan is overdue:"),
+		Title:     loan.Publication.Label,
+		Barcode:   loan.Barcode,
+		DueLabel:  l.Translate("Due date"),
+		Due:       loan.DueAt.Format("2006-01-02"),
+		Outro:     outro,
+		Signature: l.Translate("Kind regards, the library"),
+	})
+	return subject, b.String(), err
+}
+
+// OverdueJob is a job which generates reminder notices for overdue loans,
+// and adds them to the notice queue.
+type OverdueJob struct {
+	DB *sqlitex.Pool
+}
+
+func (j *OverdueJob) Name() string {
+	return "overdue_notices"
+}
+
+func (j *OverdueJob) Run(ctx context.Context, w io.Writer) error {
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	now := time.Now()
+	loans, err := sql.GetOverdueLoans(conn, now)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "found %d overdue loans\n", len(loans))
+
+	n := 0
+	for _, loan := range loans {
+		r, ok := reminderFor(loan.DueAt, now)
+		if !ok {
+			continue
+		}
+		sent, err := sql.GetLoanNoticeTypes(conn, loan.ID)
+		if err != nil {
+			return err
+		}
+		if alreadySent(sent, r) {
+			continue
+		}
+
+		patron, err := sql.GetPatron(conn, loan.PatronID)
+		if err != nil {
+			return err
+		}
+		subject, body, err := compose(language.Make(patron.Lang), r, loan)
+		if err != nil {
+			return err
+		}
+
+		_, err = sql.CreateNotice(conn, sirkulator.Notice{
+			PatronID:  patron.ID,
+			LoanID:    loan.ID,
+			Type:      r.Type,
+			Recipient: patron.Email,
+			Subject:   subject,
+			Body:      body,
+		})
+		if err != nil {
+			return err
+		}
+		n++
+	}
+	fmt.Fprintf(w, "created %d notices\n", n)
+	return nil
+}
+
+// alreadySent reports whether the given reminder, or a more severe one, is among
+// the notice types sent.
+func alreadySent(sent []string, r Reminder) bool {
+	severity := func(typ string) int {
+		for i, r := range Reminders {
+			if r.Type == typ {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, typ := range sent {
+		if severity(typ) >= severity(r.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxAttempts is the number of delivery attempts before giving up on a notice.
+const MaxAttempts = 3
+
+// SendJob is a job which delivers the queued notices using the given transport.
+type SendJob struct {
+	DB        *sqlitex.Pool
+	Transport Transport
+}
+
+func (j *SendJob) Name() string {
+	return "send_notices"
+}
+
+func (j *SendJob) Run(ctx context.Context, w io.Writer) error {
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	notices, err := sql.GetUndeliveredNotices(conn, MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, n := range notices {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		sendErr := j.Transport.Send(ctx, n)
+		if sendErr != nil {
+			failed++
+			fmt.Fprintf(w, "notice %d to %q failed: %v\n", n.ID, n.Recipient, sendErr)
+		}
+		if err := sql.SetNoticeDelivered(conn, n.ID, sendErr); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "sent %d of %d notices\n", len(notices)-failed, len(notices))
+	if failed > 0 {
+		return errors.New("some notices could not be delivered")
+	}
+	return nil
+}
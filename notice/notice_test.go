@@ -0,0 +1,110 @@
+package notice
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/sql"
+)
+
+func TestReminderFor(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		daysOverdue int
+		want        string
+	}{
+		{0, ""},
+		{1, "overdue_1"},
+		{13, "overdue_1"},
+		{14, "overdue_2"},
+		{100, "overdue_final"},
+	}
+	for _, test := range tests {
+		r, _ := reminderFor(now.AddDate(0, 0, -test.daysOverdue), now)
+		if r.Type != test.want {
+			t.Errorf("reminderFor %d days overdue = %q; want %q", test.daysOverdue, r.Type, test.want)
+		}
+	}
+
+	if !alreadySent([]string{"overdue_2"}, Reminders[0]) {
+		t.Error("alreadySent: first reminder should not be sent after second")
+	}
+	if alreadySent([]string{"overdue_1"}, Reminders[1]) {
+		t.Error("alreadySent: second reminder should be sent after first")
+	}
+}
+
+func TestOverdueNotices(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sql.CreateItem(conn, sirkulator.Item{Barcode: "0301", PublicationID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sql.CreatePatron(conn, sirkulator.Patron{CardNumber: "N001", Name: "Knut", Email: "knut@example.org", Lang: "no"}); err != nil {
+		t.Fatal(err)
+	}
+	loan, err := sql.Checkout(conn, "0301", "N001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlitex.Exec(conn, "UPDATE loan SET due_at=? WHERE id=?", nil, time.Now().AddDate(0, 0, -15).Unix(), loan.ID); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+
+	job := OverdueJob{DB: db}
+	for i := 0; i < 2; i++ {
+		// Running the job twice should not create duplicate notices.
+		if err := job.Run(context.Background(), &bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	send := SendJob{DB: db, Transport: &Dir{Path: dir}}
+	if err := send.Run(context.Background(), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn = db.Get(nil)
+	defer db.Put(conn)
+	notices, err := sql.GetNotices(conn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 {
+		t.Fatalf("got %d notices; want 1", len(notices))
+	}
+	n := notices[0]
+	if n.Type != "overdue_2" || n.Status != sirkulator.NoticeSent || n.Recipient != "knut@example.org" {
+		t.Errorf("got notice %+v; want sent second reminder to knut@example.org", n)
+	}
+	if !strings.Contains(n.Body, "Sult (0301)") || !strings.Contains(n.Subject, "påminnelse") {
+		t.Errorf("got notice subject %q and body %q; want norwegian reminder about Sult (0301)", n.Subject, n.Body)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "notice-1.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("To: knut@example.org\r\n")) {
+		t.Errorf("notice file missing recipient:\n%s", b)
+	}
+}
@@ -0,0 +1,65 @@
+package notice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/knakk/sirkulator"
+)
+
+// Transport delivers notices to patrons.
+type Transport interface {
+	Send(ctx context.Context, n sirkulator.Notice) error
+}
+
+// errNoRecipient is returned when trying to send a notice to a patron without email address.
+var errNoRecipient = errors.New("patron has no email address")
+
+// message formats the notice as an email message.
+func message(from string, n sirkulator.Notice) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", n.Recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTP is a Transport which delivers notices as email through an SMTP server.
+type SMTP struct {
+	Addr string    // host:port of SMTP server
+	From string    // sender address
+	Auth smtp.Auth // optional
+}
+
+func (t *SMTP) Send(ctx context.Context, n sirkulator.Notice) error {
+	if n.Recipient == "" {
+		return errNoRecipient
+	}
+	return smtp.SendMail(t.Addr, t.Auth, t.From, []string{n.Recipient}, message(t.From, n))
+}
+
+// Dir is a Transport which writes notices as email messages to files in a directory.
+// Useful for testing, or for libraries printing notices.
+type Dir struct {
+	Path string
+}
+
+func (t *Dir) Send(ctx context.Context, n sirkulator.Notice) error {
+	if err := os.MkdirAll(t.Path, 0755); err != nil {
+		return err
+	}
+	name := filepath.Join(t.Path, fmt.Sprintf("notice-%d.eml", n.ID))
+	return os.WriteFile(name, message("sirkulator", n), 0644)
+}
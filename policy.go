@@ -0,0 +1,147 @@
+package sirkulator
+
+import "time"
+
+// LoanRule is a rule in the loan policy matrix of patron category × item type × branch.
+// An empty PatronCategory, ItemType or Branch matches any value.
+type LoanRule struct {
+	ID             int64
+	PatronCategory string
+	ItemType       string
+	Branch         string
+	LoanDays       int   // 0 means items cannot be borrowed
+	MaxRenewals    int   // number of times a loan can be renewed
+	MaxLoans       int   // max concurrent loans of patron, 0 means no limit
+	HoldsAllowed   bool  // whether patrons can place holds
+	FinePerDay     int64 // fine accrued per day a loan is overdue, in minor units
+	MaxFine        int64 // max fine accrued per loan, 0 means no limit
+	MaxBalance     int64 // balance of fines and fees above which circulation is blocked, 0 means no limit
+}
+
+// DefaultLoanRule is used when no rule in a LoanPolicy matches.
+var DefaultLoanRule = LoanRule{
+	LoanDays:     28,
+	MaxRenewals:  2,
+	HoldsAllowed: true,
+}
+
+// LoanPeriod returns the loan period of the rule.
+func (r LoanRule) LoanPeriod() time.Duration {
+	return time.Duration(r.LoanDays) * 24 * time.Hour
+}
+
+// specificity returns a score used to pick the most specific of several matching
+// rules. Branch takes precedence over patron category, which takes precedence
+// over item type.
+func (r LoanRule) specificity() int {
+	n := 0
+	if r.Branch != "" {
+		n += 4
+	}
+	if r.PatronCategory != "" {
+		n += 2
+	}
+	if r.ItemType != "" {
+		n += 1
+	}
+	return n
+}
+
+func (r LoanRule) matches(category, itemType, branch string) bool {
+	return (r.PatronCategory == "" || r.PatronCategory == category) &&
+		(r.ItemType == "" || r.ItemType == itemType) &&
+		(r.Branch == "" || r.Branch == branch)
+}
+
+// Fine returns the total fine accrued according to the rule, for a
+// loan with the given due date at the given time.
+func (r LoanRule) Fine(dueAt, now time.Time) int64 {
+	if !now.After(dueAt) {
+		return 0
+	}
+	days := int64(now.Sub(dueAt) / (24 * time.Hour))
+	fine := days * r.FinePerDay
+	if r.MaxFine > 0 && fine > r.MaxFine {
+		fine = r.MaxFine
+	}
+	return fine
+}
+
+func (r LoanRule) checkBalance(patron Patron, balance int64) error {
+	if r.MaxBalance > 0 && balance > r.MaxBalance {
+		return Errorf(CodeConflict, "patron %s has unpaid fines and fees of %s, which is above the limit of %s",
+			patron.CardNumber, FormatAmount(balance), FormatAmount(r.MaxBalance))
+	}
+	return nil
+}
+
+// LoanPolicy is a set of LoanRules, which together determine if circulation
+// transactions are allowed, and on which terms.
+type LoanPolicy []LoanRule
+
+// Rule returns the most specific rule matching the given patron category,
+// item type and branch, or DefaultLoanRule if no rule matches.
+func (p LoanPolicy) Rule(category, itemType, branch string) LoanRule {
+	best, found := DefaultLoanRule, false
+	for _, r := range p {
+		if !r.matches(category, itemType, branch) {
+			continue
+		}
+		if !found || r.specificity() > best.specificity() {
+			best, found = r, true
+		}
+	}
+	return best
+}
+
+// Checkout evaluates the policy for checking out the given item to the given patron,
+// which currently has the given number of active loans and balance of fines and fees.
+// It returns the due date of the loan, or an error explaining why the checkout is not allowed.
+func (p LoanPolicy) Checkout(patron Patron, item Item, activeLoans int, balance int64, now time.Time) (time.Time, error) {
+	rule := p.Rule(patron.Category, item.Type, item.Branch)
+	if err := rule.checkBalance(patron, balance); err != nil {
+		return now, err
+	}
+	if rule.LoanDays <= 0 {
+		return now, Errorf(CodeConflict, "items of type %q cannot be borrowed by patron category %q", item.Type, patron.Category)
+	}
+	if rule.MaxLoans > 0 && activeLoans >= rule.MaxLoans {
+		return now, Errorf(CodeConflict, "patron %s has %d loans, which is the maximum allowed", patron.CardNumber, activeLoans)
+	}
+	return now.Add(rule.LoanPeriod()), nil
+}
+
+// Renew evaluates the policy for renewing the given loan, given the number of
+// other patrons waiting for the item's publication and the patron's balance of
+// fines and fees. It returns the new due date of the loan, or an error explaining
+// why the renewal is not allowed.
+func (p LoanPolicy) Renew(patron Patron, item Item, loan Loan, waitingHolds int, balance int64, now time.Time) (time.Time, error) {
+	rule := p.Rule(patron.Category, item.Type, item.Branch)
+	if err := rule.checkBalance(patron, balance); err != nil {
+		return now, err
+	}
+	if loan.Renewals >= rule.MaxRenewals {
+		return now, Errorf(CodeConflict, "item %s has been renewed %d times, which is the maximum allowed", item.Barcode, loan.Renewals)
+	}
+	if waitingHolds > 0 {
+		return now, Errorf(CodeConflict, "item %s cannot be renewed, other patrons are waiting for it", item.Barcode)
+	}
+	return now.Add(rule.LoanPeriod()), nil
+}
+
+// Hold evaluates the policy for the given patron placing a hold on a publication
+// with the given items. The hold is allowed if any of the items allows it. If the
+// publication has no items, the rules matching any item type are used.
+func (p LoanPolicy) Hold(patron Patron, items []Item) error {
+	if len(items) == 0 {
+		if p.Rule(patron.Category, "", patron.Branch).HoldsAllowed {
+			return nil
+		}
+	}
+	for _, item := range items {
+		if p.Rule(patron.Category, item.Type, item.Branch).HoldsAllowed {
+			return nil
+		}
+	}
+	return Errorf(CodeConflict, "patron category %q is not allowed to place holds on this publication", patron.Category)
+}
@@ -0,0 +1,106 @@
+package sirkulator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoanPolicyRule(t *testing.T) {
+	policy := LoanPolicy{
+		{ID: 1, LoanDays: 28, MaxRenewals: 2},
+		{ID: 2, ItemType: "dvd", LoanDays: 7},
+		{ID: 3, PatronCategory: "child", ItemType: "dvd", LoanDays: 0},
+		{ID: 4, PatronCategory: "child", LoanDays: 21},
+		{ID: 5, Branch: "bjerke", LoanDays: 14},
+	}
+
+	tests := []struct {
+		category, itemType, branch string
+		want                       int64
+	}{
+		{"adult", "book", "main", 1},
+		{"adult", "dvd", "main", 2},
+		{"child", "dvd", "main", 3},
+		{"child", "book", "main", 4},
+		{"child", "dvd", "bjerke", 5},
+	}
+	for _, test := range tests {
+		if got := policy.Rule(test.category, test.itemType, test.branch); got.ID != test.want {
+			t.Errorf("Rule(%q, %q, %q) = rule %d; want %d", test.category, test.itemType, test.branch, got.ID, test.want)
+		}
+	}
+
+	if got := (LoanPolicy{}).Rule("adult", "book", "main"); got != DefaultLoanRule {
+		t.Errorf("empty policy Rule = %+v; want DefaultLoanRule", got)
+	}
+}
+
+func TestLoanPolicyEvaluate(t *testing.T) {
+	policy := LoanPolicy{
+		{LoanDays: 28, MaxRenewals: 1, MaxLoans: 2, HoldsAllowed: true, MaxBalance: 10000},
+		{PatronCategory: "child", ItemType: "dvd"},
+	}
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	adult := Patron{CardNumber: "N1", Category: "adult"}
+	child := Patron{CardNumber: "N2", Category: "child"}
+	book := Item{Barcode: "1", Type: "book"}
+	dvd := Item{Barcode: "2", Type: "dvd"}
+
+	isBlocked := func(err error) bool {
+		var sErr *Error
+		return errors.As(err, &sErr) && sErr.Code == CodeConflict && sErr.Message != ""
+	}
+
+	if due, err := policy.Checkout(adult, book, 1, 0, now); err != nil || !due.Equal(now.Add(28*24*time.Hour)) {
+		t.Errorf("Checkout = %v, %v; want due in 28 days", due, err)
+	}
+	if _, err := policy.Checkout(adult, book, 2, 0, now); !isBlocked(err) {
+		t.Errorf("Checkout with max loans got %v; want blocked", err)
+	}
+	if _, err := policy.Checkout(child, dvd, 0, 0, now); !isBlocked(err) {
+		t.Errorf("Checkout of non-loanable item type got %v; want blocked", err)
+	}
+
+	if _, err := policy.Checkout(adult, book, 0, 10001, now); !isBlocked(err) {
+		t.Errorf("Checkout with balance above limit got %v; want blocked", err)
+	}
+
+	if _, err := policy.Renew(adult, book, Loan{}, 0, 0, now); err != nil {
+		t.Errorf("Renew got %v; want allowed", err)
+	}
+	if _, err := policy.Renew(adult, book, Loan{Renewals: 1}, 0, 0, now); !isBlocked(err) {
+		t.Errorf("Renew with max renewals got %v; want blocked", err)
+	}
+	if _, err := policy.Renew(adult, book, Loan{}, 1, 0, now); !isBlocked(err) {
+		t.Errorf("Renew with waiting holds got %v; want blocked", err)
+	}
+
+	if err := policy.Hold(child, []Item{dvd}); !isBlocked(err) {
+		t.Errorf("Hold not allowed got %v; want blocked", err)
+	}
+	if err := policy.Hold(child, []Item{dvd, book}); err != nil {
+		t.Errorf("Hold got %v; want allowed", err)
+	}
+}
+
+func TestLoanRuleFine(t *testing.T) {
+	rule := LoanRule{FinePerDay: 500, MaxFine: 2000}
+	due := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now  time.Time
+		want int64
+	}{
+		{due.Add(-time.Hour), 0},
+		{due.Add(23 * time.Hour), 0},
+		{due.Add(24 * time.Hour), 500},
+		{due.AddDate(0, 0, 3), 1500},
+		{due.AddDate(0, 0, 10), 2000},
+	}
+	for _, test := range tests {
+		if got := rule.Fine(due, test.now); got != test.want {
+			t.Errorf("Fine at %v = %d; want %d", test.now, got, test.want)
+		}
+	}
+}
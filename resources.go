@@ -11,14 +11,15 @@ import (
 
 	"github.com/knakk/sirkulator/internal/localizer"
 	"github.com/knakk/sirkulator/marc"
-	"github.com/knakk/sirkulator/search"
 	"github.com/knakk/sirkulator/vocab"
+	"github.com/knakk/sirkulator/vocab/iso6393"
 	"github.com/teris-io/shortid"
 	"golang.org/x/text/language"
 )
 
 type Persistable interface {
 	Validate(url.Values) (Persistable, bool)
+	Valid() bool
 	Label() string
 }
 
@@ -91,6 +92,29 @@ func (r ResourceType) Label(tag language.Tag) string {
 	}
 }
 
+// NewData returns a pointer to a new, empty value of the data type of
+// resources of type r, or nil if there is no such data type yet.
+func (r ResourceType) NewData() any {
+	switch r {
+	case TypePerson:
+		return &Person{}
+	case TypePublication:
+		return &Publication{}
+	case TypeCorporation:
+		return &Corporation{}
+	case TypeDewey:
+		return &Dewey{}
+	case TypePublisher:
+		return &Publisher{}
+	case TypeSeries:
+		return &Series{}
+	case TypeLiteraryAward:
+		return &LiteraryAward{}
+	default:
+		return nil
+	}
+}
+
 // 1) Abstract/generic/shared types:
 
 type Resource struct {
@@ -110,18 +134,6 @@ type Resource struct {
 	ArchivedAt time.Time
 }
 
-func (r Resource) Document() search.Document {
-	return search.Document{
-		ID:         r.ID,
-		Type:       r.Type.String(),
-		Label:      r.Label,
-		Gain:       1.0,
-		CreatedAt:  r.CreatedAt,
-		UpdatedAt:  r.UpdatedAt,
-		ArchivedAt: r.ArchivedAt,
-	}
-}
-
 // SimpleResource is a minimal representation of a Resource that can be
 // displayed and referenced to (i.e generate a URL/link from type+ID).
 // If ID is empty it is not persisted or intended for persistence.
@@ -200,6 +212,36 @@ func (yr YearRange) Valid() bool {
 		(yr.To == "" || rxpYear.MatchString(string(yr.To)))
 }
 
+// yearRangeFromForm returns the YearRange from the year_range.from,
+// year_range.to and year_range.approx form values.
+func yearRangeFromForm(v url.Values) YearRange {
+	return YearRange{
+		From:   json.Number(strings.TrimSpace(v.Get("year_range.from"))),
+		To:     json.Number(strings.TrimSpace(v.Get("year_range.to"))),
+		Approx: v.Get("year_range.approx") == "on",
+	}
+}
+
+// splitLines splits s into lines, dropping blank lines and surrounding whitespace.
+func splitLines(s string) []string {
+	var res []string
+	for _, line := range strings.Split(s, "\n") {
+		if entry := strings.TrimSpace(line); entry != "" {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// withPrefix returns the given strings with prefix prepended.
+func withPrefix(ss []string, prefix string) []string {
+	var res []string
+	for _, s := range ss {
+		res = append(res, prefix+s)
+	}
+	return res
+}
+
 func (yr YearRange) noLabel() string {
 	var s strings.Builder
 	if yr.Approx {
@@ -327,6 +369,24 @@ type PublisherPublication struct {
 	Year int
 }
 
+// SeriesPublication is a publication in a series, with its number in the
+// series, if the series is numbered.
+type SeriesPublication struct {
+	SimpleResource
+	Number string // as given in the in_series relation, ex "12" or "volume 5"
+	Year   int
+}
+
+// AwardNomination is a nomination of a person or publication for a literary
+// award in a given year, which is either won or not.
+type AwardNomination struct {
+	RelationID int64 // the has_nominee or has_winner relation
+	Award      SimpleResource
+	Nominee    SimpleResource
+	Year       int
+	Won        bool
+}
+
 // 2) Concrete types
 
 type Publication struct {
@@ -365,9 +425,89 @@ type Publication struct {
 	// Ex physical numbers: https://www.akademika.no/liv-koltzow/koltzow-liv/9788203365133
 }
 
+// Label returns the title, subtitle and year of the publication.
+// The main author is not part of the publication data; it is prefixed
+// to the resource label when the publication is ingested.
 // TODO how to get author into the picture?
 func (p Publication) Label() string {
-	return "TODO"
+	label := p.Title
+	if p.Subtitle != "" {
+		label = fmt.Sprintf("%s: %s", label, p.Subtitle)
+	}
+	if p.Year != "" {
+		label = fmt.Sprintf("%s (%s)", label, p.Year)
+	}
+	return label
+}
+
+var (
+	rxpPublicationYear = regexp.MustCompile(`^\d{4}$`)
+	rxpNumPages        = regexp.MustCompile(`^\d{1,4}$`)
+)
+
+// Validate returns a copy of the publication with the properties from the
+// given form values, and reports whether they are valid. Properties which
+// are not part of the form are kept.
+func (p Publication) Validate(v url.Values) (Persistable, bool) {
+	p.Title = strings.TrimSpace(v.Get("title"))
+	p.Subtitle = strings.TrimSpace(v.Get("subtitle"))
+	p.Series = splitLines(v.Get("series"))
+	p.Year = json.Number(strings.TrimSpace(v.Get("year")))
+
+	p.Language = ""
+	if lang := v.Get("language"); lang != "" {
+		p.Language = "iso6393/" + lang
+	}
+	p.LanguagesOther = withPrefix(v["languages_other"], "iso6393/")
+	p.Fiction = v.Get("fiction") == "on"
+	p.Nonfiction = v.Get("nonfiction") == "on"
+	p.GenreForms = splitLines(v.Get("genre_forms"))
+	p.Audiences = v["audiences"]
+
+	p.Binding = ""
+	if b := v.Get("binding"); b != "" {
+		p.Binding = vocab.ParseBinding(b)
+	}
+	p.NumPages = json.Number(strings.TrimSpace(v.Get("numpages")))
+
+	return p, p.Valid()
+}
+
+// Valid reports whether the publication has a title, and whether its year,
+// number of pages, languages and audiences are well-formed.
+func (p Publication) Valid() bool {
+	if p.Title == "" {
+		return false
+	}
+	if p.Year != "" && !rxpPublicationYear.MatchString(string(p.Year)) {
+		return false
+	}
+	if p.NumPages != "" && !rxpNumPages.MatchString(string(p.NumPages)) {
+		return false
+	}
+	if p.Language != "" && !validLanguage(p.Language) {
+		return false
+	}
+	for _, lang := range p.LanguagesOther {
+		if !validLanguage(lang) {
+			return false
+		}
+	}
+	for _, a := range p.Audiences {
+		if _, err := vocab.ParseAudience(a); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// validLanguage reports whether s is a known language, prefixed with "iso6393/".
+func validLanguage(s string) bool {
+	if !strings.HasPrefix(s, "iso6393/") {
+		return false
+	}
+	_, err := iso6393.ParseLanguage(strings.TrimPrefix(s, "iso6393/"))
+	return err == nil
 }
 
 type Publisher struct {
@@ -382,6 +522,22 @@ func (p Publisher) Label() string {
 	return p.Name
 }
 
+// Validate returns a copy of the publisher with the properties from the
+// given form values, and reports whether they are valid.
+func (p Publisher) Validate(v url.Values) (Persistable, bool) {
+	p.Name = strings.TrimSpace(v.Get("name"))
+	p.Description = strings.TrimSpace(v.Get("description"))
+	p.NameVariations = splitLines(v.Get("name_variations"))
+	p.Notes = splitLines(v.Get("notes"))
+	p.YearRange = yearRangeFromForm(v)
+	return p, p.Valid()
+}
+
+// Valid reports whether the publisher has a name and a well-formed year range.
+func (p Publisher) Valid() bool {
+	return p.Name != "" && p.YearRange.Valid()
+}
+
 type Person struct {
 	YearRange      YearRange    `json:"year_range"` // TODO pointer *YearRange?
 	Name           string       `json:"name"`
@@ -400,6 +556,25 @@ func (p Person) Label() string {
 	return p.Name
 }
 
+// Validate returns a copy of the person with the properties from the
+// given form values, and reports whether they are valid. Properties which
+// are not part of the form are kept.
+func (p Person) Validate(v url.Values) (Persistable, bool) {
+	p.Name = strings.TrimSpace(v.Get("name"))
+	p.Description = strings.TrimSpace(v.Get("description"))
+	p.NameVariations = splitLines(v.Get("name_variations"))
+	p.YearRange = yearRangeFromForm(v)
+	p.Gender = vocab.ParseGender(v.Get("gender"))
+	p.Countries = withPrefix(v["countries"], "iso3166/")
+	p.Nationalities = withPrefix(v["nationalities"], "bs/")
+	return p, p.Valid()
+}
+
+// Valid reports whether the person has a name and a well-formed year range.
+func (p Person) Valid() bool {
+	return p.Name != "" && p.YearRange.Valid()
+}
+
 // Corporation TODO rename Organization?
 type Corporation struct {
 	YearRange      YearRange `json:"year_range"`
@@ -419,6 +594,84 @@ func (c Corporation) Label() string {
 	return c.Name
 }
 
+// Validate returns a copy of the corporation with the properties from the
+// given form values, and reports whether they are valid.
+func (c Corporation) Validate(v url.Values) (Persistable, bool) {
+	c.Name = strings.TrimSpace(v.Get("name"))
+	c.Description = strings.TrimSpace(v.Get("description"))
+	c.ParentName = strings.TrimSpace(v.Get("parentName"))
+	c.NameVariations = splitLines(v.Get("name_variations"))
+	c.Notes = splitLines(v.Get("notes"))
+	c.YearRange = yearRangeFromForm(v)
+	return c, c.Valid()
+}
+
+// Valid reports whether the corporation has a name and a well-formed year range.
+func (c Corporation) Valid() bool {
+	return c.Name != "" && c.YearRange.Valid()
+}
+
+// Series is a publisher's series of related publications. The number of each
+// publication in the series is stored on its in_series relation.
+type Series struct {
+	Title    string   `json:"title"`
+	ISSN     string   `json:"issn,omitempty"`
+	Numbered bool     `json:"numbered"` // publications in the series are numbered
+	Notes    []string `json:"notes"`
+}
+
+func (s Series) Label() string {
+	return s.Title
+}
+
+var rxpISSN = regexp.MustCompile(`^\d{4}-\d{3}[\dX]$`)
+
+// Validate returns a copy of the series with the properties from the
+// given form values, and reports whether they are valid.
+func (s Series) Validate(v url.Values) (Persistable, bool) {
+	s.Title = strings.TrimSpace(v.Get("title"))
+	s.ISSN = strings.ToUpper(strings.TrimSpace(v.Get("issn")))
+	s.Numbered = v.Get("numbered") == "on"
+	s.Notes = splitLines(v.Get("notes"))
+	return s, s.Valid()
+}
+
+// Valid reports whether the series has a title and a well-formed ISSN, if any.
+func (s Series) Valid() bool {
+	return s.Title != "" && (s.ISSN == "" || rxpISSN.MatchString(s.ISSN))
+}
+
+// LiteraryAward is a recurring award for persons or publications. The
+// organizer and the nominees and winners of each year are stored as relations
+// from the award.
+type LiteraryAward struct {
+	YearRange      YearRange `json:"year_range"` // years the award has been given
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	NameVariations []string  `json:"name_variations"`
+	Notes          []string  `json:"notes"`
+}
+
+func (a LiteraryAward) Label() string {
+	return a.Name
+}
+
+// Validate returns a copy of the award with the properties from the
+// given form values, and reports whether they are valid.
+func (a LiteraryAward) Validate(v url.Values) (Persistable, bool) {
+	a.Name = strings.TrimSpace(v.Get("name"))
+	a.Description = strings.TrimSpace(v.Get("description"))
+	a.NameVariations = splitLines(v.Get("name_variations"))
+	a.Notes = splitLines(v.Get("notes"))
+	a.YearRange = yearRangeFromForm(v)
+	return a, a.Valid()
+}
+
+// Valid reports whether the award has a name and a well-formed year range.
+func (a LiteraryAward) Valid() bool {
+	return a.Name != "" && a.YearRange.Valid()
+}
+
 // Character is a fictional or mythical person/character.
 // Examples: Ulysses, Apollon, Zevs, Donald Duck, Harry Hole
 type Character struct {
@@ -437,8 +690,237 @@ func (d Dewey) Label() string {
 	return fmt.Sprintf("%s %s", d.Number, d.Name)
 }
 
+// rxpDewey matches Dewey numbers from the schedules, ex: 839.82, and from the
+// add tables, ex: T1--0285, as they are imported from WebDewey.
+var rxpDewey = regexp.MustCompile(`^(\d{3}(\.\d+)?|T\d[ABC]?--\d+(\.\d+)?)$`)
+
+// Validate returns a copy of the Dewey number with the properties from the
+// given form values, and reports whether they are valid.
+func (d Dewey) Validate(v url.Values) (Persistable, bool) {
+	d.Number = strings.TrimSpace(v.Get("number"))
+	d.Name = strings.TrimSpace(v.Get("name"))
+	d.Terms = splitLines(v.Get("terms"))
+	return d, d.Valid()
+}
+
+// Valid reports whether the Dewey number is well-formed and has a name.
+func (d Dewey) Valid() bool {
+	return d.Name != "" && rxpDewey.MatchString(d.Number)
+}
+
 // 3) Circulation: Item, User, Staff etc
 
+// ItemStatus is the circulation status of an Item.
+type ItemStatus string
+
+const (
+	ItemAvailable ItemStatus = "available"
+	ItemOnLoan    ItemStatus = "on_loan"
+	ItemLost      ItemStatus = "lost"
+	ItemMissing   ItemStatus = "missing"
+	ItemWithdrawn ItemStatus = "withdrawn"
+	ItemOnHold    ItemStatus = "on_hold" // on the hold shelf, waiting to be collected
+)
+
+// Item is a physical copy of a Publication, identified by its barcode.
+type Item struct {
+	ID            int64
+	Barcode       string
+	PublicationID string
+	Type          string // book|dvd|audiobook etc
+	Branch        string
+	Shelfmark     string
+	Status        ItemStatus
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Patron is a library user which can borrow items.
+type Patron struct {
+	ID         int64
+	CardNumber string
+	Name       string
+	Email      string
+	Phone      string
+	Category   string // adult|child etc
+	Branch     string // home branch
+	Lang       string // preferred language (BCP 47 tag), used in notices
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Loan represents an Item checked out to a Patron. A Loan is active
+// until it is checked in (CheckinAt is set).
+type Loan struct {
+	ID         int64
+	ItemID     int64
+	PatronID   int64
+	CheckoutAt time.Time
+	DueAt      time.Time
+	CheckinAt  time.Time
+	Renewals   int
+}
+
+// Active reports whether the loan is not yet checked in.
+func (l Loan) Active() bool {
+	return l.CheckinAt.IsZero()
+}
+
+// Overdue reports whether the loan is active and past its due date.
+func (l Loan) Overdue(now time.Time) bool {
+	return l.Active() && now.After(l.DueAt)
+}
+
+// LoanExp is a Loan along with the information needed to display it.
+type LoanExp struct {
+	Loan
+	Barcode     string
+	Publication SimpleResource
+	PatronName  string
+	PatronCard  string
+}
+
+// NoticeStatus is the delivery status of a Notice.
+type NoticeStatus string
+
+const (
+	NoticePending NoticeStatus = "pending"
+	NoticeSent    NoticeStatus = "sent"
+	NoticeFailed  NoticeStatus = "failed"
+)
+
+// Notice is a message to a Patron, such as a reminder of an overdue loan.
+type Notice struct {
+	ID        int64
+	PatronID  int64
+	LoanID    int64 // 0 if notice is not about a loan
+	Type      string
+	Recipient string // email address
+	Subject   string
+	Body      string
+	Status    NoticeStatus
+	Error     string // reason for last failed delivery attempt
+	Attempts  int
+	CreatedAt time.Time
+	SentAt    time.Time
+}
+
+// LedgerType is the type of a transaction in a patron's ledger.
+type LedgerType string
+
+const (
+	LedgerFine    LedgerType = "fine"    // accrued for overdue loans
+	LedgerFee     LedgerType = "fee"     // manually charged, ie. for lost items
+	LedgerPayment LedgerType = "payment" // paid by patron
+	LedgerWaiver  LedgerType = "waiver"  // cancelled by staff
+)
+
+// Credit reports whether the transaction type reduces the patron's balance.
+func (t LedgerType) Credit() bool {
+	return t == LedgerPayment || t == LedgerWaiver
+}
+
+// LedgerEntry is a transaction in a patron's ledger of fines and fees. Amounts
+// are in minor units (øre), positive for fines and fees, negative for payments
+// and waivers.
+type LedgerEntry struct {
+	ID        int64
+	PatronID  int64
+	LoanID    int64 // 0 if not related to a loan
+	Type      LedgerType
+	Amount    int64
+	Note      string
+	CreatedAt time.Time
+	Balance   int64 // running balance after this transaction
+}
+
+// FormatAmount formats the given amount in minor units with two decimals, ie. 1250 as "12.50".
+func FormatAmount(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
+
+// ParseAmount parses the given string as an amount with up to two decimals,
+// using either '.' or ',' as decimal separator, returning it in minor units.
+func ParseAmount(s string) (int64, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > 2 {
+		return 0, Errorf(CodeInvalid, "invalid amount: %q", s)
+	}
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || n < 0 {
+		return 0, Errorf(CodeInvalid, "invalid amount: %q", s)
+	}
+	var m int64
+	if frac != "" {
+		m, err = strconv.ParseInt(frac, 10, 64)
+		if err != nil || m < 0 {
+			return 0, Errorf(CodeInvalid, "invalid amount: %q", s)
+		}
+		if len(frac) == 1 {
+			m *= 10
+		}
+	}
+	return n*100 + m, nil
+}
+
+// HoldStatus is the status of a Hold.
+type HoldStatus string
+
+const (
+	HoldWaiting   HoldStatus = "waiting" // in queue
+	HoldReady     HoldStatus = "ready"   // an item is on the hold shelf, ready for pickup
+	HoldCollected HoldStatus = "collected"
+	HoldExpired   HoldStatus = "expired"
+	HoldCancelled HoldStatus = "cancelled"
+)
+
+// Hold is a Patron's request to borrow a Publication. The holds on a
+// Publication forms a queue, which is resolved in order as items of
+// the Publication are checked in.
+type Hold struct {
+	ID            int64
+	PublicationID string
+	PatronID      int64
+	PickupBranch  string
+	Status        HoldStatus
+	Position      int   // position in queue, only set when Status is waiting
+	ItemID        int64 // the item set aside for the patron, only set when Status is ready or collected
+	CreatedAt     time.Time
+	ReadyAt       time.Time
+	// ExpiresAt is the time a waiting hold is no longer wanted by the patron,
+	// or the pickup deadline of a ready hold.
+	ExpiresAt time.Time
+	ClosedAt  time.Time
+}
+
+// Active reports whether the hold is still waiting or ready for pickup.
+func (h Hold) Active() bool {
+	return h.Status == HoldWaiting || h.Status == HoldReady
+}
+
+// HoldExp is a Hold along with the information needed to display it.
+type HoldExp struct {
+	Hold
+	Publication SimpleResource
+	PatronName  string
+	PatronCard  string
+	Barcode     string // barcode of the item set aside, if any
+}
+
+const (
+	// DefaultHoldExpiry is the how long a hold is kept in queue when
+	// the patron hasn't specified otherwise.
+	DefaultHoldExpiry = 180 * 24 * time.Hour
+	// DefaultPickupPeriod is how long an item is kept on the hold shelf.
+	DefaultPickupPeriod = 7 * 24 * time.Hour
+)
+
 // 4) Various
 
 type Relation struct {
@@ -456,6 +938,27 @@ type RelationExp struct {
 	To    SimpleResource
 }
 
+// Review resolutions.
+const (
+	ReviewLinked    = "linked"    // connected to an existing resource
+	ReviewCreated   = "created"   // connected to a resource created from the review
+	ReviewDismissed = "dismissed" // deleted
+)
+
+// ReviewResolution records how a review, that is a relation without a
+// ToID, was resolved.
+type ReviewResolution struct {
+	ID         int64
+	RelationID int64
+	Type       string
+	Data       map[string]any
+	Action     string // ReviewLinked|ReviewCreated|ReviewDismissed
+	From       SimpleResource
+	To         SimpleResource // empty if dismissed
+	Actor      string         // username of staff user
+	At         time.Time
+}
+
 type ResourceText struct {
 	ID        int64
 	Text      string
@@ -465,6 +968,42 @@ type ResourceText struct {
 	UpdatedAt time.Time
 }
 
+// FieldChange is the change of a single field of a resource, with values
+// as JSON. Old is empty if the field was added, and New is empty if it was removed.
+type FieldChange struct {
+	Old json.RawMessage `json:"old,omitempty"`
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// ResourceDiff is the difference between two versions of a resource. Changes
+// to the resource data are keyed by JSON field name.
+type ResourceDiff struct {
+	Label    *FieldChange           `json:"label,omitempty"`
+	Data     map[string]FieldChange `json:"data,omitempty"`
+	Links    *FieldChange           `json:"links,omitempty"`    // all links before and after, as [type, id] pairs
+	Archived *FieldChange           `json:"archived,omitempty"` // whether the resource is archived, before and after
+
+	// MergedFrom is the ID of the resource merged into this one, and
+	// MergedInto the ID of the resource this one was merged into.
+	MergedFrom string `json:"merged_from,omitempty"`
+	MergedInto string `json:"merged_into,omitempty"`
+}
+
+// Empty reports whether the diff contains no changes.
+func (d ResourceDiff) Empty() bool {
+	return d.Label == nil && len(d.Data) == 0 && d.Links == nil && d.Archived == nil &&
+		d.MergedFrom == "" && d.MergedInto == ""
+}
+
+// ResourceEdit is an entry in the edit history of a resource.
+type ResourceEdit struct {
+	ID         int64
+	ResourceID string
+	At         time.Time
+	Actor      string // username of staff user, or name of job
+	Diff       ResourceDiff
+}
+
 type Image struct {
 	ID     string
 	Type   string // MIME type, but stored without "image/" prefix
@@ -1,6 +1,7 @@
 package sirkulator
 
 import (
+	"net/url"
 	"testing"
 
 	"golang.org/x/text/language"
@@ -91,3 +92,109 @@ func TestYearRange(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		ok    bool
+	}{
+		{"12", 1200, true},
+		{"12.5", 1250, true},
+		{"12,50", 1250, true},
+		{" 0.05 ", 5, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"1.234", 0, false},
+		{"abc", 0, false},
+	}
+	for _, test := range tests {
+		got, err := ParseAmount(test.input)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", test.input, got, err, test.want)
+		}
+		if test.ok && FormatAmount(got) != FormatAmount(test.want) {
+			t.Errorf("FormatAmount(%d) = %q", got, FormatAmount(got))
+		}
+	}
+	if got := FormatAmount(-1205); got != "-12.05" {
+		t.Errorf("FormatAmount(-1205) = %q; want \"-12.05\"", got)
+	}
+}
+
+func TestPublicationLabel(t *testing.T) {
+	tests := []struct {
+		input Publication
+		want  string
+	}{
+		{Publication{Title: "Sult"}, "Sult"},
+		{Publication{Title: "Sult", Year: "1890"}, "Sult (1890)"},
+		{Publication{Title: "Sult", Subtitle: "roman", Year: "1890"}, "Sult: roman (1890)"},
+	}
+	for _, test := range tests {
+		if got := test.input.Label(); got != test.want {
+			t.Errorf("%+v.Label() = %q; want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		data  Persistable
+		form  url.Values
+		valid bool
+		label string
+	}{
+		{Person{}, url.Values{"name": {" Undset, Sigrid "}, "year_range.from": {"1882"}}, true, "Undset, Sigrid (1882–)"},
+		{Person{}, url.Values{"name": {""}}, false, ""},
+		{Person{}, url.Values{"name": {"Undset"}, "year_range.from": {"ca 1882"}}, false, "Undset (ca 1882–)"},
+		{Corporation{}, url.Values{"name": {"Gyldendal"}, "parentName": {"Gyldendal ASA"}}, true, "Gyldendal / Gyldendal ASA"},
+		{Publisher{}, url.Values{"name": {"Aschehoug"}}, true, "Aschehoug"},
+		{Publication{TitleOriginal: "Sult"}, url.Values{"title": {"Sult"}, "year": {"1890"}, "language": {"nob"}}, true, "Sult (1890)"},
+		{Publication{}, url.Values{"title": {"Sult"}, "language": {"xyz"}}, false, "Sult"},
+		{Publication{}, url.Values{"title": {"Sult"}, "audiences": {"TG9999"}}, false, "Sult"},
+		{Publication{}, url.Values{"title": {"Sult"}, "numpages": {"many"}}, false, "Sult"},
+		{Series{}, url.Values{"title": {"Ulvegutten Tal"}, "issn": {"0801-281x"}}, true, "Ulvegutten Tal"},
+		{Series{}, url.Values{"title": {"Ulvegutten Tal"}, "issn": {"0801281"}}, false, "Ulvegutten Tal"},
+		{LiteraryAward{}, url.Values{"name": {"Brageprisen"}, "year_range.from": {"1992"}}, true, "Brageprisen"},
+		{LiteraryAward{}, url.Values{"name": {" "}}, false, ""},
+		{Dewey{}, url.Values{"number": {"839.82"}, "name": {"Norsk litteratur"}}, true, "839.82 Norsk litteratur"},
+		{Dewey{}, url.Values{"number": {"T1--0285"}, "name": {"Databehandling"}}, true, "T1--0285 Databehandling"},
+		{Dewey{}, url.Values{"number": {"83"}, "name": {"Litteratur"}}, false, "83 Litteratur"},
+	}
+	for _, test := range tests {
+		got, valid := test.data.Validate(test.form)
+		if valid != test.valid {
+			t.Errorf("%T.Validate(%v) valid = %v; want %v", test.data, test.form, valid, test.valid)
+		}
+		if got.Label() != test.label {
+			t.Errorf("%T.Validate(%v).Label() = %q; want %q", test.data, test.form, got.Label(), test.label)
+		}
+	}
+
+	// Properties not in the form are kept.
+	got, _ := Publication{TitleOriginal: "Sult"}.Validate(url.Values{"title": {"Hunger"}, "language": {"eng"}})
+	if p := got.(Publication); p.TitleOriginal != "Sult" || p.Language != "iso6393/eng" {
+		t.Errorf("Publication.Validate = %+v; want TitleOriginal and Language set", p)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		data  Persistable
+		valid bool
+	}{
+		{Publication{Title: "Sult", Language: "iso6393/nob", LanguagesOther: []string{"iso6393/eng"}}, true},
+		{Publication{Title: "Sult", Language: "nob"}, false},
+		{Publication{Title: "Sult", Year: "ca 1890"}, false},
+		{Publication{Year: "1890"}, false},
+		{Person{Name: "Undset, Sigrid", YearRange: YearRange{From: "1882", To: "1949"}}, true},
+		{Person{YearRange: YearRange{From: "1882"}}, false},
+		{Series{Title: "Ulvegutten Tal", ISSN: "0801-281x"}, false}, // ISSN is upper-cased by the form only
+	}
+	for _, test := range tests {
+		if got := test.data.Valid(); got != test.valid {
+			t.Errorf("%+v.Valid() = %v; want %v", test.data, got, test.valid)
+		}
+	}
+}
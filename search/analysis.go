@@ -0,0 +1,220 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/char"
+	"github.com/blugelabs/bluge/analysis/lang/en"
+	"github.com/blugelabs/bluge/analysis/lang/no"
+	"github.com/blugelabs/bluge/analysis/token"
+	"github.com/blugelabs/bluge/analysis/tokenizer"
+	"golang.org/x/text/language"
+)
+
+// textAnalysis is the analysis of the text fields of documents, for indexing
+// and querying in a given language.
+type textAnalysis struct {
+	// name identifies the analysis. It is stored with the index, which must
+	// be rebuilt when it changes, so it must be changed whenever any of the
+	// analyzers change.
+	name string
+
+	// fields are the analyzers of each text field. Fields not listed are
+	// analyzed by the standard analyzer.
+	fields map[string]*analysis.Analyzer
+
+	// suggest is the analyzer of the suggest field, and suggestQuery the
+	// analyzer of prefixes looked up in it.
+	suggest      *analysis.Analyzer
+	suggestQuery *analysis.Analyzer
+}
+
+// analysisFor returns the text analysis of the given language, which is
+// either Norwegian or English.
+func analysisFor(lang language.Tag) *textAnalysis {
+	base, _ := lang.Base()
+	switch base.String() {
+	case "no", "nb", "nn":
+		return norwegianAnalysis()
+	default:
+		return englishAnalysis()
+	}
+}
+
+// norwegianAnalysis stems titles and other text by the Norwegian snowball
+// stemmer and removes Norwegian stopwords, while names are only folded.
+func norwegianAnalysis() *textAnalysis {
+	text := &analysis.Analyzer{
+		Tokenizer: tokenizer.NewUnicodeTokenizer(),
+		TokenFilters: []analysis.TokenFilter{
+			token.NewLowerCaseFilter(),
+			scandinavianFoldingFilter{},
+			no.StopWordsFilter(),
+			norwegianIrregularFilter{},
+			no.StemmerFilter(),
+		},
+	}
+	names := &analysis.Analyzer{
+		Tokenizer: tokenizer.NewUnicodeTokenizer(),
+		TokenFilters: []analysis.TokenFilter{
+			token.NewLowerCaseFilter(),
+			scandinavianFoldingFilter{},
+		},
+	}
+	return &textAnalysis{
+		name: "no-1",
+		fields: map[string]*analysis.Analyzer{
+			"label":       text,
+			"name":        text,
+			"series":      text,
+			"contributor": names,
+			"publisher":   names,
+		},
+		suggest: &analysis.Analyzer{
+			Tokenizer: tokenizer.NewUnicodeTokenizer(),
+			TokenFilters: []analysis.TokenFilter{
+				token.NewLowerCaseFilter(),
+				scandinavianFoldingFilter{},
+				token.NewEdgeNgramFilter(token.FRONT, 1, suggestMaxLength),
+			},
+		},
+		suggestQuery: names,
+	}
+}
+
+// englishAnalysis stems titles and other text by the Porter stemmer and
+// removes English stopwords, while names are only folded. All letters with
+// diacritics are folded to ASCII, ex: "Ibsén" and "Ibsen" are the same.
+func englishAnalysis() *textAnalysis {
+	text := en.NewAnalyzer()
+	text.CharFilters = []analysis.CharFilter{char.NewASCIIFoldingFilter()}
+	names := &analysis.Analyzer{
+		CharFilters: []analysis.CharFilter{char.NewASCIIFoldingFilter()},
+		Tokenizer:   tokenizer.NewUnicodeTokenizer(),
+		TokenFilters: []analysis.TokenFilter{
+			token.NewLowerCaseFilter(),
+		},
+	}
+	return &textAnalysis{
+		name: "en-1",
+		fields: map[string]*analysis.Analyzer{
+			"label":       text,
+			"name":        text,
+			"series":      text,
+			"contributor": names,
+			"publisher":   names,
+		},
+		suggest: &analysis.Analyzer{
+			CharFilters: []analysis.CharFilter{char.NewASCIIFoldingFilter()},
+			Tokenizer:   tokenizer.NewUnicodeTokenizer(),
+			TokenFilters: []analysis.TokenFilter{
+				token.NewLowerCaseFilter(),
+				token.NewEdgeNgramFilter(token.FRONT, 1, suggestMaxLength),
+			},
+		},
+		suggestQuery: names,
+	}
+}
+
+// field returns the analyzer of the given text field.
+func (ta *textAnalysis) field(name string) *analysis.Analyzer {
+	if a, ok := ta.fields[name]; ok {
+		return a
+	}
+	return standardAnalyzer
+}
+
+var (
+	standardAnalyzer = &analysis.Analyzer{
+		Tokenizer: tokenizer.NewUnicodeTokenizer(),
+		TokenFilters: []analysis.TokenFilter{
+			token.NewLowerCaseFilter(),
+		},
+	}
+	asciiFolding = char.NewASCIIFoldingFilter()
+)
+
+// scandinavianFoldingFilter folds letters with diacritics to ASCII, except
+// æ, ø and å, which are distinct letters in Norwegian. The Swedish and German
+// ä and ö are folded to æ and ø, and the older spelling aa to å, so that ex:
+// "Aasen" matches "Åsen", and "Ibsén" matches "Ibsen". Tokens must be lowercased.
+type scandinavianFoldingFilter struct{}
+
+func (scandinavianFoldingFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, tok := range input {
+		if isASCII(tok.Term) && !strings.Contains(string(tok.Term), "aa") {
+			continue
+		}
+		var sb strings.Builder
+		for _, r := range string(tok.Term) {
+			switch {
+			case r < utf8.RuneSelf, r == 'æ', r == 'ø', r == 'å':
+				sb.WriteRune(r)
+			case r == 'ä':
+				sb.WriteRune('æ')
+			case r == 'ö':
+				sb.WriteRune('ø')
+			default:
+				sb.Write(asciiFolding.Filter([]byte(string(r))))
+			}
+		}
+		tok.Term = []byte(strings.ReplaceAll(sb.String(), "aa", "å"))
+	}
+	return input
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// norwegianIrregular maps inflected forms of Norwegian nouns, which the
+// stemmer doesn't reduce to the same stem as their base form, to the base form.
+var norwegianIrregular = map[string]string{
+	"bøker":    "bok",
+	"bøkene":   "bok",
+	"føtter":   "fot",
+	"føttene":  "fot",
+	"menn":     "mann",
+	"mennene":  "mann",
+	"bønder":   "bonde",
+	"bøndene":  "bonde",
+	"brødre":   "bror",
+	"brødrene": "bror",
+	"døtre":    "datter",
+	"døtrene":  "datter",
+	"mødre":    "mor",
+	"mødrene":  "mor",
+	"fedre":    "far",
+	"fedrene":  "far",
+	"hender":   "hånd",
+	"hendene":  "hånd",
+	"netter":   "natt",
+	"nettene":  "natt",
+	"tenner":   "tann",
+	"tennene":  "tann",
+	"røtter":   "rot",
+	"røttene":  "rot",
+	"strender": "strand",
+	"gjess":    "gås",
+	"gjessene": "gås",
+}
+
+// norwegianIrregularFilter replaces irregular forms of nouns by their base
+// form. Tokens must be lowercased.
+type norwegianIrregularFilter struct{}
+
+func (norwegianIrregularFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, tok := range input {
+		if base, ok := norwegianIrregular[string(tok.Term)]; ok {
+			tok.Term = []byte(base)
+		}
+	}
+	return input
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// facets are the fields which search results are counted by, in the order
+// they are returned in Results.
+var facets = []string{"type", "published", "language", "audience", "content", "status"}
+
+// maxFacetValues is the maximum number of values counted for a term facet.
+const maxFacetValues = 10
+
+// yearRanges are the ranges of publication years counted, as from..to.
+var yearRanges = []string{"..1899", "1900..1949", "1950..1999", "2000..2009", "2010..2019", "2020.."}
+
+func isFacet(field string) bool {
+	for _, f := range facets {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Facet is the number of matching documents by the values of a field.
+type Facet struct {
+	Field  string
+	Values []FacetValue
+}
+
+// FacetValue is the number of documents with a value, or for numeric fields,
+// a range of values on the form from..to.
+type FacetValue struct {
+	Value  string
+	Count  uint64
+	Active bool // the value is a filter of the query
+}
+
+// Filter returns the filter matching the value, which can be given in
+// QueryOptions.Filters.
+func (f Facet) Filter(v FacetValue) string {
+	return f.Field + ":" + v.Value
+}
+
+// addAggregations adds the aggregations needed to count the facets to req.
+// The status facet is only counted when archived documents are included,
+// since they all are active otherwise.
+func addAggregations(req *bluge.TopNSearch, opt QueryOptions) {
+	for _, field := range facets {
+		switch {
+		case field == "status" && !opt.InclArchived:
+			continue
+		case fields[field] == numericField:
+			agg := aggregations.Ranges(search.Field(field))
+			for _, r := range yearRanges {
+				min, max, _ := parseRange(r)
+				if max != bluge.MaxNumeric {
+					max++ // range aggregations exclude the upper bound
+				}
+				agg.AddRange(aggregations.NamedRange(r, min, max))
+			}
+			req.Aggregations().Add(field, agg)
+		default:
+			req.Aggregations().Add(field, aggregations.NewTermsAggregation(search.Field(field), maxFacetValues))
+		}
+	}
+}
+
+// readFacets returns the facets counted by the aggregations of a search,
+// leaving out values without any matching documents, unless they are
+// filtered on.
+func readFacets(aggs *search.Bucket, filters []string) []Facet {
+	active := make(map[string]bool, len(filters))
+	for _, f := range filters {
+		active[f] = true
+	}
+	var res []Facet
+	for _, field := range facets {
+		if aggs.Aggregation(field) == nil {
+			continue
+		}
+		facet := Facet{Field: field}
+		seen := make(map[string]bool)
+		for _, b := range aggs.Buckets(field) {
+			v := FacetValue{Value: b.Name(), Count: b.Count()}
+			v.Active = active[facet.Filter(v)]
+			seen[v.Value] = true
+			if v.Count > 0 || v.Active {
+				facet.Values = append(facet.Values, v)
+			}
+		}
+		for _, f := range filters {
+			if value := strings.TrimPrefix(f, field+":"); value != f && !seen[value] {
+				facet.Values = append(facet.Values, FacetValue{Value: value, Active: true})
+			}
+		}
+		if fields[field] != numericField {
+			// Order by count, and by value when equal, as bluge doesn't.
+			sort.SliceStable(facet.Values, func(i, j int) bool {
+				a, b := facet.Values[i], facet.Values[j]
+				return a.Count > b.Count || (a.Count == b.Count && a.Value < b.Value)
+			})
+		}
+		if len(facet.Values) > 0 {
+			res = append(res, facet)
+		}
+	}
+	return res
+}
+
+// filterQuery returns the query of the given filters, each on the form
+// field:value. Filters on the same field are alternatives, so that any of
+// them must match, while filters on different fields all must match.
+func filterQuery(filters []string, ta *textAnalysis) (bluge.Query, error) {
+	var (
+		byField = make(map[string][]bluge.Query)
+		order   []string
+	)
+	for _, f := range filters {
+		field, _, ok := strings.Cut(f, ":")
+		if _, known := fields[field]; !ok || !known {
+			return nil, fmt.Errorf("%w: unknown filter %s", ErrInvalidQuery, f)
+		}
+		queries, err := parseQuery(f, ta)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := byField[field]; !ok {
+			order = append(order, field)
+		}
+		byField[field] = append(byField[field], queries...)
+	}
+	q := bluge.NewBooleanQuery()
+	for _, field := range order {
+		q.AddMust(bluge.NewBooleanQuery().AddShould(byField[field]...).SetMinShould(1))
+	}
+	return q, nil
+}
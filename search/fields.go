@@ -0,0 +1,297 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/blugelabs/bluge"
+	"github.com/knakk/sirkulator/isbn"
+)
+
+type fieldKind int
+
+const (
+	textField    fieldKind = iota // analyzed, for names and titles
+	keywordField                  // matched exactly, for identifiers and codes
+	numericField                  // for years, which can be queried by range
+)
+
+// fields are the document fields which can be queried as field:value,
+// in addition to the label, which is queried by terms without a field.
+var fields = map[string]fieldKind{
+	"id":          keywordField, // any identifier (link) of the resource
+	"type":        keywordField,
+	"status":      keywordField, // active or archived
+	"isbn":        keywordField,
+	"issn":        keywordField,
+	"name":        textField, // names, name variations and titles
+	"contributor": textField,
+	"publisher":   textField,
+	"series":      textField,
+	"year":        numericField,
+	"language":    keywordField,
+	"dewey":       keywordField,
+	"published":   numericField, // year of publication
+	"audience":    keywordField,
+	"content":     keywordField, // fiction or nonfiction
+}
+
+// dataFields maps the JSON data of each resource type to document fields,
+// as pairs of field and dot-separated path. Arrays are indexed by each element,
+// and booleans by the last key of the path, if true.
+var dataFields = map[string][][2]string{
+	"publication": {
+		{"name", "title"},
+		{"name", "subtitle"},
+		{"name", "title_original"},
+		{"year", "year"},
+		{"year", "year_first"},
+		{"published", "year"},
+		{"language", "language"},
+		{"language", "languages_other"},
+		{"audience", "audiences"},
+		{"content", "fiction"},
+		{"content", "nonfiction"},
+	},
+	"person": {
+		{"name", "name"},
+		{"name", "name_variations"},
+		{"year", "year_range.from"},
+		{"year", "year_range.to"},
+	},
+	"corporation": {
+		{"name", "name"},
+		{"name", "name_variations"},
+		{"year", "year_range.from"},
+		{"year", "year_range.to"},
+	},
+	"publisher": {
+		{"name", "name"},
+		{"name", "name_variations"},
+		{"year", "year_range.from"},
+		{"year", "year_range.to"},
+	},
+	"literary_award": {
+		{"name", "name"},
+		{"name", "name_variations"},
+		{"year", "year_range.from"},
+		{"year", "year_range.to"},
+	},
+	"series": {
+		{"name", "title"},
+		{"issn", "issn"},
+	},
+	"dewey": {
+		{"dewey", "number"},
+		{"name", "name"},
+		{"name", "terms"},
+	},
+}
+
+// relationFields maps relations from a resource to document fields, which
+// are given the label of the resource related to, or for classifications,
+// its ID (the Dewey number).
+var relationFields = map[string]string{
+	"has_contributor":    "contributor",
+	"published_by":       "publisher",
+	"in_series":          "series",
+	"has_classification": "dewey",
+}
+
+// normalize returns the value as indexed and queried in the given field.
+func normalize(field, value string) string {
+	switch field {
+	case "isbn", "issn":
+		return strings.ToLower(isbn.Clean(value))
+	case "language":
+		// Languages are stored with vocabulary prefix, ex: "iso6393/nob"
+		return strings.ToLower(value[strings.LastIndex(value, "/")+1:])
+	default:
+		return strings.ToLower(value)
+	}
+}
+
+// jsonValues returns the values at the given dot-separated path of v, as strings.
+func jsonValues(v any, path string) []string {
+	key, rest, _ := strings.Cut(path, ".")
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	v = m[key]
+	if rest != "" {
+		return jsonValues(v, rest)
+	}
+	var res []string
+	switch t := v.(type) {
+	case string:
+		if t != "" {
+			res = append(res, t)
+		}
+	case float64:
+		res = append(res, strconv.FormatFloat(t, 'f', -1, 64))
+	case bool:
+		if t {
+			res = append(res, key)
+		}
+	case []any:
+		for _, e := range t {
+			if s, ok := e.(string); ok && s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+const documentColumns = "id, type, label, gain, data, created_at, updated_at, archived_at"
+
+// readDocument reads a document and the JSON data of its resource from the
+// given statement, selecting documentColumns from the resource table, starting
+// at column i.
+func readDocument(stmt *sqlite.Stmt, i int) (Document, []byte) {
+	doc := Document{
+		ID:        stmt.ColumnText(i),
+		Type:      stmt.ColumnText(i + 1),
+		Label:     stmt.ColumnText(i + 2),
+		Gain:      stmt.ColumnFloat(i + 3),
+		CreatedAt: time.Unix(stmt.ColumnInt64(i+5), 0),
+		UpdatedAt: time.Unix(stmt.ColumnInt64(i+6), 0),
+	}
+	if archived := stmt.ColumnInt64(i + 7); archived != 0 {
+		doc.ArchivedAt = time.Unix(archived, 0)
+	}
+	return doc, []byte(stmt.ColumnText(i + 4))
+}
+
+// addFields adds the fields of the document from the JSON data, links and
+// relations of its resource.
+func addFields(conn *sqlite.Conn, doc *Document, data []byte) error {
+	if mappings := dataFields[doc.Type]; len(mappings) > 0 {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("search: decoding data of %s: %w", doc.ID, err)
+		}
+		for _, m := range mappings {
+			for _, val := range jsonValues(v, m[1]) {
+				doc.Fields = append(doc.Fields, [2]string{m[0], val})
+			}
+		}
+	}
+
+	fn := func(stmt *sqlite.Stmt) error {
+		typ, id := stmt.ColumnText(0), stmt.ColumnText(1)
+		doc.Fields = append(doc.Fields, [2]string{"id", id})
+		if typ == "isbn" || typ == "issn" {
+			doc.Fields = append(doc.Fields, [2]string{typ, id})
+		}
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT type, id FROM link WHERE resource_id=?", fn, doc.ID); err != nil {
+		return fmt.Errorf("search: links of %s: %w", doc.ID, err)
+	}
+
+	fn = func(stmt *sqlite.Stmt) error {
+		field := relationFields[stmt.ColumnText(0)]
+		val := stmt.ColumnText(2)
+		if field == "dewey" {
+			val = stmt.ColumnText(1)
+		}
+		if field != "" && val != "" {
+			doc.Fields = append(doc.Fields, [2]string{field, val})
+		}
+		return nil
+	}
+	const q = `
+		SELECT rel.type, coalesce(rel.to_id, ''), coalesce(r.label, json_extract(rel.data, '$.label'), '')
+		  FROM relation rel
+		  LEFT JOIN resource r ON (r.id=rel.to_id)
+		 WHERE rel.from_id=?`
+	if err := sqlitex.Exec(conn, q, fn, doc.ID); err != nil {
+		return fmt.Errorf("search: relations of %s: %w", doc.ID, err)
+	}
+	return nil
+}
+
+// Documents returns the documents to index for the resources with the given
+// IDs, with fields from their data, links and relations. Resources which
+// don't exist are left out.
+func Documents(conn *sqlite.Conn, ids ...string) ([]Document, error) {
+	docs := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		var (
+			doc   Document
+			data  []byte
+			found bool
+		)
+		fn := func(stmt *sqlite.Stmt) error {
+			doc, data = readDocument(stmt, 0)
+			found = true
+			return nil
+		}
+		if err := sqlitex.Exec(conn, "SELECT "+documentColumns+" FROM resource WHERE id=?", fn, id); err != nil {
+			return docs, fmt.Errorf("search: Documents: %w", err)
+		}
+		if !found {
+			continue
+		}
+		if err := addFields(conn, &doc, data); err != nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// blugeDocument returns the document as stored in the index, with text
+// fields analyzed by the given analysis.
+func blugeDocument(doc Document, ta *textAnalysis) *bluge.Document {
+	status := "active"
+	if !doc.ArchivedAt.IsZero() {
+		status = "archived"
+	}
+	d := bluge.NewDocument(doc.ID).
+		AddField(bluge.NewKeywordField("type", doc.Type).StoreValue().Aggregatable()).
+		AddField(bluge.NewKeywordField("status", status).Aggregatable()).
+		AddField(bluge.NewTextField("label", doc.Label).WithAnalyzer(ta.field("label")).SearchTermPositions().StoreValue()).
+		AddField(bluge.NewTextField("suggest", doc.Label).WithAnalyzer(ta.suggest)).
+		AddField(bluge.NewDateTimeField("created", doc.CreatedAt).StoreValue()).
+		AddField(bluge.NewDateTimeField("updated", doc.UpdatedAt).StoreValue()).
+		AddField(bluge.NewNumericField("gain", doc.Gain)) // TODO https://github.com/mschoch/bluge-custom-score
+	if !doc.ArchivedAt.IsZero() {
+		d.AddField(bluge.NewKeywordField("flags", "archived"))
+	}
+	for _, f := range doc.Fields {
+		kind, ok := fields[f[0]]
+		if !ok {
+			continue
+		}
+		switch kind {
+		case textField:
+			d.AddField(bluge.NewTextField(f[0], f[1]).WithAnalyzer(ta.field(f[0])))
+			if f[0] == "name" {
+				d.AddField(bluge.NewTextField("suggest", f[1]).WithAnalyzer(ta.suggest))
+			}
+		case keywordField:
+			field := bluge.NewKeywordField(f[0], normalize(f[0], f[1]))
+			if isFacet(f[0]) {
+				field.Aggregatable()
+			}
+			d.AddField(field)
+		case numericField:
+			if n, err := strconv.ParseFloat(f[1], 64); err == nil {
+				field := bluge.NewNumericField(f[0], n)
+				if isFacet(f[0]) {
+					field.Aggregatable()
+				}
+				d.AddField(field)
+			}
+		}
+	}
+	return d
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"sync"
-	"time"
 
 	"crawshaw.io/sqlite"
 	"crawshaw.io/sqlite/sqlitex"
@@ -33,39 +32,29 @@ func (i *Indexer) Run(ctx context.Context, w io.Writer) error {
 	var rowid int64
 	hasMore := true
 	q := `
-		SELECT
-			rowid,
-			id,
-			type,
-			label,
-			gain,
-			created_at,
-			updated_at,
-			archived_at
+		SELECT rowid, ` + documentColumns + `
 		FROM resource
 		WHERE rowid > ?
 		ORDER BY rowid ASC
 		LIMIT ?`
 
+	// firstErr is the first error storing a batch, which are stored concurrently.
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+
 	docs := make([]Document, 0, i.BatchSize)
+	var data [][]byte
 	stats := make(map[string]int)
 
 	fn := func(stmt *sqlite.Stmt) error {
 		hasMore = true
 		rowid = stmt.ColumnInt64(0)
 
-		var doc Document
-		doc.ID = stmt.ColumnText(1)
-		doc.Type = stmt.ColumnText(2)
-		doc.Label = stmt.ColumnText(3)
-		doc.Gain = stmt.ColumnFloat(4)
-		doc.CreatedAt = time.Unix(stmt.ColumnInt64(5), 0)
-		doc.CreatedAt = time.Unix(stmt.ColumnInt64(6), 0)
-		if archived := stmt.ColumnInt64(7); archived != 0 {
-			doc.ArchivedAt = time.Unix(archived, 0)
-		}
-
+		doc, d := readDocument(stmt, 1)
 		docs = append(docs, doc)
+		data = append(data, d)
 		stats[doc.Type]++
 
 		return nil
@@ -80,19 +69,38 @@ func (i *Indexer) Run(ctx context.Context, w io.Writer) error {
 		}
 
 		if len(docs) > 0 {
+			for j := range docs {
+				if err := addFields(conn, &docs[j], data[j]); err != nil {
+					return err
+				}
+			}
 			fmt.Fprint(w, ".")
 			i.wg.Add(1)
-			d := make([]Document, 0, len(docs))
+			d := make([]Document, len(docs))
 			copy(d, docs)
 			go func() {
-				i.Idx.batchStore(d)
-				i.wg.Done()
+				defer i.wg.Done()
+				if err := i.Idx.batchStore(d); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
 			}()
 			docs = docs[:0]
+			data = data[:0]
 		}
 	}
 
 	i.wg.Wait()
+	if firstErr != nil {
+		// The index is left stale, so that it is rebuilt again.
+		return firstErr
+	}
+	if err := i.Idx.built(); err != nil {
+		return err
+	}
 
 	total := 0
 
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/knakk/sirkulator/isbn"
+)
+
+// ErrInvalidQuery is returned by Index.Search when the query cannot be parsed.
+var ErrInvalidQuery = errors.New("search: invalid query")
+
+// splitQuery splits the query into terms separated by space, keeping quoted
+// strings together, ex: `contributor:"knut hamsun" sult` is split into
+// `contributor:knut hamsun` and `sult`.
+func splitQuery(q string) (terms []string) {
+	var sb strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if sb.Len() > 0 {
+				terms = append(terms, sb.String())
+				sb.Reset()
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() > 0 {
+		terms = append(terms, sb.String())
+	}
+	return terms
+}
+
+// parseQuery returns the queries of each term of q, which all must match.
+// Text is analyzed by the given analysis; terms which are left without any
+// tokens, such as stopwords, are ignored.
+func parseQuery(q string, ta *textAnalysis) ([]bluge.Query, error) {
+	var queries []bluge.Query
+	for _, term := range splitQuery(q) {
+		field, value, found := strings.Cut(term, ":")
+		kind, ok := fields[field]
+		if !found || !ok || value == "" {
+			if len(ta.field("label").Analyze([]byte(term))) > 0 {
+				queries = append(queries, termQuery(term, ta))
+			}
+			continue
+		}
+		switch kind {
+		case textField:
+			if len(ta.field(field).Analyze([]byte(value))) == 0 {
+				continue
+			}
+			queries = append(queries, bluge.NewMatchQuery(value).
+				SetField(field).
+				SetAnalyzer(ta.field(field)).
+				SetFuzziness(1).
+				SetOperator(bluge.MatchQueryOperatorAnd))
+		case keywordField:
+			value = normalize(field, value)
+			if prefix := strings.TrimSuffix(value, "*"); prefix != value {
+				queries = append(queries, bluge.NewPrefixQuery(prefix).SetField(field))
+			} else {
+				queries = append(queries, bluge.NewTermQuery(value).SetField(field))
+			}
+		case numericField:
+			min, max, ok := parseRange(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s is not a number or range", ErrInvalidQuery, term)
+			}
+			queries = append(queries, bluge.NewNumericRangeInclusiveQuery(min, max, true, true).SetField(field))
+		}
+	}
+	return queries, nil
+}
+
+// termQuery returns the query of a term without a field, which matches the
+// label or names of a document. Numbers, including hyphenated ISBNs, can
+// also match identifiers.
+func termQuery(term string, ta *textAnalysis) bluge.Query {
+	if isNumber(isbn.Clean(term)) {
+		return bluge.NewBooleanQuery().
+			AddShould(bluge.NewMatchQuery(term).SetField("label").SetAnalyzer(ta.field("label"))).
+			AddShould(bluge.NewTermQuery(isbn.Clean(term)).SetField("id")).
+			SetMinShould(1)
+	}
+	return bluge.NewBooleanQuery().
+		AddShould(bluge.NewMatchQuery(term).SetField("label").SetAnalyzer(ta.field("label")).SetFuzziness(1)).
+		AddShould(bluge.NewMatchQuery(term).SetField("name").SetAnalyzer(ta.field("name")).SetFuzziness(1)).
+		SetMinShould(1)
+}
+
+// parseRange parses a number, or an inclusive range of numbers on the form
+// from..to, where either end can be left out, and reports whether it is valid.
+func parseRange(s string) (min, max float64, ok bool) {
+	from, to, isRange := strings.Cut(s, "..")
+	if !isRange {
+		n, err := strconv.ParseFloat(s, 64)
+		return n, n, err == nil
+	}
+	if from == "" && to == "" {
+		return 0, 0, false
+	}
+	min, max = bluge.MinNumeric, bluge.MaxNumeric
+	var err error
+	if from != "" {
+		if min, err = strconv.ParseFloat(from, 64); err != nil {
+			return min, max, false
+		}
+	}
+	if to != "" {
+		if max, err = strconv.ParseFloat(to, 64); err != nil {
+			return min, max, false
+		}
+	}
+	return min, max, true
+}
@@ -0,0 +1,210 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+// QueueIndexer keeps the index up to date with changes to resources, by
+// draining the index queue, which is written by database triggers in the same
+// transaction as the changes. Resources which fail to be indexed stay in the
+// queue, and are retried with increasing delay.
+type QueueIndexer struct {
+	DB        *sqlitex.Pool
+	Idx       *Index
+	Interval  time.Duration // between polls of the queue (default 1s)
+	BatchSize int           // maximum number of resources indexed at once (default 100)
+}
+
+const (
+	retryDelay    = 10 * time.Second // delay before the first retry, doubled on each attempt
+	maxRetryDelay = time.Hour
+)
+
+// Run drains the queue until the context is canceled.
+func (q *QueueIndexer) Run(ctx context.Context) {
+	interval := q.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := q.Drain(ctx)
+		if err != nil {
+			log.Printf("search: QueueIndexer: %v", err)
+		}
+		if err == nil && n == q.batchSize() {
+			continue // there may be more due
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *QueueIndexer) batchSize() int {
+	if q.BatchSize == 0 {
+		return 100
+	}
+	return q.BatchSize
+}
+
+type queued struct {
+	id       string
+	version  int64
+	attempts int
+}
+
+// Drain indexes a batch of the queued resources which are due, and returns
+// the number of resources attempted. Resources which no longer exist are
+// deleted from the index. The returned error is only about the queue itself;
+// errors indexing resources are recorded in the queue.
+func (q *QueueIndexer) Drain(ctx context.Context) (int, error) {
+	conn := q.DB.Get(ctx)
+	if conn == nil {
+		return 0, context.Canceled
+	}
+	defer q.DB.Put(conn)
+
+	var batch []queued
+	fn := func(stmt *sqlite.Stmt) error {
+		batch = append(batch, queued{
+			id:       stmt.ColumnText(0),
+			version:  stmt.ColumnInt64(1),
+			attempts: stmt.ColumnInt(2),
+		})
+		return nil
+	}
+	const sel = `
+		SELECT resource_id, version, attempts FROM index_queue
+		 WHERE next_at <= ?
+		 ORDER BY queued_at
+		 LIMIT ?`
+	if err := sqlitex.Exec(conn, sel, fn, time.Now().Unix(), q.batchSize()); err != nil {
+		return 0, fmt.Errorf("reading queue: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(batch))
+	for _, b := range batch {
+		ids = append(ids, b.id)
+	}
+	if err := q.index(conn, ids...); err == nil {
+		for _, b := range batch {
+			if err := dequeue(conn, b); err != nil {
+				return len(batch), err
+			}
+		}
+		return len(batch), nil
+	}
+
+	// Index one by one, so that a failing resource doesn't hold back the others.
+	for _, b := range batch {
+		if err := q.index(conn, b.id); err != nil {
+			if err := requeue(conn, b, err); err != nil {
+				return len(batch), err
+			}
+			continue
+		}
+		if err := dequeue(conn, b); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// index stores the documents of the resources with the given IDs, and
+// deletes those which don't exist from the index.
+func (q *QueueIndexer) index(conn *sqlite.Conn, ids ...string) error {
+	docs, err := Documents(conn, ids...)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(docs))
+	for _, d := range docs {
+		found[d.ID] = true
+	}
+	var deleted []string
+	for _, id := range ids {
+		if !found[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	if err := q.Idx.Delete(deleted...); err != nil {
+		return err
+	}
+	return q.Idx.Store(docs...)
+}
+
+// dequeue removes the resource from the queue, unless it has changed since
+// it was read from the queue.
+func dequeue(conn *sqlite.Conn, b queued) error {
+	const del = "DELETE FROM index_queue WHERE resource_id=? AND version=?"
+	if err := sqlitex.Exec(conn, del, nil, b.id, b.version); err != nil {
+		return fmt.Errorf("dequeuing %s: %w", b.id, err)
+	}
+	return nil
+}
+
+// requeue records the failed attempt of indexing the resource, and delays
+// the next attempt.
+func requeue(conn *sqlite.Conn, b queued, indexErr error) error {
+	delay := retryDelay << b.attempts
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	const upd = `
+		UPDATE index_queue
+		   SET attempts=attempts+1, next_at=?, error=?
+		 WHERE resource_id=? AND version=?`
+	if err := sqlitex.Exec(conn, upd, nil, time.Now().Add(delay).Unix(), indexErr.Error(), b.id, b.version); err != nil {
+		return fmt.Errorf("requeuing %s: %w", b.id, err)
+	}
+	return nil
+}
+
+// QueueStatus is the status of the index queue.
+type QueueStatus struct {
+	Queued    int           // number of resources waiting to be indexed
+	Failing   int           // number of resources which failed to be indexed, waiting to be retried
+	Lag       time.Duration // since the oldest change not yet indexed
+	LastError string        // error of the most recent failed attempt
+}
+
+// GetQueueStatus returns the status of the index queue.
+func GetQueueStatus(conn *sqlite.Conn) (QueueStatus, error) {
+	var res QueueStatus
+	fn := func(stmt *sqlite.Stmt) error {
+		res.Queued = stmt.ColumnInt(0)
+		res.Failing = stmt.ColumnInt(1)
+		if oldest := stmt.ColumnInt64(2); oldest > 0 {
+			res.Lag = time.Since(time.Unix(oldest, 0)).Truncate(time.Second)
+		}
+		return nil
+	}
+	const q = `
+		SELECT count(*), count(error), coalesce(min(queued_at), 0)
+		  FROM index_queue`
+	if err := sqlitex.Exec(conn, q, fn); err != nil {
+		return res, fmt.Errorf("search: GetQueueStatus: %w", err)
+	}
+	const qErr = "SELECT error FROM index_queue WHERE error IS NOT NULL ORDER BY next_at DESC LIMIT 1"
+	fn = func(stmt *sqlite.Stmt) error {
+		res.LastError = stmt.ColumnText(0)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, qErr, fn); err != nil {
+		return res, fmt.Errorf("search: GetQueueStatus: %w", err)
+	}
+	return res, nil
+}
@@ -2,12 +2,17 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"golang.org/x/text/language"
 )
 
 // Document represents a indexable document, or a document retrieved
@@ -48,13 +53,35 @@ func (d Document) GetAllOfField(key string) (res []string) {
 
 // Index represents a search index that can index and query Documents.
 type Index struct {
-	writer *bluge.Writer
+	writer   *bluge.Writer
+	analysis *textAnalysis
+	dir      string // empty for in-memory indexes
+	stale    bool
 }
 
+// analysisFile is the file in the index directory storing the name of the
+// text analysis the index was built with.
+const analysisFile = "sirkulator-analysis"
+
 // Open creates the Index on disk and make it ready for indexing and querying.
-func Open(dir string) (*Index, error) {
-	// Create directory if it doesn't exist.
+// Text is analyzed according to the given language. If the index was built
+// with another analysis, or the build didn't complete, it is emptied and
+// must be rebuilt, as reported by Stale.
+func Open(dir string, lang language.Tag) (*Index, error) {
 	dir = strings.TrimSuffix(dir, "/")
+	ta := analysisFor(lang)
+	b, err := os.ReadFile(filepath.Join(dir, analysisFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("search: Open(%s): %w", dir, err)
+	}
+	stale := string(b) != ta.name
+	if stale {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("search: Open(%s): %w", dir, err)
+		}
+	}
+
+	// Create directory if it doesn't exist.
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 		return nil, fmt.Errorf("search: Open(%s): MkdirAll: %w", dir, err)
 	}
@@ -65,17 +92,36 @@ func Open(dir string) (*Index, error) {
 		return nil, fmt.Errorf("search: Open(%s): %w", dir, err)
 	}
 
-	return &Index{writer: w}, nil
+	return &Index{writer: w, analysis: ta, dir: dir, stale: stale}, nil
 }
 
 // OpenMem creates an in-memory index usefull for testing
-func OpenMem() (*Index, error) {
+func OpenMem(lang language.Tag) (*Index, error) {
 	w, err := bluge.OpenWriter(bluge.InMemoryOnlyConfig())
 	if err != nil {
 		return nil, fmt.Errorf("search: OpenMem: %w", err)
 	}
 
-	return &Index{writer: w}, nil
+	return &Index{writer: w, analysis: analysisFor(lang)}, nil
+}
+
+// Stale reports whether the index must be rebuilt by an Indexer, because
+// it was built with another text analysis than it was opened with, or the
+// build didn't complete.
+func (idx *Index) Stale() bool {
+	return idx.stale
+}
+
+// built records that the index is completely built with its text analysis.
+func (idx *Index) built() error {
+	idx.stale = false
+	if idx.dir == "" {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(idx.dir, analysisFile), []byte(idx.analysis.name), 0o644); err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	return nil
 }
 
 func (idx *Index) Close() error {
@@ -91,15 +137,7 @@ func (idx *Index) Store(docs ...Document) error {
 		return idx.batchStore(docs)
 	}
 	for _, doc := range docs {
-		d := bluge.NewDocument(doc.ID).
-			AddField(bluge.NewTextField("type", doc.Type).SearchTermPositions().StoreValue()).
-			AddField(bluge.NewTextField("label", doc.Label).SearchTermPositions().StoreValue()).
-			AddField(bluge.NewDateTimeField("created", doc.CreatedAt).StoreValue()).
-			AddField(bluge.NewDateTimeField("updated", doc.UpdatedAt).StoreValue()).
-			AddField(bluge.NewNumericField("gain", doc.Gain)) // TODO https://github.com/mschoch/bluge-custom-score
-		if !doc.ArchivedAt.IsZero() {
-			d.AddField(bluge.NewKeywordField("flags", "archived"))
-		}
+		d := blugeDocument(doc, idx.analysis)
 		if err := idx.writer.Update(d.ID(), d); err != nil {
 			return fmt.Errorf("search: Index.Store: writing doc %s: %w", d.ID(), err)
 		}
@@ -113,15 +151,7 @@ func (idx Index) batchStore(docs []Document) error {
 
 	// TODO verify docs does not contain duplicate IDs?
 	for _, doc := range docs {
-		d := bluge.NewDocument(doc.ID).
-			AddField(bluge.NewTextField("type", doc.Type).SearchTermPositions().StoreValue()).
-			AddField(bluge.NewTextField("label", doc.Label).SearchTermPositions().StoreValue()).
-			AddField(bluge.NewDateTimeField("created", doc.CreatedAt).StoreValue()).
-			AddField(bluge.NewDateTimeField("updated", doc.UpdatedAt).StoreValue()).
-			AddField(bluge.NewNumericField("gain", doc.Gain)) // TODO https://github.com/mschoch/bluge-custom-score
-		if !doc.ArchivedAt.IsZero() {
-			d.AddField(bluge.NewKeywordField("flags", "archived"))
-		}
+		d := blugeDocument(doc, idx.analysis)
 		batch.Update(d.ID(), d)
 	}
 
@@ -131,12 +161,28 @@ func (idx Index) batchStore(docs []Document) error {
 	return nil
 }
 
+// Delete removes the documents with the given IDs from the index.
+func (idx *Index) Delete(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	batch := bluge.NewBatch()
+	for _, id := range ids {
+		batch.Delete(bluge.Identifier(id))
+	}
+	if err := idx.writer.Batch(batch); err != nil {
+		return fmt.Errorf("search: Index.Delete: %w", err)
+	}
+	return nil
+}
+
 type QueryOptions struct {
 	Type         string
 	SortBy       string
 	SortDir      string
 	Limit        int
 	InclArchived bool
+	Filters      []string // field:value, see Search
 }
 
 func isNumber(s string) bool {
@@ -151,20 +197,29 @@ func isNumber(s string) bool {
 	return true
 }
 
+// Search searches the index for documents matching the query, which is
+// made up of terms separated by space. A term without a field matches the
+// label or name of the document, while a term on the form field:value matches
+// the given field. Values with spaces can be quoted. Keyword fields, such as
+// identifiers, can be prefixed matched with a trailing *, and numeric fields,
+// such as year, can be queried by range. Examples:
+//
+//	hamsun sult
+//	isbn:978-82-05-12345-6
+//	contributor:"knut hamsun" year:1890..1900
+//	dewey:839* language:nob year:..1950
+//
+// The results are counted by facets, such as type and language, whose values
+// can be given as filters in the query options. Filters on the same field are
+// alternatives, while filters on different fields all must match.
 func (idx *Index) Search(ctx context.Context, q string, opt QueryOptions) (Results, error) {
 	res := Results{}
-	terms := strings.Split(q, " ")
-	var queries []bluge.Query
-	if q == "" {
+	queries, err := parseQuery(q, idx.analysis)
+	if err != nil {
+		return res, err
+	}
+	if len(queries) == 0 {
 		queries = []bluge.Query{bluge.NewMatchAllQuery()}
-	} else {
-		for _, term := range terms {
-			if isNumber(term) {
-				queries = append(queries, bluge.NewMatchQuery(term).SetField("label"))
-			} else {
-				queries = append(queries, bluge.NewFuzzyQuery(term).SetField("label"))
-			}
-		}
 	}
 
 	boolq := bluge.NewBooleanQuery().
@@ -174,10 +229,19 @@ func (idx *Index) Search(ctx context.Context, q string, opt QueryOptions) (Resul
 	}
 
 	if opt.Type != "" {
-		boolq.AddMust(bluge.NewMatchQuery(opt.Type).SetField("type"))
+		boolq.AddMust(bluge.NewTermQuery(opt.Type).SetField("type"))
+	}
+
+	if len(opt.Filters) > 0 {
+		fq, err := filterQuery(opt.Filters, idx.analysis)
+		if err != nil {
+			return res, err
+		}
+		boolq.AddMust(fq)
 	}
 
 	req := bluge.NewTopNSearch(opt.Limit, boolq).WithStandardAggregations()
+	addAggregations(req, opt)
 
 	switch opt.SortBy {
 	case "created", "updated":
@@ -194,8 +258,19 @@ func (idx *Index) Search(ctx context.Context, q string, opt QueryOptions) (Resul
 	}
 	res.Total = dmi.Aggregations().Count()
 	res.Time = dmi.Aggregations().Duration()
+	res.Facets = readFacets(dmi.Aggregations(), opt.Filters)
+
+	res.Hits, err = readHits(dmi)
+	if err != nil {
+		return res, fmt.Errorf("search: Index.Search: %w", err)
+	}
 
-	// Iterate through the query matches
+	return res, nil
+}
+
+// readHits reads the stored fields of the documents matched by a search.
+func readHits(dmi search.DocumentMatchIterator) ([]Hit, error) {
+	var hits []Hit
 	match, err := dmi.Next()
 	for err == nil && match != nil {
 		hit := Hit{Score: match.Score}
@@ -222,23 +297,23 @@ func (idx *Index) Search(ctx context.Context, q string, opt QueryOptions) (Resul
 			return true
 		})
 		if err != nil {
-			return res, fmt.Errorf("search: Index.Search: loading fields: %w", err)
+			return hits, fmt.Errorf("loading fields: %w", err)
 		}
-		res.Hits = append(res.Hits, hit)
+		hits = append(hits, hit)
 
 		match, err = dmi.Next() // load next match
 	}
 	if err != nil {
-		return res, fmt.Errorf("search: Index.Search: iterating results: %w", err)
+		return hits, fmt.Errorf("iterating results: %w", err)
 	}
-
-	return res, nil
+	return hits, nil
 }
 
 type Results struct {
-	Hits  []Hit
-	Total uint64
-	Time  time.Duration
+	Hits   []Hit
+	Total  uint64
+	Time   time.Duration
+	Facets []Facet
 }
 
 type Hit struct {
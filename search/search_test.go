@@ -0,0 +1,420 @@
+package search_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/google/go-cmp/cmp"
+	"github.com/knakk/sirkulator/search"
+	"github.com/knakk/sirkulator/sql"
+	"golang.org/x/text/language"
+)
+
+func TestFieldSearch(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut (1859–1952)',
+			        '{"name": "Hamsun, Knut", "name_variations": ["Pedersen, Knud"], "year_range": {"from": "1859", "to": "1952"}}', 0, 0),
+			       ('b1', 'publication', 'Hamsun, Knut - Sult (1890)',
+			        '{"title": "Sult", "year": "1890", "language": "iso6393/nob"}', 0, 0),
+			       ('b2', 'publication', 'Hamsun, Knut - Markens grøde (1917)',
+			        '{"title": "Markens grøde", "year": "1917", "language": "iso6393/nob"}', 0, 0),
+			       ('b3', 'publication', 'Undset, Sigrid - Kransen (1920)',
+			        '{"title": "Kransen", "year": "1920", "language": "iso6393/nob"}', 0, 0),
+			       ('839.82', 'dewey', '839.82 Norsk litteratur', '{"number": "839.82", "name": "Norsk litteratur"}', 0, 0);
+		INSERT INTO link (resource_id, type, id)
+			VALUES ('b1', 'isbn', '9788205123456'),
+			       ('p1', 'viaf', '61624802');
+		INSERT INTO relation (from_id, to_id, type, data)
+			VALUES ('b1', 'p1', 'has_contributor', '{"role": "aut"}'),
+			       ('b2', 'p1', 'has_contributor', '{"role": "aut"}'),
+			       ('b2', '839.82', 'has_classification', NULL),
+			       ('b3', NULL, 'has_contributor', '{"role": "aut", "label": "Undset, Sigrid"}');`); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := search.Documents(conn, "p1", "b1", "b2", "b3", "839.82", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 5 {
+		t.Fatalf("got %d documents; want 5", len(docs))
+	}
+	if got := docs[1].GetAllOfField("contributor"); len(got) != 1 || got[0] != "Hamsun, Knut (1859–1952)" {
+		t.Errorf("contributors of b1 = %v; want Hamsun", got)
+	}
+
+	idx, err := search.OpenMem(language.Norwegian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Store(docs...); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"isbn:978-82-05-12345-6", []string{"b1"}},
+		{"978-82-05-12345-6", []string{"b1"}},
+		{"id:61624802", []string{"p1"}},
+		{"pedersen", []string{"p1"}},
+		{"contributor:hamsun", []string{"b1", "b2"}},
+		{`contributor:"sigrid undset"`, []string{"b3"}},
+		{"contributor:hamsun year:1900..", []string{"b2"}},
+		{"year:..1900", []string{"b1", "p1"}},
+		{"year:1920", []string{"b3"}},
+		{"dewey:839*", []string{"839.82", "b2"}},
+		{"language:nob kransen", []string{"b3"}},
+		{"name:markens", []string{"b2"}},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(context.Background(), test.q, search.QueryOptions{Limit: 10})
+		if err != nil {
+			t.Errorf("Search(%q): %v", test.q, err)
+			continue
+		}
+		var got []string
+		for _, h := range res.Hits {
+			got = append(got, h.ID)
+		}
+		sort.Strings(got)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Search(%q) mismatch (-want +got):\n%s", test.q, diff)
+		}
+	}
+
+	if _, err := idx.Search(context.Background(), "year:abc", search.QueryOptions{Limit: 10}); !errors.Is(err, search.ErrInvalidQuery) {
+		t.Errorf("Search(year:abc): got %v; want ErrInvalidQuery", err)
+	}
+}
+
+func TestFacets(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at, archived_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{"name": "Hamsun, Knut"}', 0, 0, NULL),
+			       ('b1', 'publication', 'Sult', '{"title": "Sult", "year": 1890, "language": "iso6393/nob", "fiction": true, "audiences": ["TG1003"]}', 0, 0, NULL),
+			       ('b2', 'publication', 'Kransen', '{"title": "Kransen", "year": 1920, "language": "iso6393/nob", "fiction": true}', 0, 0, NULL),
+			       ('b3', 'publication', 'Nordisk mytologi', '{"title": "Nordisk mytologi", "year": 2015, "language": "iso6393/eng", "nonfiction": true, "audiences": ["TG1003"]}', 0, 0, NULL),
+			       ('b4', 'publication', 'Gammel', '{"title": "Gammel", "year": 1925, "language": "iso6393/nob"}', 0, 0, 1);`); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := search.Documents(conn, "p1", "b1", "b2", "b3", "b4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := search.OpenMem(language.Norwegian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Store(docs...); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := idx.Search(context.Background(), "", search.QueryOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []search.Facet{
+		{Field: "type", Values: []search.FacetValue{{Value: "publication", Count: 3}, {Value: "person", Count: 1}}},
+		{Field: "published", Values: []search.FacetValue{{Value: "..1899", Count: 1}, {Value: "1900..1949", Count: 1}, {Value: "2010..2019", Count: 1}}},
+		{Field: "language", Values: []search.FacetValue{{Value: "nob", Count: 2}, {Value: "eng", Count: 1}}},
+		{Field: "audience", Values: []search.FacetValue{{Value: "tg1003", Count: 2}}},
+		{Field: "content", Values: []search.FacetValue{{Value: "fiction", Count: 2}, {Value: "nonfiction", Count: 1}}},
+	}
+	if diff := cmp.Diff(want, res.Facets); diff != "" {
+		t.Errorf("facets mismatch (-want +got):\n%s", diff)
+	}
+
+	res, err = idx.Search(context.Background(), "", search.QueryOptions{
+		Limit:        10,
+		InclArchived: true,
+		Filters:      []string{"language:nob", "published:1900..1949", "published:2010..2019"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, h := range res.Hits {
+		got = append(got, h.ID)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"b2", "b4"}, got); diff != "" {
+		t.Errorf("filtered hits mismatch (-want +got):\n%s", diff)
+	}
+	wantStatus := search.Facet{Field: "status", Values: []search.FacetValue{{Value: "active", Count: 1}, {Value: "archived", Count: 1}}}
+	wantPublished := search.Facet{Field: "published", Values: []search.FacetValue{
+		{Value: "1900..1949", Count: 2, Active: true},
+		{Value: "2010..2019", Active: true},
+	}}
+	for _, f := range res.Facets {
+		switch f.Field {
+		case "status":
+			if diff := cmp.Diff(wantStatus, f); diff != "" {
+				t.Errorf("status facet mismatch (-want +got):\n%s", diff)
+			}
+		case "published":
+			if diff := cmp.Diff(wantPublished, f); diff != "" {
+				t.Errorf("published facet mismatch (-want +got):\n%s", diff)
+			}
+		}
+	}
+
+	if _, err := idx.Search(context.Background(), "", search.QueryOptions{Limit: 10, Filters: []string{"nofield:x"}}); !errors.Is(err, search.ErrInvalidQuery) {
+		t.Errorf("Search with unknown filter: got %v; want ErrInvalidQuery", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	idx, err := search.OpenMem(language.Norwegian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	if err := idx.Store(
+		search.Document{ID: "p1", Type: "person", Label: "Hamsun, Knut (1859–1952)",
+			Fields: [][2]string{{"name", "Hamsun, Knut"}, {"name", "Pedersen, Knud"}}},
+		search.Document{ID: "p2", Type: "person", Label: "Hammer, Anne"},
+		search.Document{ID: "p3", Type: "person", Label: "Hamsun, Marie", ArchivedAt: time.Unix(1, 0)},
+		search.Document{ID: "b1", Type: "publication", Label: "Hamsun, Knut - Sult (1890)"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix string
+		typ    string
+		want   []string
+	}{
+		{"ham", "", []string{"b1", "p1", "p2"}},
+		{"ham", "person", []string{"p1", "p2"}},
+		{"hams kn", "", []string{"b1", "p1"}},
+		{"KNU ham", "person", []string{"p1"}},
+		{"pederse", "", []string{"p1"}},
+		{"hamsunx", "", nil},
+		{"", "", nil},
+	}
+	for _, test := range tests {
+		hits, err := idx.Suggest(context.Background(), test.prefix, test.typ, 10)
+		if err != nil {
+			t.Errorf("Suggest(%q, %q): %v", test.prefix, test.typ, err)
+			continue
+		}
+		var got []string
+		for _, h := range hits {
+			got = append(got, h.ID)
+		}
+		sort.Strings(got)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Suggest(%q, %q) mismatch (-want +got):\n%s", test.prefix, test.typ, diff)
+		}
+	}
+}
+
+func TestTextAnalysis(t *testing.T) {
+	docs := []search.Document{
+		{ID: "b1", Type: "publication", Label: "Aasen, Ivar - Den store boka om ord (1850)"},
+		{ID: "b2", Type: "publication", Label: "Ibsen, Henrik - Et dukkehjem (1879)"},
+		{ID: "b3", Type: "publication", Label: "Lagerlöf, Selma - Gösta Berlings saga (1891)"},
+	}
+	tests := []struct {
+		lang language.Tag
+		q    string
+		want []string
+	}{
+		{language.Norwegian, "bøker", []string{"b1"}},
+		{language.Norwegian, "Åsen", []string{"b1"}},
+		{language.Norwegian, "ibsén", []string{"b2"}},
+		{language.Norwegian, "den dukkehjemmet", []string{"b2"}},
+		{language.Norwegian, "lagerløf gøsta", []string{"b3"}},
+		{language.English, "ibsén", []string{"b2"}},
+		{language.English, "lagerlof", []string{"b3"}},
+		{language.English, "sagas", []string{"b3"}},
+	}
+	for _, test := range tests {
+		idx, err := search.OpenMem(test.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Store(docs...); err != nil {
+			t.Fatal(err)
+		}
+		res, err := idx.Search(context.Background(), test.q, search.QueryOptions{Limit: 10})
+		idx.Close()
+		if err != nil {
+			t.Errorf("Search(%q) [%v]: %v", test.q, test.lang, err)
+			continue
+		}
+		var got []string
+		for _, h := range res.Hits {
+			got = append(got, h.ID)
+		}
+		sort.Strings(got)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Search(%q) [%v] mismatch (-want +got):\n%s", test.q, test.lang, diff)
+		}
+	}
+}
+
+func TestIndexRebuild(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	open := func(lang language.Tag) *search.Index {
+		t.Helper()
+		idx, err := search.Open(dir, lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return idx
+	}
+
+	idx := open(language.Norwegian)
+	if !idx.Stale() {
+		t.Error("new index is not stale")
+	}
+	indexer := search.Indexer{DB: db, Idx: idx, BatchSize: 10}
+	if err := indexer.Run(context.Background(), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Stale() {
+		t.Error("index is stale after building")
+	}
+	idx.Close()
+
+	idx = open(language.Norwegian)
+	if idx.Stale() {
+		t.Error("index reopened with the same language is stale")
+	}
+	idx.Close()
+
+	idx = open(language.English)
+	if !idx.Stale() {
+		t.Error("index reopened with another language is not stale")
+	}
+	idx.Close()
+}
+
+func TestQueueIndexer(t *testing.T) {
+	db, err := sql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	idx, err := search.OpenMem(language.Norwegian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	q := search.QueueIndexer{DB: db, Idx: idx}
+	ctx := context.Background()
+	hits := func(query string) (ids []string) {
+		t.Helper()
+		res, err := idx.Search(ctx, query, search.QueryOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range res.Hits {
+			ids = append(ids, h.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	drain := func(want int) {
+		t.Helper()
+		n, err := q.Drain(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("Drain indexed %d resources; want %d", n, want)
+		}
+	}
+	exec := func(script string) {
+		t.Helper()
+		if err := sqlitex.ExecScript(conn, script); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exec(`
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{"name": "Hamsun, Knut"}', 0, 0),
+			       ('b1', 'publication', 'Sult', '{"title": "Sult"}', 0, 0);`)
+	drain(2)
+	if diff := cmp.Diff([]string{"b1"}, hits("sult")); diff != "" {
+		t.Errorf("after insert mismatch (-want +got):\n%s", diff)
+	}
+
+	// Relations are indexed with the label of the related resource, so changing
+	// it queues the resources related to it.
+	exec(`INSERT INTO relation (from_id, to_id, type) VALUES ('b1', 'p1', 'has_contributor');`)
+	drain(1)
+	exec(`UPDATE resource SET label='Hamsun, Knut (1859–1952)' WHERE id='p1';`)
+	drain(2)
+	if diff := cmp.Diff([]string{"b1"}, hits(`contributor:1952`)); diff != "" {
+		t.Errorf("after label update mismatch (-want +got):\n%s", diff)
+	}
+
+	// Deleted resources are deleted from the index.
+	exec(`DELETE FROM relation; DELETE FROM resource WHERE id='b1';`)
+	drain(1)
+	if got := hits("sult"); len(got) != 0 {
+		t.Errorf("deleted resource found: %v", got)
+	}
+
+	// Resources which fail to be indexed are retried later, without holding
+	// back the others.
+	exec(`
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('b2', 'publication', 'Bad', '{', 0, 0),
+			       ('b3', 'publication', 'Markens grøde', '{"title": "Markens grøde"}', 0, 0);`)
+	drain(2)
+	if diff := cmp.Diff([]string{"b3"}, hits("markens")); diff != "" {
+		t.Errorf("after failure mismatch (-want +got):\n%s", diff)
+	}
+	status, err := search.GetQueueStatus(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Queued != 1 || status.Failing != 1 || status.LastError == "" {
+		t.Errorf("GetQueueStatus = %+v; want 1 queued and failing, with error", status)
+	}
+	drain(0) // not due before retry delay
+}
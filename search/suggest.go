@@ -0,0 +1,49 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+)
+
+// suggestMaxLength is the length of the longest prefix of a word indexed
+// for suggestions. Longer words in a query are truncated to it.
+const suggestMaxLength = 20
+
+// Suggest returns the documents whose label or names have words starting with
+// every word of prefix, ex: "hams kn" matches "Hamsun, Knut". It is meant for
+// lookups as you type, so the words are matched exactly, not fuzzy. Results
+// are limited to the given type, unless empty. Archived documents are
+// left out.
+func (idx *Index) Suggest(ctx context.Context, prefix, typ string, limit int) ([]Hit, error) {
+	tokens := idx.analysis.suggestQuery.Analyze([]byte(prefix))
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	q := bluge.NewBooleanQuery()
+	for _, t := range tokens {
+		term := []rune(string(t.Term))
+		if len(term) > suggestMaxLength {
+			term = term[:suggestMaxLength]
+		}
+		q.AddMust(bluge.NewTermQuery(string(term)).SetField("suggest"))
+	}
+	if typ != "" {
+		q.AddMust(bluge.NewTermQuery(typ).SetField("type"))
+	}
+	q.AddMustNot(bluge.NewTermQuery("archived").SetField("flags"))
+
+	r, _ := idx.writer.Reader() // err is always nil: https://github.com/blugelabs/bluge/issues/35
+	defer r.Close()
+	dmi, err := r.Search(ctx, bluge.NewTopNSearch(limit, q))
+	if err != nil {
+		return nil, fmt.Errorf("search: Index.Suggest: %w", err)
+	}
+	hits, err := readHits(dmi)
+	if err != nil {
+		return nil, fmt.Errorf("search: Index.Suggest: %w", err)
+	}
+	return hits, nil
+}
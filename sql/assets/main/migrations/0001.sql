@@ -0,0 +1,44 @@
+-- Circulation: items, patrons and loans
+
+-- An item is a physical copy of a publication.
+CREATE TABLE item (
+    id             INTEGER PRIMARY KEY,
+    barcode        TEXT NOT NULL UNIQUE,
+    publication_id TEXT NOT NULL REFERENCES resource (id),
+    branch         TEXT NOT NULL,
+    shelfmark      TEXT NOT NULL DEFAULT '',
+    status         TEXT NOT NULL DEFAULT 'available', -- available|on_loan|lost|missing|withdrawn|on_hold
+    created_at     INTEGER NOT NULL, -- time.Now().Unix()
+    updated_at     INTEGER NOT NULL  -- time.Now().Unix()
+);
+
+CREATE INDEX idx_item_publication_id ON item (publication_id);
+
+CREATE TABLE patron (
+    id          INTEGER PRIMARY KEY,
+    card_number TEXT NOT NULL UNIQUE,
+    name        TEXT NOT NULL,
+    email       TEXT NOT NULL DEFAULT '',
+    phone       TEXT NOT NULL DEFAULT '',
+    category    TEXT NOT NULL DEFAULT 'adult',
+    branch      TEXT NOT NULL DEFAULT '', -- home branch
+    created_at  INTEGER NOT NULL, -- time.Now().Unix()
+    updated_at  INTEGER NOT NULL  -- time.Now().Unix()
+);
+
+-- A loan is active as long as checkin_at is NULL.
+CREATE TABLE loan (
+    id          INTEGER PRIMARY KEY,
+    item_id     INTEGER NOT NULL REFERENCES item (id),
+    patron_id   INTEGER NOT NULL REFERENCES patron (id),
+    checkout_at INTEGER NOT NULL, -- time.Now().Unix()
+    due_at      INTEGER NOT NULL, -- time.Now().Unix()
+    checkin_at  INTEGER,          -- time.Now().Unix()
+    renewals    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_loan_patron_id ON loan (patron_id);
+-- An item can only have one active loan at a time.
+CREATE UNIQUE INDEX idx_loan_active_item_id ON loan (item_id) WHERE checkin_at IS NULL;
+
+PRAGMA user_version = 2;
@@ -0,0 +1,26 @@
+-- Circulation: holds
+
+-- A hold is a patron's request to borrow a publication. The waiting holds on
+-- a publication forms a queue, ordered by id.
+CREATE TABLE hold (
+    id             INTEGER PRIMARY KEY,
+    publication_id TEXT NOT NULL REFERENCES resource (id),
+    patron_id      INTEGER NOT NULL REFERENCES patron (id),
+    pickup_branch  TEXT NOT NULL,
+    status         TEXT NOT NULL DEFAULT 'waiting', -- waiting|ready|collected|expired|cancelled
+    item_id        INTEGER REFERENCES item (id), -- set when status is ready
+    created_at     INTEGER NOT NULL, -- time.Now().Unix()
+    ready_at       INTEGER,          -- time.Now().Unix()
+    expires_at     INTEGER NOT NULL, -- time.Now().Unix()
+    closed_at      INTEGER           -- time.Now().Unix()
+);
+
+CREATE INDEX idx_hold_publication_id ON hold (publication_id, status);
+CREATE INDEX idx_hold_patron_id ON hold (patron_id);
+-- A patron can only have one active hold per publication.
+CREATE UNIQUE INDEX idx_hold_active ON hold (publication_id, patron_id) WHERE status IN ('waiting', 'ready');
+
+-- Expire holds not picked up in time every night, after the library has closed.
+INSERT INTO job_schedule (name, cron) VALUES ('expire_holds', '0 5 0 * * *');
+
+PRAGMA user_version = 3;
@@ -0,0 +1,22 @@
+-- Circulation: loan policy
+
+ALTER TABLE item ADD COLUMN type TEXT NOT NULL DEFAULT 'book'; -- book|dvd|audiobook etc
+
+-- The loan policy is a matrix of patron category × item type × branch, where
+-- an empty value matches any value. The most specific matching rule applies.
+CREATE TABLE loan_rule (
+    id              INTEGER PRIMARY KEY,
+    patron_category TEXT NOT NULL DEFAULT '',
+    item_type       TEXT NOT NULL DEFAULT '',
+    branch          TEXT NOT NULL DEFAULT '',
+    loan_days       INTEGER NOT NULL, -- 0 means items cannot be borrowed
+    max_renewals    INTEGER NOT NULL,
+    max_loans       INTEGER NOT NULL DEFAULT 0, -- 0 means no limit
+    holds_allowed   INTEGER NOT NULL DEFAULT 1, -- bool
+
+    UNIQUE (patron_category, item_type, branch)
+);
+
+INSERT INTO loan_rule (loan_days, max_renewals) VALUES (28, 2);
+
+PRAGMA user_version = 4;
@@ -0,0 +1,27 @@
+-- Circulation: opening hours calendar
+
+-- Regular weekly opening hours per branch. A branch without any
+-- opening hours is considered open every day.
+CREATE TABLE opening_hours (
+    branch  TEXT NOT NULL,
+    weekday INTEGER NOT NULL, -- 0=Sunday, as time.Weekday
+    opens   TEXT NOT NULL,    -- HH:MM
+    closes  TEXT NOT NULL,    -- HH:MM
+
+    PRIMARY KEY (branch, weekday)
+);
+
+-- Exceptions from the regular opening hours, ie. closed days or
+-- different opening hours. Public holidays are computed, and need
+-- not be stored here.
+CREATE TABLE calendar_exception (
+    branch TEXT NOT NULL,
+    date   TEXT NOT NULL,             -- YYYY-MM-DD
+    opens  TEXT NOT NULL DEFAULT '',  -- HH:MM, empty means closed
+    closes TEXT NOT NULL DEFAULT '',  -- HH:MM
+    note   TEXT NOT NULL DEFAULT '',
+
+    PRIMARY KEY (branch, date)
+);
+
+PRAGMA user_version = 5;
@@ -0,0 +1,29 @@
+-- Circulation: notices
+
+ALTER TABLE patron ADD COLUMN lang TEXT NOT NULL DEFAULT 'no'; -- preferred language, used in notices
+
+-- Queue of notices to patrons, ie. reminders of overdue loans.
+CREATE TABLE notice (
+    id         INTEGER PRIMARY KEY,
+    patron_id  INTEGER NOT NULL REFERENCES patron (id),
+    loan_id    INTEGER REFERENCES loan (id),
+    type       TEXT NOT NULL,                   -- overdue_1|overdue_2|overdue_final
+    recipient  TEXT NOT NULL,                   -- email address
+    subject    TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'pending', -- pending|sent|failed
+    error      TEXT NOT NULL DEFAULT '',
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL, -- time.Now().Unix()
+    sent_at    INTEGER           -- time.Now().Unix()
+);
+
+CREATE INDEX idx_notice_status ON notice (status);
+-- A loan can only get one notice of each type.
+CREATE UNIQUE INDEX idx_notice_loan_id_type ON notice (loan_id, type) WHERE loan_id IS NOT NULL;
+
+-- Generate overdue notices every night at 02:00, and deliver pending notices every hour.
+INSERT INTO job_schedule (name, cron) VALUES ('overdue_notices', '0 0 2 * * *');
+INSERT INTO job_schedule (name, cron) VALUES ('send_notices', '0 15 * * * *');
+
+PRAGMA user_version = 6;
@@ -0,0 +1,37 @@
+-- Circulation: fines and fees
+
+ALTER TABLE loan_rule ADD COLUMN fine_per_day INTEGER NOT NULL DEFAULT 0; -- in minor units (øre)
+ALTER TABLE loan_rule ADD COLUMN max_fine     INTEGER NOT NULL DEFAULT 0; -- per loan, 0 means no limit
+ALTER TABLE loan_rule ADD COLUMN max_balance  INTEGER NOT NULL DEFAULT 0; -- 0 means no limit
+
+-- Append-only log of transactions in patrons' accounts. The balance of
+-- a patron is the sum of all amounts.
+CREATE TABLE ledger (
+    id         INTEGER PRIMARY KEY,
+    patron_id  INTEGER NOT NULL REFERENCES patron (id),
+    loan_id    INTEGER REFERENCES loan (id),
+    type       TEXT NOT NULL,    -- fine|fee|payment|waiver
+    amount     INTEGER NOT NULL, -- in minor units (øre), negative for payment|waiver
+    note       TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL, -- time.Now().Unix()
+
+    CHECK (CASE WHEN type IN ('payment', 'waiver') THEN amount < 0 ELSE amount > 0 END)
+);
+
+CREATE INDEX idx_ledger_patron_id ON ledger (patron_id);
+CREATE INDEX idx_ledger_loan_id ON ledger (loan_id) WHERE loan_id IS NOT NULL;
+
+CREATE TRIGGER ledger_no_update BEFORE UPDATE ON ledger
+BEGIN
+    SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+CREATE TRIGGER ledger_no_delete BEFORE DELETE ON ledger
+BEGIN
+    SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+-- Accrue fines on overdue loans every night at 01:00.
+INSERT INTO job_schedule (name, cron) VALUES ('accrue_fines', '0 0 1 * * *');
+
+PRAGMA user_version = 7;
@@ -0,0 +1,23 @@
+-- Staff users and sessions
+
+CREATE TABLE user (
+    id            INTEGER PRIMARY KEY,
+    username      TEXT NOT NULL UNIQUE,
+    name          TEXT NOT NULL DEFAULT '',
+    password_hash TEXT NOT NULL, -- bcrypt
+    role          TEXT NOT NULL, -- cataloguer|circulation|admin
+    created_at    INTEGER NOT NULL, -- time.Now().Unix()
+    updated_at    INTEGER NOT NULL  -- time.Now().Unix()
+);
+
+CREATE TABLE session (
+    token_hash TEXT PRIMARY KEY NOT NULL, -- sha256 of session token, hex-encoded
+    user_id    INTEGER NOT NULL REFERENCES user (id) ON DELETE CASCADE,
+    csrf_token TEXT NOT NULL,
+    created_at INTEGER NOT NULL, -- time.Now().Unix()
+    expires_at INTEGER NOT NULL  -- time.Now().Unix()
+);
+
+CREATE INDEX idx_session_user_id ON session (user_id);
+
+PRAGMA user_version = 8;
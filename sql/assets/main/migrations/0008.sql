@@ -0,0 +1,20 @@
+-- Edit history of resources
+--
+-- The original resource_edit_log table was never written to, and its primary
+-- key only allowed one edit per resource per second, so it is replaced.
+-- resource_id is not a foreign key, as the creation of a resource is logged
+-- before it is inserted, and the history should outlive deleted resources.
+
+DROP TABLE resource_edit_log;
+
+CREATE TABLE resource_edit_log (
+    id          INTEGER PRIMARY KEY,
+    resource_id TEXT NOT NULL,
+    at          INTEGER NOT NULL, -- time.Now().Unix()
+    actor       TEXT NOT NULL DEFAULT '', -- username or job name
+    diff        JSON NOT NULL  -- {"label": {"old": .., "new": ..}, "data": {"<field>": {"old": .., "new": ..}}}
+);
+
+CREATE INDEX idx_resource_edit_log_resource_id ON resource_edit_log (resource_id);
+
+PRAGMA user_version = 9;
@@ -0,0 +1,15 @@
+-- Redirects from resources which have been merged into other resources.
+--
+-- The merged (losing) resource is kept as archived, and all references to
+-- it are moved to the surviving resource. If the survivor is later merged
+-- into another resource, the existing redirects are updated to point there.
+
+CREATE TABLE resource_redirect (
+    from_id    TEXT PRIMARY KEY NOT NULL REFERENCES resource (id),
+    to_id      TEXT NOT NULL REFERENCES resource (id),
+    created_at INTEGER NOT NULL -- time.Now().Unix()
+);
+
+CREATE INDEX idx_resource_redirect_to_id ON resource_redirect (to_id);
+
+PRAGMA user_version = 10;
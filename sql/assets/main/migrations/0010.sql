@@ -0,0 +1,4 @@
+-- Back up the databases every night at 03:30.
+INSERT INTO job_schedule (name, cron) VALUES ('backup', '0 30 3 * * *');
+
+PRAGMA user_version = 11;
@@ -0,0 +1,19 @@
+-- review_resolution records how reviews (relations without to_id) were
+-- resolved, and by whom. The review relation is either updated to point to
+-- an existing or newly created resource, or deleted when dismissed, so its
+-- original type and data are kept here.
+CREATE TABLE review_resolution (
+    id          INTEGER PRIMARY KEY,
+    relation_id INTEGER NOT NULL,
+    from_id     TEXT NOT NULL,
+    type        TEXT NOT NULL,
+    data        JSON,
+    action      TEXT NOT NULL, -- linked|created|dismissed
+    to_id       TEXT,          -- NULL if dismissed
+    actor       TEXT NOT NULL,
+    resolved_at INTEGER NOT NULL -- time.Now().Unix()
+);
+
+CREATE INDEX idx_review_resolution_resolved_at ON review_resolution (resolved_at);
+
+PRAGMA user_version = 12;
@@ -0,0 +1,90 @@
+-- index_queue is the outbox of resources whose documents in the search index
+-- are out of date. It is written by the triggers below, in the same transaction
+-- as the change to the resource, or its links and relations, and drained by
+-- the search indexer, which deletes the entry when the resource is indexed.
+CREATE TABLE index_queue (
+    resource_id TEXT PRIMARY KEY NOT NULL,
+    version     INTEGER NOT NULL DEFAULT 1, -- incremented on every change
+    queued_at   INTEGER NOT NULL, -- time.Now().Unix() of the first change not indexed
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    next_at     INTEGER NOT NULL DEFAULT 0, -- time.Now().Unix(); retry no earlier than this
+    error       TEXT -- error of the last failed attempt
+);
+
+CREATE INDEX idx_index_queue_next_at ON index_queue (next_at);
+
+CREATE TRIGGER resource_insert_index_queue AFTER INSERT ON resource
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+-- Documents of resources related to the updated resource are indexed with
+-- its label, so they must be reindexed when it changes.
+CREATE TRIGGER resource_update_index_queue AFTER UPDATE ON resource
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT DISTINCT from_id, strftime('%s', 'now') FROM relation WHERE to_id=NEW.id AND OLD.label IS NOT NEW.label
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER resource_delete_index_queue AFTER DELETE ON resource
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT OLD.id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER relation_insert_index_queue AFTER INSERT ON relation
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.from_id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER relation_update_index_queue AFTER UPDATE ON relation
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.from_id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT OLD.from_id, strftime('%s', 'now') WHERE OLD.from_id != NEW.from_id
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER relation_delete_index_queue AFTER DELETE ON relation
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT OLD.from_id, strftime('%s', 'now') WHERE true
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER link_insert_index_queue AFTER INSERT ON link
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.resource_id, strftime('%s', 'now') WHERE NEW.resource_id IS NOT NULL
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER link_update_index_queue AFTER UPDATE ON link
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT NEW.resource_id, strftime('%s', 'now') WHERE NEW.resource_id IS NOT NULL
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT OLD.resource_id, strftime('%s', 'now') WHERE OLD.resource_id IS NOT NEW.resource_id AND OLD.resource_id IS NOT NULL
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+CREATE TRIGGER link_delete_index_queue AFTER DELETE ON link
+BEGIN
+    INSERT INTO index_queue (resource_id, queued_at)
+    SELECT OLD.resource_id, strftime('%s', 'now') WHERE OLD.resource_id IS NOT NULL
+        ON CONFLICT (resource_id) DO UPDATE SET version=version+1, attempts=0, next_at=0, error=NULL;
+END;
+
+PRAGMA user_version = 13;
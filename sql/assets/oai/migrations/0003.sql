@@ -0,0 +1,7 @@
+-- Remove the tables created by the dummy migrations 0001 and 0002, which were
+-- only used to try out the migration runner.
+
+DROP TABLE oai.test_a;
+DROP TABLE oai.test_b;
+
+PRAGMA oai.user_version = 4;
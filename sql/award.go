@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"fmt"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+const awardNominationsQuery = `
+    SELECT
+        r.id,
+        a.id, a.label,
+        n.id, n.type, n.label,
+        json_extract(r.data, '$.year') AS year,
+        r.type='has_winner' AS won
+    FROM
+        relation r
+        JOIN resource a ON (r.from_id=a.id)
+        JOIN resource n ON (r.to_id=n.id)
+    WHERE
+        r.type IN ('has_nominee', 'has_winner')
+    AND %s=?
+    ORDER BY year DESC, a.label, won DESC, n.label`
+
+func readAwardNominations(res *[]sirkulator.AwardNomination) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		n := sirkulator.AwardNomination{
+			RelationID: stmt.ColumnInt64(0),
+			Award: sirkulator.SimpleResource{
+				ID:    stmt.ColumnText(1),
+				Type:  sirkulator.TypeLiteraryAward,
+				Label: stmt.ColumnText(2),
+			},
+			Nominee: sirkulator.SimpleResource{
+				ID:    stmt.ColumnText(3),
+				Type:  sirkulator.ParseResourceType(stmt.ColumnText(4)),
+				Label: stmt.ColumnText(5),
+			},
+			Year: stmt.ColumnInt(6),
+			Won:  stmt.ColumnInt(7) == 1,
+		}
+		// A winner is usually also recorded as nominated the same year,
+		// which is redundant when listing them. Winners are ordered first.
+		for _, prev := range *res {
+			if prev.Won && !n.Won && prev.Award.ID == n.Award.ID && prev.Nominee.ID == n.Nominee.ID && prev.Year == n.Year {
+				return nil
+			}
+		}
+		*res = append(*res, n)
+		return nil
+	}
+}
+
+// GetAwardNominations returns the nominees and winners of the literary award
+// with the given ID, the most recent year first, and winners before nominees.
+// Nominations of winners are left out.
+func GetAwardNominations(conn *sqlite.Conn, id string) ([]sirkulator.AwardNomination, error) {
+	var res []sirkulator.AwardNomination
+	q := fmt.Sprintf(awardNominationsQuery, "a.id")
+	if err := sqlitex.Exec(conn, q, readAwardNominations(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetAwardNominations(%q): %w", id, err)
+	}
+	return res, nil
+}
+
+// GetResourceAwards returns the literary awards the person or publication
+// with the given ID has been nominated for or has won, the most recent first.
+func GetResourceAwards(conn *sqlite.Conn, id string) ([]sirkulator.AwardNomination, error) {
+	var res []sirkulator.AwardNomination
+	q := fmt.Sprintf(awardNominationsQuery, "n.id")
+	if err := sqlitex.Exec(conn, q, readAwardNominations(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetResourceAwards(%q): %w", id, err)
+	}
+	return res, nil
+}
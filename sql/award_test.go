@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/google/go-cmp/cmp"
+	"github.com/knakk/sirkulator"
+)
+
+func TestAwardNominations(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('a1', 'literary_award', 'Brageprisen', '{}', 0, 0),
+			       ('p1', 'person', 'Petterson, Per', '{}', 0, 0),
+			       ('p2', 'person', 'Fosse, Jon', '{}', 0, 0),
+			       ('b1', 'publication', 'Ut og stjæle hester', '{}', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "a1", ToID: "b1", Type: "has_winner"}); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("creating has_winner relation without year: got %v; want invalid", err)
+	}
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", ToID: "a1", Type: "has_winner", Data: map[string]any{"year": 2003}}); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("creating has_winner relation from a publication: got %v; want invalid", err)
+	}
+
+	for _, rel := range []sirkulator.Relation{
+		{FromID: "a1", ToID: "b1", Type: "has_nominee", Data: map[string]any{"year": 2003}},
+		{FromID: "a1", ToID: "b1", Type: "has_winner", Data: map[string]any{"year": 2003}},
+		{FromID: "a1", ToID: "p2", Type: "has_nominee", Data: map[string]any{"year": 2003}},
+		{FromID: "a1", ToID: "p2", Type: "has_winner", Data: map[string]any{"year": 2007}},
+	} {
+		if _, err := CreateRelation(conn, rel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	award := sirkulator.SimpleResource{ID: "a1", Type: sirkulator.TypeLiteraryAward, Label: "Brageprisen"}
+	book := sirkulator.SimpleResource{ID: "b1", Type: sirkulator.TypePublication, Label: "Ut og stjæle hester"}
+	fosse := sirkulator.SimpleResource{ID: "p2", Type: sirkulator.TypePerson, Label: "Fosse, Jon"}
+
+	got, err := GetAwardNominations(conn, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		if got[i].RelationID == 0 {
+			t.Errorf("GetAwardNominations(a1)[%d] has no relation ID", i)
+		}
+		got[i].RelationID = 0
+	}
+	want := []sirkulator.AwardNomination{
+		{Award: award, Nominee: fosse, Year: 2007, Won: true},
+		{Award: award, Nominee: book, Year: 2003, Won: true},
+		{Award: award, Nominee: fosse, Year: 2003, Won: false},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetAwardNominations(a1) mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = GetResourceAwards(conn, "p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i].RelationID = 0
+	}
+	if diff := cmp.Diff([]sirkulator.AwardNomination{want[0], want[2]}, got); diff != "" {
+		t.Errorf("GetResourceAwards(p2) mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = GetResourceAwards(conn, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetResourceAwards(p1) = %+v; want none", got)
+	}
+}
@@ -0,0 +1,229 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+// backupLayout is the time layout of the names of backup directories.
+const backupLayout = "20060102T150405.000"
+
+// Backup writes a consistent copy of each of the databases (main, oai, files) to
+// the given directory, which must not exist. It is safe to run while the
+// databases are in use.
+func Backup(conn *sqlite.Conn, dir string) error {
+	// Write to a temporary directory first, so that a failed backup is never
+	// mistaken for a complete one.
+	tmp := dir + ".tmp"
+	if err := os.MkdirAll(tmp, os.ModePerm); err != nil {
+		return fmt.Errorf("sql.Backup(%s): %w", dir, err)
+	}
+	for _, db := range []database{dbMain, dbOAI, dbFiles} {
+		file := filepath.Join(tmp, db.String()+".db")
+		if err := sqlitex.ExecTransient(conn, "VACUUM "+db.String()+" INTO ?", nil, file); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("sql.Backup(%s): %s: %w", dir, db, err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("sql.Backup(%s): %w", dir, err)
+	}
+	return nil
+}
+
+// Backups returns the backups in the given directory, from oldest to newest.
+func Backups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("sql.Backups(%s): %w", dir, err)
+	}
+	var res []string
+	for _, e := range entries {
+		if _, err := time.Parse(backupLayout, e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+		res = append(res, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(res) // the layout sorts chronologically
+	return res, nil
+}
+
+// checkBackup verifies that the backup in the given directory contains all
+// the databases, that they are not corrupt, and that their schemas are not
+// newer than this version of the program can handle.
+func checkBackup(dir string) error {
+	for _, db := range []database{dbMain, dbOAI, dbFiles} {
+		file := filepath.Join(dir, db.String()+".db")
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+		conn, err := sqlite.OpenConn("file:"+file+"?mode=ro", sqlite.SQLITE_OPEN_READONLY|sqlite.SQLITE_OPEN_URI)
+		if err != nil {
+			return fmt.Errorf("%s: %w", db, err)
+		}
+		version, err := schemaVersion(conn, "main")
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("%s: %w", db, err)
+		}
+		check, err := sqlitex.ResultText(conn.Prep("PRAGMA quick_check;"))
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", db, err)
+		}
+		if check != "ok" {
+			return fmt.Errorf("%s: quick_check: %s", db, check)
+		}
+		if version == 0 {
+			return fmt.Errorf("%s: database has no schema", db)
+		}
+		if _, err := pending(db, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the databases in dataDir with the ones from the backup in
+// backupDir, after verifying the backup. It must not be called while the
+// databases are open. The replaced databases are kept with the suffix
+// .before-restore, along with their journals, so that they can be opened to
+// undo the restore. Either all or none of the databases are replaced.
+// Backups with an older schema version are migrated when they are opened.
+func Restore(backupDir, dataDir string) error {
+	if err := checkBackup(backupDir); err != nil {
+		return fmt.Errorf("sql.Restore(%s): %w", backupDir, err)
+	}
+	dbs := []database{dbMain, dbOAI, dbFiles}
+
+	// Copy all the databases next to the ones they replace first, since
+	// copying is what is most likely to fail, ex: when the disk is full.
+	defer func() {
+		for _, db := range dbs {
+			os.Remove(filepath.Join(dataDir, db.String()+".db.restore"))
+		}
+	}()
+	for _, db := range dbs {
+		src := filepath.Join(backupDir, db.String()+".db")
+		dst := filepath.Join(dataDir, db.String()+".db")
+		if err := copyFile(src, dst+".restore"); err != nil {
+			return fmt.Errorf("sql.Restore(%s): %w", backupDir, err)
+		}
+	}
+
+	// Then swap them by renaming, which is undone if any rename fails.
+	var renamed [][2]string
+	rename := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		renamed = append(renamed, [2]string{from, to})
+		return nil
+	}
+	journals := []string{"", "-journal", "-wal", "-shm"}
+	for _, db := range dbs {
+		dst := filepath.Join(dataDir, db.String()+".db")
+		for _, suffix := range journals {
+			// Journals left from an earlier restore don't belong to the
+			// database about to be kept, and would corrupt it if applied.
+			os.Remove(dst + ".before-restore" + suffix)
+		}
+	}
+	for _, db := range dbs {
+		dst := filepath.Join(dataDir, db.String()+".db")
+		var err error
+		for _, suffix := range journals {
+			if err = rename(dst+suffix, dst+".before-restore"+suffix); errors.Is(err, os.ErrNotExist) {
+				err = nil
+			} else if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rename(dst+".restore", dst)
+		}
+		if err != nil {
+			for i := len(renamed) - 1; i >= 0; i-- {
+				os.Rename(renamed[i][1], renamed[i][0])
+			}
+			return fmt.Errorf("sql.Restore(%s): %w", backupDir, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// BackupJob backs up the databases into a new timestamped directory in Dir,
+// keeping only the Keep most recent backups.
+type BackupJob struct {
+	DB   *sqlitex.Pool
+	Dir  string
+	Keep int
+}
+
+func (j *BackupJob) Name() string {
+	return "backup"
+}
+
+func (j *BackupJob) Run(ctx context.Context, w io.Writer) error {
+	if j.Dir == "" {
+		return errors.New("no backup directory configured")
+	}
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	dir := filepath.Join(j.Dir, time.Now().Format(backupLayout))
+	start := time.Now()
+	if err := Backup(conn, dir); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "backup written to %s in %v\n", dir, time.Since(start))
+
+	backups, err := Backups(j.Dir)
+	if err != nil {
+		return err
+	}
+	for j.Keep > 0 && len(backups) > j.Keep {
+		if err := os.RemoveAll(backups[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "removed old backup %s\n", backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	backupDir := t.TempDir()
+
+	db, err := OpenAt(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := db.Get(nil)
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{}', 0, 0);
+		INSERT INTO files.image (id, type, width, height, size, data)
+			VALUES ('p1', 'jpeg', 1, 1, 1, x'00');`); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+
+	job := BackupJob{DB: db, Dir: backupDir, Keep: 2}
+	for i := 0; i < 3; i++ {
+		if err := job.Run(context.Background(), io.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := Backups(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups; want 2", len(backups))
+	}
+
+	// Make changes after the backup, which should be gone after restore.
+	conn = db.Get(nil)
+	if err := sqlitex.ExecScript(conn, `
+		DELETE FROM resource;
+		DELETE FROM files.image;`); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(filepath.Join(backupDir, "missing"), dataDir); err == nil {
+		t.Error("restoring missing backup succeeded; want error")
+	}
+
+	// A restore failing after some of the databases are swapped must leave
+	// all of them as they were.
+	blocker := filepath.Join(dataDir, "files.db.before-restore")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(backups[1], dataDir); err == nil {
+		t.Error("restore with files.db not movable succeeded; want error")
+	}
+	if n := countResources(t, filepath.Join(dataDir, "main.db")); n != 0 {
+		t.Errorf("got %d resources after failed restore; want 0", n)
+	}
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(backups[1], dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if n := countResources(t, filepath.Join(dataDir, "main.db.before-restore")); n != 0 {
+		t.Errorf("got %d resources in database kept before restore; want 0", n)
+	}
+
+	db, err = OpenAt(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn = db.Get(nil)
+	defer db.Put(conn)
+	n, err := sqlitex.ResultInt64(conn.Prep("SELECT count(*) FROM resource JOIN files.image USING (id)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d resources with image after restore; want 1", n)
+	}
+}
+
+func countResources(t *testing.T, file string) int64 {
+	t.Helper()
+	conn, err := sqlite.OpenConn("file:"+file+"?mode=ro", sqlite.SQLITE_OPEN_READONLY|sqlite.SQLITE_OPEN_URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := sqlitex.ResultInt64(conn.Prep("SELECT count(*) FROM resource"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator/calendar"
+)
+
+// GetCalendar returns the opening hours calendar of the given branch, including
+// the Norwegian public holidays.
+func GetCalendar(conn *sqlite.Conn, branch string) (calendar.Calendar, error) {
+	cal := calendar.Calendar{
+		Branch:   branch,
+		Holidays: calendar.NorwegianHolidays,
+	}
+
+	fn := func(stmt *sqlite.Stmt) error {
+		cal.Weekly = append(cal.Weekly, calendar.Hours{
+			Weekday: time.Weekday(stmt.ColumnInt(0)),
+			Opens:   stmt.ColumnText(1),
+			Closes:  stmt.ColumnText(2),
+		})
+		return nil
+	}
+	q := "SELECT weekday, opens, closes FROM opening_hours WHERE branch=? ORDER BY weekday"
+	if err := sqlitex.Exec(conn, q, fn, branch); err != nil {
+		return cal, fmt.Errorf("sql.GetCalendar(%q): %w", branch, err)
+	}
+
+	fn = func(stmt *sqlite.Stmt) error {
+		cal.Exceptions = append(cal.Exceptions, calendar.Exception{
+			Date:   stmt.ColumnText(0),
+			Opens:  stmt.ColumnText(1),
+			Closes: stmt.ColumnText(2),
+			Note:   stmt.ColumnText(3),
+		})
+		return nil
+	}
+	q = "SELECT date, opens, closes, note FROM calendar_exception WHERE branch=? ORDER BY date"
+	if err := sqlitex.Exec(conn, q, fn, branch); err != nil {
+		return cal, fmt.Errorf("sql.GetCalendar(%q): %w", branch, err)
+	}
+
+	return cal, nil
+}
+
+// SaveOpeningHours replaces the weekly opening hours of the given branch.
+func SaveOpeningHours(conn *sqlite.Conn, branch string, hours []calendar.Hours) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := sqlitex.Exec(conn, "DELETE FROM opening_hours WHERE branch=?", nil, branch); err != nil {
+		return fmt.Errorf("sql.SaveOpeningHours(%q): %w", branch, err)
+	}
+	stmt := conn.Prep(`
+		INSERT INTO opening_hours (branch, weekday, opens, closes)
+			VALUES ($branch, $weekday, $opens, $closes)`)
+	for _, h := range hours {
+		stmt.SetText("$branch", branch)
+		stmt.SetInt64("$weekday", int64(h.Weekday))
+		stmt.SetText("$opens", h.Opens)
+		stmt.SetText("$closes", h.Closes)
+		if _, err := stmt.Step(); err != nil {
+			return fmt.Errorf("sql.SaveOpeningHours(%q): %w", branch, err)
+		}
+		if err := stmt.Reset(); err != nil {
+			return fmt.Errorf("sql.SaveOpeningHours(%q): %w", branch, err)
+		}
+	}
+	return nil
+}
+
+// SaveCalendarException stores the given exception from the regular opening
+// hours of the branch, replacing any existing exception on the same date.
+func SaveCalendarException(conn *sqlite.Conn, branch string, e calendar.Exception) error {
+	stmt := conn.Prep(`
+		INSERT OR REPLACE INTO calendar_exception (branch, date, opens, closes, note)
+			VALUES ($branch, $date, $opens, $closes, $note)`)
+	stmt.SetText("$branch", branch)
+	stmt.SetText("$date", e.Date)
+	stmt.SetText("$opens", e.Opens)
+	stmt.SetText("$closes", e.Closes)
+	stmt.SetText("$note", e.Note)
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("sql.SaveCalendarException(%q, %q): %w", branch, e.Date, err)
+	}
+	return nil
+}
+
+// DeleteCalendarException deletes the exception of the given branch on the given date.
+func DeleteCalendarException(conn *sqlite.Conn, branch, date string) error {
+	stmt := conn.Prep("DELETE FROM calendar_exception WHERE branch=$branch AND date=$date")
+	stmt.SetText("$branch", branch)
+	stmt.SetText("$date", date)
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("sql.DeleteCalendarException(%q, %q): %w", branch, date, err)
+	}
+	return nil
+}
+
+// GetBranches returns all known branches, that is branches with items, patrons
+// or opening hours.
+func GetBranches(conn *sqlite.Conn) ([]string, error) {
+	var res []string
+	fn := func(stmt *sqlite.Stmt) error {
+		res = append(res, stmt.ColumnText(0))
+		return nil
+	}
+	const q = `
+		SELECT branch FROM item
+		UNION SELECT branch FROM patron
+		UNION SELECT branch FROM opening_hours
+		ORDER BY 1`
+	if err := sqlitex.Exec(conn, q, fn); err != nil {
+		return res, fmt.Errorf("sql.GetBranches: %w", err)
+	}
+	// Remove empty branch, which is the default for patrons without home branch.
+	if len(res) > 0 && res[0] == "" {
+		res = res[1:]
+	}
+	return res, nil
+}
@@ -0,0 +1,550 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+const itemColumns = "id, barcode, publication_id, type, branch, shelfmark, status, created_at, updated_at"
+
+func readItem(item *sirkulator.Item) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		item.ID = stmt.ColumnInt64(0)
+		item.Barcode = stmt.ColumnText(1)
+		item.PublicationID = stmt.ColumnText(2)
+		item.Type = stmt.ColumnText(3)
+		item.Branch = stmt.ColumnText(4)
+		item.Shelfmark = stmt.ColumnText(5)
+		item.Status = sirkulator.ItemStatus(stmt.ColumnText(6))
+		item.CreatedAt = time.Unix(stmt.ColumnInt64(7), 0)
+		item.UpdatedAt = time.Unix(stmt.ColumnInt64(8), 0)
+		return nil
+	}
+}
+
+const patronColumns = "id, card_number, name, email, phone, category, branch, lang, created_at, updated_at"
+
+func readPatron(p *sirkulator.Patron) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		p.ID = stmt.ColumnInt64(0)
+		p.CardNumber = stmt.ColumnText(1)
+		p.Name = stmt.ColumnText(2)
+		p.Email = stmt.ColumnText(3)
+		p.Phone = stmt.ColumnText(4)
+		p.Category = stmt.ColumnText(5)
+		p.Branch = stmt.ColumnText(6)
+		p.Lang = stmt.ColumnText(7)
+		p.CreatedAt = time.Unix(stmt.ColumnInt64(8), 0)
+		p.UpdatedAt = time.Unix(stmt.ColumnInt64(9), 0)
+		return nil
+	}
+}
+
+const loanColumns = "id, item_id, patron_id, checkout_at, due_at, checkin_at, renewals"
+
+func readLoan(l *sirkulator.Loan) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		l.ID = stmt.ColumnInt64(0)
+		l.ItemID = stmt.ColumnInt64(1)
+		l.PatronID = stmt.ColumnInt64(2)
+		l.CheckoutAt = time.Unix(stmt.ColumnInt64(3), 0)
+		l.DueAt = time.Unix(stmt.ColumnInt64(4), 0)
+		if n := stmt.ColumnInt64(5); n != 0 {
+			l.CheckinAt = time.Unix(n, 0)
+		}
+		l.Renewals = stmt.ColumnInt(6)
+		return nil
+	}
+}
+
+// CreateItem persists a new Item, returning it with ID and timestamps set.
+func CreateItem(conn *sqlite.Conn, item sirkulator.Item) (sirkulator.Item, error) {
+	now := time.Now()
+	if item.Status == "" {
+		item.Status = sirkulator.ItemAvailable
+	}
+	if item.Type == "" {
+		item.Type = "book"
+	}
+	stmt := conn.Prep(`
+		INSERT INTO item (barcode, publication_id, type, branch, shelfmark, status, created_at, updated_at)
+			VALUES ($barcode, $publication_id, $type, $branch, $shelfmark, $status, $now, $now)
+		RETURNING id`)
+	stmt.SetText("$barcode", item.Barcode)
+	stmt.SetText("$publication_id", item.PublicationID)
+	stmt.SetText("$type", item.Type)
+	stmt.SetText("$branch", item.Branch)
+	stmt.SetText("$shelfmark", item.Shelfmark)
+	stmt.SetText("$status", string(item.Status))
+	stmt.SetInt64("$now", now.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return item, sirkulator.Errorf(sirkulator.CodeConflict, "barcode %s is already in use", item.Barcode)
+	} else if err != nil {
+		return item, fmt.Errorf("sql.CreateItem(%q): %w", item.Barcode, err)
+	}
+	item.ID = id
+	item.CreatedAt = time.Unix(now.Unix(), 0)
+	item.UpdatedAt = item.CreatedAt
+	return item, nil
+}
+
+// GetItem returns the Item with the given barcode.
+func GetItem(conn *sqlite.Conn, barcode string) (sirkulator.Item, error) {
+	var item sirkulator.Item
+	q := "SELECT " + itemColumns + " FROM item WHERE barcode=?"
+	if err := sqlitex.Exec(conn, q, readItem(&item), barcode); err != nil {
+		return item, fmt.Errorf("sql.GetItem(%q): %w", barcode, err)
+	}
+	if item.ID == 0 {
+		return item, sirkulator.ErrNotFound
+	}
+	return item, nil
+}
+
+func getItemByID(conn *sqlite.Conn, id int64) (sirkulator.Item, error) {
+	var item sirkulator.Item
+	q := "SELECT " + itemColumns + " FROM item WHERE id=?"
+	if err := sqlitex.Exec(conn, q, readItem(&item), id); err != nil {
+		return item, err
+	}
+	if item.ID == 0 {
+		return item, sirkulator.ErrNotFound
+	}
+	return item, nil
+}
+
+// GetPublicationItems returns all items of the given Publication, ordered by branch and barcode.
+func GetPublicationItems(conn *sqlite.Conn, id string) ([]sirkulator.Item, error) {
+	var res []sirkulator.Item
+	q := "SELECT " + itemColumns + " FROM item WHERE publication_id=? ORDER BY branch, barcode"
+	fn := func(stmt *sqlite.Stmt) error {
+		var item sirkulator.Item
+		if err := readItem(&item)(stmt); err != nil {
+			return err
+		}
+		res = append(res, item)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetPublicationItems(%q): %w", id, err)
+	}
+	return res, nil
+}
+
+// CreatePatron persists a new Patron, returning it with ID and timestamps set.
+func CreatePatron(conn *sqlite.Conn, p sirkulator.Patron) (sirkulator.Patron, error) {
+	now := time.Now()
+	if p.Category == "" {
+		p.Category = "adult"
+	}
+	if p.Lang == "" {
+		p.Lang = "no"
+	}
+	stmt := conn.Prep(`
+		INSERT INTO patron (card_number, name, email, phone, category, branch, lang, created_at, updated_at)
+			VALUES ($card_number, $name, $email, $phone, $category, $branch, $lang, $now, $now)
+		RETURNING id`)
+	stmt.SetText("$card_number", p.CardNumber)
+	stmt.SetText("$name", p.Name)
+	stmt.SetText("$email", p.Email)
+	stmt.SetText("$phone", p.Phone)
+	stmt.SetText("$category", p.Category)
+	stmt.SetText("$branch", p.Branch)
+	stmt.SetText("$lang", p.Lang)
+	stmt.SetInt64("$now", now.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return p, sirkulator.Errorf(sirkulator.CodeConflict, "card number %s is already in use", p.CardNumber)
+	} else if err != nil {
+		return p, fmt.Errorf("sql.CreatePatron(%q): %w", p.CardNumber, err)
+	}
+	p.ID = id
+	p.CreatedAt = time.Unix(now.Unix(), 0)
+	p.UpdatedAt = p.CreatedAt
+	return p, nil
+}
+
+// GetPatron returns the Patron with the given ID.
+func GetPatron(conn *sqlite.Conn, id int64) (sirkulator.Patron, error) {
+	var p sirkulator.Patron
+	q := "SELECT " + patronColumns + " FROM patron WHERE id=?"
+	if err := sqlitex.Exec(conn, q, readPatron(&p), id); err != nil {
+		return p, fmt.Errorf("sql.GetPatron(%d): %w", id, err)
+	}
+	if p.ID == 0 {
+		return p, sirkulator.ErrNotFound
+	}
+	return p, nil
+}
+
+// GetPatronByCard returns the Patron with the given card number.
+func GetPatronByCard(conn *sqlite.Conn, card string) (sirkulator.Patron, error) {
+	var p sirkulator.Patron
+	q := "SELECT " + patronColumns + " FROM patron WHERE card_number=?"
+	if err := sqlitex.Exec(conn, q, readPatron(&p), card); err != nil {
+		return p, fmt.Errorf("sql.GetPatronByCard(%q): %w", card, err)
+	}
+	if p.ID == 0 {
+		return p, sirkulator.ErrNotFound
+	}
+	return p, nil
+}
+
+func setItemStatus(conn *sqlite.Conn, id int64, status sirkulator.ItemStatus, now time.Time) error {
+	stmt := conn.Prep("UPDATE item SET status=$status, updated_at=$now WHERE id=$id")
+	stmt.SetText("$status", string(status))
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$id", id)
+	_, err := stmt.Step()
+	stmt.Reset()
+	return err
+}
+
+func countActiveLoans(conn *sqlite.Conn, patronID int64) (int, error) {
+	var n int
+	fn := func(stmt *sqlite.Stmt) error {
+		n = stmt.ColumnInt(0)
+		return nil
+	}
+	q := "SELECT count(*) FROM loan WHERE patron_id=? AND checkin_at IS NULL"
+	err := sqlitex.Exec(conn, q, fn, patronID)
+	return n, err
+}
+
+func getActiveLoan(conn *sqlite.Conn, itemID int64) (sirkulator.Loan, error) {
+	var l sirkulator.Loan
+	q := "SELECT " + loanColumns + " FROM loan WHERE item_id=? AND checkin_at IS NULL"
+	if err := sqlitex.Exec(conn, q, readLoan(&l), itemID); err != nil {
+		return l, err
+	}
+	if l.ID == 0 {
+		return l, sirkulator.ErrNotFound
+	}
+	return l, nil
+}
+
+// Checkout lends the item with the given barcode to the patron with
+// the given card number. The due date is determined by the loan policy,
+// which may also block the checkout, and moved to the next day the item's
+// branch is open if necessary.
+func Checkout(conn *sqlite.Conn, barcode, card string) (loan sirkulator.Loan, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	item, err := GetItem(conn, barcode)
+	if err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return loan, sirkulator.Errorf(sirkulator.CodeNotFound, "no item with barcode %s", barcode)
+		}
+		return loan, err
+	}
+	patron, err := GetPatronByCard(conn, card)
+	if err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return loan, sirkulator.Errorf(sirkulator.CodeNotFound, "no patron with card number %s", card)
+		}
+		return loan, err
+	}
+
+	switch item.Status {
+	case sirkulator.ItemAvailable, sirkulator.ItemOnHold:
+		// OK, holds are checked by collectHold below
+	case sirkulator.ItemOnLoan:
+		return loan, sirkulator.Errorf(sirkulator.CodeConflict, "item %s is already on loan", barcode)
+	default:
+		return loan, sirkulator.Errorf(sirkulator.CodeConflict, "item %s has status %s", barcode, item.Status)
+	}
+
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		return loan, err
+	}
+	activeLoans, err := countActiveLoans(conn, patron.ID)
+	if err != nil {
+		return loan, fmt.Errorf("sql.Checkout(%q, %q): %w", barcode, card, err)
+	}
+	balance, err := GetBalance(conn, patron.ID)
+	if err != nil {
+		return loan, err
+	}
+	now := time.Now()
+	dueAt, err := policy.Checkout(patron, item, activeLoans, balance, now)
+	if err != nil {
+		return loan, err
+	}
+	cal, err := GetCalendar(conn, item.Branch)
+	if err != nil {
+		return loan, err
+	}
+	dueAt = cal.NextOpen(dueAt)
+
+	if err := collectHold(conn, item, patron.ID, now); err != nil {
+		var sErr *sirkulator.Error
+		if errors.As(err, &sErr) {
+			return loan, err
+		}
+		return loan, fmt.Errorf("sql.Checkout(%q, %q): %w", barcode, card, err)
+	}
+
+	stmt := conn.Prep(`
+		INSERT INTO loan (item_id, patron_id, checkout_at, due_at)
+			VALUES ($item_id, $patron_id, $now, $due_at)
+		RETURNING id`)
+	stmt.SetInt64("$item_id", item.ID)
+	stmt.SetInt64("$patron_id", patron.ID)
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$due_at", dueAt.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return loan, fmt.Errorf("sql.Checkout(%q, %q): %w", barcode, card, err)
+	}
+	if err := setItemStatus(conn, item.ID, sirkulator.ItemOnLoan, now); err != nil {
+		return loan, fmt.Errorf("sql.Checkout(%q, %q): %w", barcode, card, err)
+	}
+
+	return sirkulator.Loan{
+		ID:         id,
+		ItemID:     item.ID,
+		PatronID:   patron.ID,
+		CheckoutAt: time.Unix(now.Unix(), 0),
+		DueAt:      time.Unix(dueAt.Unix(), 0),
+	}, nil
+}
+
+// collectHold closes the patron's active hold on the publication of the given item,
+// if any, as collected. If the item is set aside for another patron's hold, an
+// error is returned.
+func collectHold(conn *sqlite.Conn, item sirkulator.Item, patronID int64, now time.Time) error {
+	var holds []sirkulator.Hold
+	fn := func(stmt *sqlite.Stmt) error {
+		var h sirkulator.Hold
+		if err := readHold(&h)(stmt); err != nil {
+			return err
+		}
+		holds = append(holds, h)
+		return nil
+	}
+	const q = "SELECT " + holdColumns + `
+		FROM hold
+		WHERE hold.status IN ('waiting', 'ready')
+		  AND (hold.item_id=? OR (hold.publication_id=? AND hold.patron_id=?))`
+	if err := sqlitex.Exec(conn, q, fn, item.ID, item.PublicationID, patronID); err != nil {
+		return err
+	}
+
+	for _, h := range holds {
+		if h.ItemID == item.ID && h.PatronID != patronID {
+			return sirkulator.Errorf(sirkulator.CodeConflict, "item %s is set aside for another patron", item.Barcode)
+		}
+	}
+	for _, h := range holds {
+		if h.PatronID != patronID {
+			continue
+		}
+		stmt := conn.Prep("UPDATE hold SET status='collected', item_id=$item_id, closed_at=$now WHERE id=$id")
+		stmt.SetInt64("$item_id", item.ID)
+		stmt.SetInt64("$now", now.Unix())
+		stmt.SetInt64("$id", h.ID)
+		if _, err := stmt.Step(); err != nil {
+			return err
+		}
+		if h.Status == sirkulator.HoldReady && h.ItemID != item.ID {
+			// The patron borrowed another item than the one set aside,
+			// which can then be passed on to the next in queue.
+			if _, err := resolveHolds(conn, h.ItemID, h.PublicationID, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Checkin returns the item with the given barcode, closing its active loan
+// and charging the patron any fine accrued if the loan is overdue.
+// If there are holds on the item's publication, the item is set aside for
+// the first hold in queue, which is returned. Otherwise the returned hold is nil.
+func Checkin(conn *sqlite.Conn, barcode string) (loan sirkulator.Loan, hold *sirkulator.HoldExp, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	item, err := GetItem(conn, barcode)
+	if err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return loan, nil, sirkulator.Errorf(sirkulator.CodeNotFound, "no item with barcode %s", barcode)
+		}
+		return loan, nil, err
+	}
+
+	loan, err = getActiveLoan(conn, item.ID)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		return loan, nil, sirkulator.Errorf(sirkulator.CodeInvalid, "item %s is not on loan", barcode)
+	} else if err != nil {
+		return loan, nil, fmt.Errorf("sql.Checkin(%q): %w", barcode, err)
+	}
+
+	now := time.Now()
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		return loan, nil, err
+	}
+	if _, err := accrueFine(conn, policy, loan, now); err != nil {
+		return loan, nil, fmt.Errorf("sql.Checkin(%q): %w", barcode, err)
+	}
+
+	stmt := conn.Prep("UPDATE loan SET checkin_at=$now WHERE id=$id")
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$id", loan.ID)
+	if _, err := stmt.Step(); err != nil {
+		return loan, nil, fmt.Errorf("sql.Checkin(%q): %w", barcode, err)
+	}
+	loan.CheckinAt = time.Unix(now.Unix(), 0)
+
+	holdID, err := resolveHolds(conn, item.ID, item.PublicationID, now)
+	if err != nil {
+		return loan, nil, fmt.Errorf("sql.Checkin(%q): %w", barcode, err)
+	}
+	if holdID != 0 {
+		h, err := GetHold(conn, holdID)
+		if err != nil {
+			return loan, nil, fmt.Errorf("sql.Checkin(%q): %w", barcode, err)
+		}
+		hold = &h
+	}
+
+	return loan, hold, nil
+}
+
+// Renew extends the active loan of the item with the given barcode. The
+// new due date is determined by the loan policy, which may also block the renewal,
+// and moved to the next day the item's branch is open if necessary.
+func Renew(conn *sqlite.Conn, barcode string) (loan sirkulator.Loan, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	item, err := GetItem(conn, barcode)
+	if err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return loan, sirkulator.Errorf(sirkulator.CodeNotFound, "no item with barcode %s", barcode)
+		}
+		return loan, err
+	}
+
+	loan, err = getActiveLoan(conn, item.ID)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		return loan, sirkulator.Errorf(sirkulator.CodeInvalid, "item %s is not on loan", barcode)
+	} else if err != nil {
+		return loan, fmt.Errorf("sql.Renew(%q): %w", barcode, err)
+	}
+
+	patron, err := GetPatron(conn, loan.PatronID)
+	if err != nil {
+		return loan, fmt.Errorf("sql.Renew(%q): %w", barcode, err)
+	}
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		return loan, err
+	}
+	var waiting int
+	fn := func(stmt *sqlite.Stmt) error {
+		waiting = stmt.ColumnInt(0)
+		return nil
+	}
+	q := "SELECT count(*) FROM hold WHERE publication_id=? AND status='waiting'"
+	if err := sqlitex.Exec(conn, q, fn, item.PublicationID); err != nil {
+		return loan, fmt.Errorf("sql.Renew(%q): %w", barcode, err)
+	}
+	now := time.Now()
+	if _, err := accrueFine(conn, policy, loan, now); err != nil {
+		return loan, fmt.Errorf("sql.Renew(%q): %w", barcode, err)
+	}
+	balance, err := GetBalance(conn, patron.ID)
+	if err != nil {
+		return loan, err
+	}
+	dueAt, err := policy.Renew(patron, item, loan, waiting, balance, now)
+	if err != nil {
+		return loan, err
+	}
+	cal, err := GetCalendar(conn, item.Branch)
+	if err != nil {
+		return loan, err
+	}
+	dueAt = cal.NextOpen(dueAt)
+
+	stmt := conn.Prep("UPDATE loan SET due_at=$due_at, renewals=renewals+1 WHERE id=$id")
+	stmt.SetInt64("$due_at", dueAt.Unix())
+	stmt.SetInt64("$id", loan.ID)
+	if _, err := stmt.Step(); err != nil {
+		return loan, fmt.Errorf("sql.Renew(%q): %w", barcode, err)
+	}
+	loan.DueAt = time.Unix(dueAt.Unix(), 0)
+	loan.Renewals++
+
+	return loan, nil
+}
+
+const qLoanExp = `
+    SELECT
+        loan.id,
+        loan.item_id,
+        loan.patron_id,
+        loan.checkout_at,
+        loan.due_at,
+        loan.checkin_at,
+        loan.renewals,
+        item.barcode,
+        res.id,
+        res.label,
+        patron.name,
+        patron.card_number
+    FROM loan
+        JOIN item ON (loan.item_id=item.id)
+        JOIN resource res ON (item.publication_id=res.id)
+        JOIN patron ON (loan.patron_id=patron.id)`
+
+func readLoanExps(res *[]sirkulator.LoanExp) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		var l sirkulator.LoanExp
+		if err := readLoan(&l.Loan)(stmt); err != nil {
+			return err
+		}
+		l.Barcode = stmt.ColumnText(7)
+		l.Publication = sirkulator.SimpleResource{
+			Type:  sirkulator.TypePublication,
+			ID:    stmt.ColumnText(8),
+			Label: stmt.ColumnText(9),
+		}
+		l.PatronName = stmt.ColumnText(10)
+		l.PatronCard = stmt.ColumnText(11)
+		*res = append(*res, l)
+		return nil
+	}
+}
+
+// GetPatronLoans returns the active loans of the given Patron, ordered by due date.
+func GetPatronLoans(conn *sqlite.Conn, id int64) ([]sirkulator.LoanExp, error) {
+	var res []sirkulator.LoanExp
+	q := qLoanExp + `
+    WHERE loan.patron_id=? AND loan.checkin_at IS NULL
+    ORDER BY loan.due_at`
+	if err := sqlitex.Exec(conn, q, readLoanExps(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetPatronLoans(%d): %w", id, err)
+	}
+	return res, nil
+}
+
+// GetRecentLoans returns the latest circulation transactions, that is loans
+// ordered by the time of their latest checkout or checkin.
+func GetRecentLoans(conn *sqlite.Conn, limit int) ([]sirkulator.LoanExp, error) {
+	var res []sirkulator.LoanExp
+	q := qLoanExp + `
+    ORDER BY max(loan.checkout_at, IFNULL(loan.checkin_at, 0)) DESC, loan.id DESC
+    LIMIT ?`
+	if err := sqlitex.Exec(conn, q, readLoanExps(&res), limit); err != nil {
+		return res, fmt.Errorf("sql.GetRecentLoans(%d): %w", limit, err)
+	}
+	return res, nil
+}
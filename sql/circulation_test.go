@@ -0,0 +1,374 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/calendar"
+)
+
+func TestCirculation(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateItem(conn, sirkulator.Item{Barcode: "0301", PublicationID: "p1", Branch: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateItem(conn, sirkulator.Item{Barcode: "0301", PublicationID: "p1", Branch: "main"})
+	var sErr *sirkulator.Error
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Fatalf("CreateItem with duplicate barcode got %v; want conflict", err)
+	}
+
+	patron, err := CreatePatron(conn, sirkulator.Patron{CardNumber: "N001", Name: "Knut"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Checkout(conn, "0301", "N001"); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if item, _ := GetItem(conn, "0301"); item.Status != sirkulator.ItemOnLoan {
+		t.Errorf("item status after checkout = %q; want %q", item.Status, sirkulator.ItemOnLoan)
+	}
+	_, err = Checkout(conn, "0301", "N001")
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("Checkout of item on loan got %v; want conflict", err)
+	}
+	_, err = Checkout(conn, "0301", "X999")
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeNotFound {
+		t.Errorf("Checkout to unknown patron got %v; want not found", err)
+	}
+
+	loans, err := GetPatronLoans(conn, patron.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loans) != 1 || loans[0].Barcode != "0301" || loans[0].Publication.Label != "Sult" {
+		t.Errorf("GetPatronLoans = %+v; want one loan of 0301 Sult", loans)
+	}
+
+	loan, err := Renew(conn, "0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loan.Renewals != 1 {
+		t.Errorf("loan renewals = %d; want 1", loan.Renewals)
+	}
+
+	loan, _, err = Checkin(conn, "0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loan.Active() {
+		t.Error("loan still active after checkin")
+	}
+	if item, _ := GetItem(conn, "0301"); item.Status != sirkulator.ItemAvailable {
+		t.Errorf("item status after checkin = %q; want %q", item.Status, sirkulator.ItemAvailable)
+	}
+	_, _, err = Checkin(conn, "0301")
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeInvalid {
+		t.Errorf("Checkin of item not on loan got %v; want invalid", err)
+	}
+
+	recent, err := GetRecentLoans(conn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].PatronCard != "N001" {
+		t.Errorf("GetRecentLoans = %+v; want one loan by N001", recent)
+	}
+}
+
+func TestHolds(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateItem(conn, sirkulator.Item{Barcode: "0301", PublicationID: "p1", Branch: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, card := range []string{"N001", "N002", "N003"} {
+		if _, err := CreatePatron(conn, sirkulator.Patron{CardNumber: card, Name: card, Branch: "main"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Checkout(conn, "0301", "N001"); err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().Add(sirkulator.DefaultHoldExpiry)
+	h2, err := PlaceHold(conn, "p1", "N002", "", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h2.Position != 1 || h2.PickupBranch != "main" {
+		t.Errorf("PlaceHold = %+v; want position 1 at branch main", h2)
+	}
+	_, err = PlaceHold(conn, "p1", "N002", "", expires)
+	var sErr *sirkulator.Error
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("PlaceHold twice got %v; want conflict", err)
+	}
+	h3, err := PlaceHold(conn, "p1", "N003", "", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h3.Position != 2 {
+		t.Errorf("second hold position = %d; want 2", h3.Position)
+	}
+
+	_, hold, err := Checkin(conn, "0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold == nil || hold.ID != h2.ID || hold.Status != sirkulator.HoldReady || hold.Barcode != "0301" {
+		t.Fatalf("Checkin got hold %+v; want hold %d ready", hold, h2.ID)
+	}
+	if item, _ := GetItem(conn, "0301"); item.Status != sirkulator.ItemOnHold {
+		t.Errorf("item status after checkin = %q; want %q", item.Status, sirkulator.ItemOnHold)
+	}
+
+	_, err = Checkout(conn, "0301", "N003")
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("Checkout of item set aside for another patron got %v; want conflict", err)
+	}
+
+	// Uncollected hold expires, and the item is passed on to next in queue.
+	n, err := ExpireHolds(conn, time.Now().Add(sirkulator.DefaultPickupPeriod+time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("ExpireHolds = %d; want 1", n)
+	}
+	holds, err := GetPublicationHolds(conn, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].ID != h3.ID || holds[0].Status != sirkulator.HoldReady {
+		t.Fatalf("GetPublicationHolds = %+v; want hold %d ready", holds, h3.ID)
+	}
+
+	if _, err := Checkout(conn, "0301", "N003"); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := GetHold(conn, h3.ID); h.Status != sirkulator.HoldCollected {
+		t.Errorf("hold status after checkout = %q; want %q", h.Status, sirkulator.HoldCollected)
+	}
+}
+
+func TestLoanRules(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	for _, barcode := range []string{"0301", "0302"} {
+		if _, err := CreateItem(conn, sirkulator.Item{Barcode: barcode, PublicationID: "p1", Branch: "main"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := CreatePatron(conn, sirkulator.Patron{CardNumber: "N001", Name: "Knut", Category: "child"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := SaveLoanRule(conn, sirkulator.LoanRule{PatronCategory: "child", LoanDays: 14, MaxLoans: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = SaveLoanRule(conn, sirkulator.LoanRule{PatronCategory: "child", LoanDays: 7})
+	var sErr *sirkulator.Error
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("SaveLoanRule with duplicate matrix entry got %v; want conflict", err)
+	}
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy) != 2 || policy.Rule("child", "book", "main") != rule {
+		t.Fatalf("GetLoanPolicy = %+v; want default rule and %+v", policy, rule)
+	}
+
+	loan, err := Checkout(conn, "0301", "N001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loan.DueAt.Sub(loan.CheckoutAt); got != 14*24*time.Hour {
+		t.Errorf("loan period = %v; want 14 days", got)
+	}
+	_, err = Checkout(conn, "0302", "N001")
+	if !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("Checkout exceeding max loans got %v; want conflict", err)
+	}
+	if _, err := Renew(conn, "0301"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("Renew exceeding max renewals got %v; want conflict", err)
+	}
+
+	if err := DeleteLoanRule(conn, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Checkout(conn, "0302", "N001"); err != nil {
+		t.Errorf("Checkout after deleting rule got %v; want OK", err)
+	}
+}
+
+func TestDueDateCalendar(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateItem(conn, sirkulator.Item{Barcode: "0301", PublicationID: "p1", Branch: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreatePatron(conn, sirkulator.Patron{CardNumber: "N001", Name: "Knut"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only open on mondays
+	if err := SaveOpeningHours(conn, "main", []calendar.Hours{{Weekday: time.Monday, Opens: "10:00", Closes: "15:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := GetCalendar(conn, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Weekly) != 1 {
+		t.Fatalf("GetCalendar weekly hours = %v; want 1", cal.Weekly)
+	}
+
+	loan, err := Checkout(conn, "0301", "N001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cal.IsOpen(loan.DueAt) {
+		t.Errorf("loan due at %v, when branch is closed", loan.DueAt)
+	}
+	if loan.DueAt.Sub(loan.CheckoutAt) < time.Duration(sirkulator.DefaultLoanRule.LoanDays)*24*time.Hour {
+		t.Errorf("loan due at %v, before end of loan period", loan.DueAt)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, created_at, updated_at)
+			VALUES ('p1', 'publication', 'Sult', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	for _, barcode := range []string{"0301", "0302"} {
+		if _, err := CreateItem(conn, sirkulator.Item{Barcode: barcode, PublicationID: "p1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patron, err := CreatePatron(conn, sirkulator.Patron{CardNumber: "N001", Name: "Knut"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveLoanRule(conn, sirkulator.LoanRule{LoanDays: 28, ItemType: "book", FinePerDay: 500, MaxFine: 5000, MaxBalance: 2000}); err != nil {
+		t.Fatal(err)
+	}
+
+	loan, err := Checkout(conn, "0301", "N001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlitex.Exec(conn, "UPDATE loan SET due_at=? WHERE id=?", nil, time.Now().AddDate(0, 0, -3).Unix(), loan.ID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		// Accruing fines twice the same day should not charge the patron twice.
+		if _, err := AccrueFines(conn, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := GetBalance(conn, patron.ID); err != nil || got != 1500 {
+		t.Fatalf("GetBalance after accruing fines = %d, %v; want 1500", got, err)
+	}
+	if _, err := AccrueFines(conn, time.Now().AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	var sErr *sirkulator.Error
+	if _, err := Checkout(conn, "0302", "N001"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("Checkout with balance above limit got %v; want conflict", err)
+	}
+
+	if _, err := AddLedgerEntry(conn, sirkulator.LedgerEntry{PatronID: patron.ID, Type: sirkulator.LedgerPayment, Amount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddLedgerEntry(conn, sirkulator.LedgerEntry{PatronID: patron.ID, Type: sirkulator.LedgerWaiver, Amount: -10}); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeInvalid {
+		t.Errorf("AddLedgerEntry with negative amount got %v; want invalid", err)
+	}
+	if _, err := Checkout(conn, "0302", "N001"); err != nil {
+		t.Errorf("Checkout after payment got %v; want OK", err)
+	}
+
+	ledger, err := GetLedger(conn, patron.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 3 || ledger[0].Amount != -1000 || ledger[0].Balance != 1500 || ledger[2].Balance != 1500 {
+		t.Errorf("GetLedger = %+v; want 3 entries, newest first, with running balance", ledger)
+	}
+
+	if err := sqlitex.Exec(conn, "UPDATE ledger SET amount=1 WHERE patron_id=?", nil, patron.ID); err == nil {
+		t.Error("updating ledger succeeded; want append-only")
+	}
+	if err := sqlitex.Exec(conn, "DELETE FROM ledger WHERE patron_id=?", nil, patron.ID); err == nil {
+		t.Error("deleting from ledger succeeded; want append-only")
+	}
+}
@@ -3,10 +3,8 @@ package sql
 import (
 	"context"
 	"embed"
-	"errors"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
 	"crawshaw.io/sqlite"
@@ -98,8 +96,8 @@ func attachTo(pool *sqlitex.Pool, dir string, poolSize int, dbs ...database) err
 			}
 
 			if i == 0 {
-				// Run initSchema once per DB
-				if err := initSchema(conn, db); err != nil {
+				// Run migrations once per DB
+				if err := migrate(conn, db); err != nil {
 					return fmt.Errorf("attachTo %v: %w", db, err)
 				}
 			}
@@ -128,89 +126,22 @@ func OpenAt(dir string) (*sqlitex.Pool, error) {
 	return initPool(pool, dir)
 }
 
+// initPool attaches the supplementary databases and runs migrations. The
+// pool is closed if it fails.
 func initPool(pool *sqlitex.Pool, dir string) (*sqlitex.Pool, error) {
 	if err := attachTo(pool, dir, poolSize, dbOAI, dbFiles); err != nil {
-		return pool, fmt.Errorf("sql.OpenAt: %w", err)
+		pool.Close()
+		return nil, fmt.Errorf("sql.OpenAt: %w", err)
 	}
 
+	// Initialize main db with SQL schema and migrations
 	conn := pool.Get(context.Background())
-	defer pool.Put(conn)
-
-	// Initialize main db with SQL schema
-	if err := initSchema(conn, dbMain); err != nil {
+	err := migrate(conn, dbMain)
+	pool.Put(conn)
+	if err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("sql.OpenAt(%s): %w", dir, err)
 	}
 
 	return pool, nil
 }
-
-// initSchema initializes the conn with db schema and runs migrations
-func initSchema(conn *sqlite.Conn, db database) error {
-	version, err := schemaVersion(conn, db)
-	if err != nil {
-		return fmt.Errorf("initSchema: %w", err)
-	}
-
-	if version == 0 {
-		schema, err := assets.ReadFile("assets/" + db.String() + "/schema.sql")
-		if err != nil {
-			return fmt.Errorf("initSchema: read SQL file %w", err)
-		}
-
-		if err := sqlitex.ExecScript(conn, string(schema)); err != nil {
-			return fmt.Errorf("initSchema: exec SQL schema: %w", err)
-		}
-	}
-
-	return runMigrations(conn, db)
-}
-
-func runMigrations(conn *sqlite.Conn, db database) error {
-	path := "assets/" + db.String() + "/migrations"
-	dir, err := assets.ReadDir(path)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("runMigrations: ReadDir: %w", err)
-	}
-
-	version, err := schemaVersion(conn, db)
-	if err != nil {
-		return fmt.Errorf("runMigrations: %w", err)
-	}
-
-	for _, file := range dir { // directory entries are sorted by embed package
-		if file.IsDir() {
-			continue
-		}
-		d, err := strconv.Atoi(strings.Replace(file.Name(), ".sql", "", 1))
-		if err != nil {
-			// silently ignore file names not named \d{4}.sql
-			continue
-		}
-		if d == version {
-			migration, err := assets.ReadFile(path + "/" + file.Name())
-			if err != nil {
-				// TODO pool.Close()
-				return fmt.Errorf("runMigrations: read migration file: %w", err)
-			}
-			if err := sqlitex.ExecScript(conn, string(migration)); err != nil {
-				// TODO pool.Close()
-				return fmt.Errorf("runMigrations: exec migration sql: %w", err)
-			}
-			version++
-		}
-	}
-	return nil
-}
-
-func schemaVersion(conn *sqlite.Conn, db database) (int, error) {
-	var v int
-	fn := func(stmt *sqlite.Stmt) error {
-		v = int(stmt.ColumnInt64(0))
-		return nil
-	}
-	// TODO properly build query string
-	if err := sqlitex.ExecTransient(conn, "PRAGMA "+db.String()+".user_version;", fn); err != nil {
-		return 0, fmt.Errorf("schemaVersion: %w", err)
-	}
-	return v, nil
-}
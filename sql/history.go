@@ -0,0 +1,298 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+// diffResource returns the difference between two versions of a resource,
+// given their labels and data as JSON objects. An empty oldData is treated
+// as an empty object, ie. the resource is new.
+func diffResource(oldLabel string, oldData []byte, newLabel string, newData []byte) (sirkulator.ResourceDiff, error) {
+	var diff sirkulator.ResourceDiff
+	if oldLabel != newLabel {
+		o, _ := json.Marshal(oldLabel)
+		n, _ := json.Marshal(newLabel)
+		diff.Label = &sirkulator.FieldChange{New: n}
+		if oldLabel != "" {
+			diff.Label.Old = o
+		}
+	}
+
+	oldFields := make(map[string]json.RawMessage)
+	newFields := make(map[string]json.RawMessage)
+	if len(oldData) > 0 {
+		if err := json.Unmarshal(oldData, &oldFields); err != nil {
+			return diff, err
+		}
+	}
+	if err := json.Unmarshal(newData, &newFields); err != nil {
+		return diff, err
+	}
+
+	diff.Data = make(map[string]sirkulator.FieldChange)
+	for k, o := range oldFields {
+		n, ok := newFields[k]
+		if !ok {
+			if !jsonEmpty(o) {
+				diff.Data[k] = sirkulator.FieldChange{Old: o}
+			}
+			continue
+		}
+		if !jsonEqual(o, n) {
+			diff.Data[k] = sirkulator.FieldChange{Old: o, New: n}
+		}
+	}
+	for k, n := range newFields {
+		if _, ok := oldFields[k]; !ok && !jsonEmpty(n) {
+			diff.Data[k] = sirkulator.FieldChange{New: n}
+		}
+	}
+	if len(diff.Data) == 0 {
+		diff.Data = nil
+	}
+	return diff, nil
+}
+
+// jsonEmpty reports whether the JSON value is the zero value of its type, in which
+// case a missing field is considered equal to it.
+func jsonEmpty(v json.RawMessage) bool {
+	switch string(bytes.TrimSpace(v)) {
+	case `""`, "null", "[]", "{}", "0", "false":
+		return true
+	}
+	return false
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// RecordEdit records the changes of the given resource, compared to the version currently
+// stored, in the resource's edit history. It must be called before the resource
+// is created or updated. Nothing is recorded if there are no changes.
+func RecordEdit(conn *sqlite.Conn, res sirkulator.Resource, actor string, at time.Time) error {
+	var oldLabel, oldData string
+	fn := func(stmt *sqlite.Stmt) error {
+		oldLabel = stmt.ColumnText(0)
+		oldData = stmt.ColumnText(1)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT label, data FROM resource WHERE id=?", fn, res.ID); err != nil {
+		return fmt.Errorf("sql.RecordEdit(%s): %w", res.ID, err)
+	}
+	newData, err := json.Marshal(res.Data)
+	if err != nil {
+		return fmt.Errorf("sql.RecordEdit(%s): %w", res.ID, err)
+	}
+	diff, err := diffResource(oldLabel, []byte(oldData), res.Label, newData)
+	if err != nil {
+		return fmt.Errorf("sql.RecordEdit(%s): %w", res.ID, err)
+	}
+	if err := insertEdit(conn, res.ID, actor, at, diff); err != nil {
+		return fmt.Errorf("sql.RecordEdit(%s): %w", res.ID, err)
+	}
+	return nil
+}
+
+// insertEdit stores the diff in the edit history of the resource with the given
+// ID, unless it is empty.
+func insertEdit(conn *sqlite.Conn, id, actor string, at time.Time, diff sirkulator.ResourceDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+	b, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	stmt := conn.Prep(`
+		INSERT INTO resource_edit_log (resource_id, at, actor, diff)
+			VALUES ($resource_id, $at, $actor, $diff)`)
+	stmt.SetText("$resource_id", id)
+	stmt.SetInt64("$at", at.Unix())
+	stmt.SetText("$actor", actor)
+	stmt.SetBytes("$diff", b)
+	_, err = stmt.Step()
+	return err
+}
+
+// getLinks returns the links of the resource with the given ID, as stored.
+func getLinks(conn *sqlite.Conn, id string) ([][2]string, error) {
+	links := [][2]string{}
+	fn := func(stmt *sqlite.Stmt) error {
+		links = append(links, [2]string{stmt.ColumnText(0), stmt.ColumnText(1)})
+		return nil
+	}
+	const q = "SELECT type, id FROM link WHERE resource_id=? ORDER BY type, id"
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// linksChange returns the change from the old to the new links, both sorted
+// as returned by getLinks, or nil if they are the same.
+func linksChange(old, new [][2]string) *sirkulator.FieldChange {
+	o, _ := json.Marshal(old)
+	n, _ := json.Marshal(new)
+	if bytes.Equal(o, n) {
+		return nil
+	}
+	return &sirkulator.FieldChange{Old: o, New: n}
+}
+
+// editLinks changes the links of the resource with the given ID by calling fn,
+// and records the change in its edit history, with the given actor as author.
+func editLinks(conn *sqlite.Conn, id, actor string, fn func() error) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	old, err := getLinks(conn, id)
+	if err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	new, err := getLinks(conn, id)
+	if err != nil {
+		return err
+	}
+	change := linksChange(old, new)
+	if change == nil {
+		return nil
+	}
+	now := time.Now()
+	if err := insertEdit(conn, id, actor, now, sirkulator.ResourceDiff{Links: change}); err != nil {
+		return err
+	}
+	return sqlitex.Exec(conn, "UPDATE resource SET updated_at=? WHERE id=?", nil, now.Unix(), id)
+}
+
+// GetResourceHistory returns the edit history of the resource with the given ID,
+// ordered from newest to oldest.
+func GetResourceHistory(conn *sqlite.Conn, id string) ([]sirkulator.ResourceEdit, error) {
+	var res []sirkulator.ResourceEdit
+	fn := func(stmt *sqlite.Stmt) error {
+		e := sirkulator.ResourceEdit{
+			ID:         stmt.ColumnInt64(0),
+			ResourceID: stmt.ColumnText(1),
+			At:         time.Unix(stmt.ColumnInt64(2), 0),
+			Actor:      stmt.ColumnText(3),
+		}
+		if err := json.Unmarshal([]byte(stmt.ColumnText(4)), &e.Diff); err != nil {
+			return err
+		}
+		res = append(res, e)
+		return nil
+	}
+	const q = "SELECT id, resource_id, at, actor, diff FROM resource_edit_log WHERE resource_id=? ORDER BY id DESC"
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetResourceHistory(%s): %w", id, err)
+	}
+	return res, nil
+}
+
+// RevertResource restores the resource with the given ID to the version it had
+// after the given edit, by undoing all later edits, which must not include merges.
+// The revert is itself recorded as an edit by the given actor. The reverted
+// resource is returned.
+func RevertResource(conn *sqlite.Conn, id string, editID int64, actor string) (res sirkulator.Resource, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	history, err := GetResourceHistory(conn, id)
+	if err != nil {
+		return res, err
+	}
+	found := false
+	for _, e := range history {
+		if e.ID == editID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return res, sirkulator.ErrNotFound
+	}
+
+	var typ, label, data string
+	fn := func(stmt *sqlite.Stmt) error {
+		typ = stmt.ColumnText(0)
+		label = stmt.ColumnText(1)
+		data = stmt.ColumnText(2)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT type, label, data FROM resource WHERE id=?", fn, id); err != nil {
+		return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+	}
+
+	// links and archived are nil unless they are reverted
+	var (
+		links    [][2]string
+		archived *bool
+	)
+	for _, e := range history { // newest first
+		if e.ID == editID {
+			break
+		}
+		if e.Diff.MergedFrom != "" || e.Diff.MergedInto != "" {
+			return res, sirkulator.Errorf(sirkulator.CodeInvalid, "cannot revert a merge")
+		}
+		if e.Diff.Archived != nil {
+			archived = new(bool)
+			if err := json.Unmarshal(e.Diff.Archived.Old, archived); err != nil {
+				return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+			}
+		}
+		if e.Diff.Links != nil {
+			links = [][2]string{}
+			if err := json.Unmarshal(e.Diff.Links.Old, &links); err != nil {
+				return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+			}
+		}
+		if e.Diff.Label != nil && len(e.Diff.Label.Old) > 0 {
+			if err := json.Unmarshal(e.Diff.Label.Old, &label); err != nil {
+				return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+			}
+		}
+		for k, c := range e.Diff.Data {
+			if len(c.Old) == 0 {
+				delete(fields, k)
+			} else {
+				fields[k] = c.Old
+			}
+		}
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+	}
+	if err := UpdateResource(conn, sirkulator.Resource{ID: id, Data: json.RawMessage(b)}, label, actor); err != nil {
+		return res, err
+	}
+	if links != nil {
+		if err := UpdateLinks(conn, id, links, actor); err != nil {
+			return res, err
+		}
+	}
+	if archived != nil {
+		if err := setArchived(conn, id, actor, *archived); err != nil {
+			return res, fmt.Errorf("sql.RevertResource(%s, %d): %w", id, editID, err)
+		}
+	}
+	return GetResource(conn, sirkulator.ParseResourceType(typ), id)
+}
@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+func TestResourceHistory(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('c1', 'corporation', 'Gyldendal', '{"name":"Gyldendal"}', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(c sirkulator.Corporation, actor string) {
+		t.Helper()
+		if err := UpdateResource(conn, sirkulator.Resource{ID: "c1", Data: c}, c.Label(), actor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(sirkulator.Corporation{Name: "Gyldendal", Description: "forlag"}, "kari")
+	update(sirkulator.Corporation{Name: "Gyldendal Norsk Forlag", Description: "forlag"}, "ola")
+	update(sirkulator.Corporation{Name: "Gyldendal Norsk Forlag", Description: "forlag"}, "ola") // no changes
+
+	history, err := GetResourceHistory(conn, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d edits; want 2", len(history))
+	}
+	latest := history[0]
+	if latest.Actor != "ola" || latest.Diff.Label == nil || string(latest.Diff.Label.Old) != `"Gyldendal"` {
+		t.Errorf("latest edit = %+v; want label change by ola", latest)
+	}
+	if c, ok := history[1].Diff.Data["description"]; !ok || len(c.Old) != 0 || string(c.New) != `"forlag"` {
+		t.Errorf("first edit = %+v; want description added", history[1])
+	}
+
+	res, err := RevertResource(conn, "c1", history[1].ID, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corp := res.Data.(*sirkulator.Corporation)
+	if res.Label != "Gyldendal" || corp.Name != "Gyldendal" || corp.Description != "forlag" {
+		t.Errorf("reverted resource = %+v %+v; want state after first edit", res, corp)
+	}
+	if history, _ = GetResourceHistory(conn, "c1"); len(history) != 3 || history[0].Actor != "admin" {
+		t.Errorf("revert not recorded in history: %+v", history)
+	}
+
+	if err := RecordEdit(conn, sirkulator.Resource{ID: "c2", Label: "Aschehoug", Data: sirkulator.Corporation{Name: "Aschehoug"}}, "import", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if history, _ = GetResourceHistory(conn, "c2"); len(history) != 1 || len(history[0].Diff.Label.Old) != 0 {
+		t.Errorf("creation not recorded in history: %+v", history)
+	}
+}
@@ -0,0 +1,335 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+// The position of a waiting hold is the number of waiting holds on the same
+// publication placed before it, plus one.
+const holdColumns = `
+        hold.id,
+        hold.publication_id,
+        hold.patron_id,
+        hold.pickup_branch,
+        hold.status,
+        IIF(hold.status='waiting', (
+            SELECT count(*) FROM hold h
+             WHERE h.publication_id=hold.publication_id AND h.status='waiting' AND h.id <= hold.id
+        ), 0),
+        IFNULL(hold.item_id, 0),
+        hold.created_at,
+        IFNULL(hold.ready_at, 0),
+        hold.expires_at,
+        IFNULL(hold.closed_at, 0)`
+
+func readHold(h *sirkulator.Hold) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		h.ID = stmt.ColumnInt64(0)
+		h.PublicationID = stmt.ColumnText(1)
+		h.PatronID = stmt.ColumnInt64(2)
+		h.PickupBranch = stmt.ColumnText(3)
+		h.Status = sirkulator.HoldStatus(stmt.ColumnText(4))
+		h.Position = stmt.ColumnInt(5)
+		h.ItemID = stmt.ColumnInt64(6)
+		h.CreatedAt = time.Unix(stmt.ColumnInt64(7), 0)
+		if n := stmt.ColumnInt64(8); n != 0 {
+			h.ReadyAt = time.Unix(n, 0)
+		}
+		h.ExpiresAt = time.Unix(stmt.ColumnInt64(9), 0)
+		if n := stmt.ColumnInt64(10); n != 0 {
+			h.ClosedAt = time.Unix(n, 0)
+		}
+		return nil
+	}
+}
+
+const qHoldExp = `
+    SELECT` + holdColumns + `,
+        res.label,
+        patron.name,
+        patron.card_number,
+        IFNULL(item.barcode, '')
+    FROM hold
+        JOIN resource res ON (hold.publication_id=res.id)
+        JOIN patron ON (hold.patron_id=patron.id)
+        LEFT JOIN item ON (hold.item_id=item.id)`
+
+func readHoldExps(res *[]sirkulator.HoldExp) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		var h sirkulator.HoldExp
+		if err := readHold(&h.Hold)(stmt); err != nil {
+			return err
+		}
+		h.Publication = sirkulator.SimpleResource{
+			Type:  sirkulator.TypePublication,
+			ID:    h.PublicationID,
+			Label: stmt.ColumnText(11),
+		}
+		h.PatronName = stmt.ColumnText(12)
+		h.PatronCard = stmt.ColumnText(13)
+		h.Barcode = stmt.ColumnText(14)
+		*res = append(*res, h)
+		return nil
+	}
+}
+
+// GetHold returns the Hold with the given ID.
+func GetHold(conn *sqlite.Conn, id int64) (sirkulator.HoldExp, error) {
+	var res []sirkulator.HoldExp
+	if err := sqlitex.Exec(conn, qHoldExp+" WHERE hold.id=?", readHoldExps(&res), id); err != nil {
+		return sirkulator.HoldExp{}, fmt.Errorf("sql.GetHold(%d): %w", id, err)
+	}
+	if len(res) == 0 {
+		return sirkulator.HoldExp{}, sirkulator.ErrNotFound
+	}
+	return res[0], nil
+}
+
+// PlaceHold places a hold on the given Publication for the patron with the given
+// card number. The hold is kept in queue until the given expiry time.
+func PlaceHold(conn *sqlite.Conn, publicationID, card, pickupBranch string, expiresAt time.Time) (hold sirkulator.Hold, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	patron, err := GetPatronByCard(conn, card)
+	if err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return hold, sirkulator.Errorf(sirkulator.CodeNotFound, "no patron with card number %s", card)
+		}
+		return hold, err
+	}
+
+	var found bool
+	fn := func(stmt *sqlite.Stmt) error {
+		found = true
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT 1 FROM resource WHERE id=? AND type='publication'", fn, publicationID); err != nil {
+		return hold, fmt.Errorf("sql.PlaceHold(%q, %q): %w", publicationID, card, err)
+	}
+	if !found {
+		return hold, sirkulator.Errorf(sirkulator.CodeNotFound, "no publication with id %s", publicationID)
+	}
+
+	items, err := GetPublicationItems(conn, publicationID)
+	if err != nil {
+		return hold, err
+	}
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		return hold, err
+	}
+	if err := policy.Hold(patron, items); err != nil {
+		return hold, err
+	}
+
+	if pickupBranch == "" {
+		pickupBranch = patron.Branch
+	}
+
+	now := time.Now()
+	stmt := conn.Prep(`
+		INSERT INTO hold (publication_id, patron_id, pickup_branch, created_at, expires_at)
+			VALUES ($publication_id, $patron_id, $pickup_branch, $now, $expires_at)
+		RETURNING id`)
+	stmt.SetText("$publication_id", publicationID)
+	stmt.SetInt64("$patron_id", patron.ID)
+	stmt.SetText("$pickup_branch", pickupBranch)
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$expires_at", expiresAt.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return hold, sirkulator.Errorf(sirkulator.CodeConflict, "patron %s already has a hold on this publication", card)
+	} else if err != nil {
+		return hold, fmt.Errorf("sql.PlaceHold(%q, %q): %w", publicationID, card, err)
+	}
+
+	h, err := GetHold(conn, id)
+	if err != nil {
+		return hold, fmt.Errorf("sql.PlaceHold(%q, %q): %w", publicationID, card, err)
+	}
+	return h.Hold, nil
+}
+
+// CancelHold cancels the hold with the given ID. If an item is
+// set aside for the hold, it is passed on to the next hold in queue.
+func CancelHold(conn *sqlite.Conn, id int64) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	h, err := GetHold(conn, id)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		return sirkulator.Errorf(sirkulator.CodeNotFound, "no hold with id %d", id)
+	} else if err != nil {
+		return err
+	}
+	if !h.Active() {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "hold is already %s", h.Status)
+	}
+
+	now := time.Now()
+	if err := closeHold(conn, id, sirkulator.HoldCancelled, now); err != nil {
+		return fmt.Errorf("sql.CancelHold(%d): %w", id, err)
+	}
+	if h.Status == sirkulator.HoldReady {
+		if _, err := resolveHolds(conn, h.ItemID, h.PublicationID, now); err != nil {
+			return fmt.Errorf("sql.CancelHold(%d): %w", id, err)
+		}
+	}
+	return nil
+}
+
+func closeHold(conn *sqlite.Conn, id int64, status sirkulator.HoldStatus, now time.Time) error {
+	stmt := conn.Prep("UPDATE hold SET status=$status, closed_at=$now WHERE id=$id")
+	stmt.SetText("$status", string(status))
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$id", id)
+	_, err := stmt.Step()
+	stmt.Reset()
+	return err
+}
+
+// resolveHolds sets aside the given item for the first non-expired hold in queue
+// on the given publication, returning the hold ID, or 0 if there are no holds,
+// in which case the item is made available.
+func resolveHolds(conn *sqlite.Conn, itemID int64, publicationID string, now time.Time) (int64, error) {
+	var holdID int64
+	fn := func(stmt *sqlite.Stmt) error {
+		holdID = stmt.ColumnInt64(0)
+		return nil
+	}
+	const q = `
+		SELECT id FROM hold
+		 WHERE publication_id=? AND status='waiting' AND expires_at > ?
+		 ORDER BY id
+		 LIMIT 1`
+	if err := sqlitex.Exec(conn, q, fn, publicationID, now.Unix()); err != nil {
+		return 0, err
+	}
+	if holdID == 0 {
+		return 0, setItemStatus(conn, itemID, sirkulator.ItemAvailable, now)
+	}
+
+	stmt := conn.Prep(`
+		UPDATE hold
+		   SET status='ready', item_id=$item_id, ready_at=$now, expires_at=$expires_at
+		 WHERE id=$id`)
+	stmt.SetInt64("$item_id", itemID)
+	stmt.SetInt64("$now", now.Unix())
+	stmt.SetInt64("$expires_at", now.Add(sirkulator.DefaultPickupPeriod).Unix())
+	stmt.SetInt64("$id", holdID)
+	if _, err := stmt.Step(); err != nil {
+		return 0, err
+	}
+	return holdID, setItemStatus(conn, itemID, sirkulator.ItemOnHold, now)
+}
+
+// GetPublicationHolds returns the active holds on the given Publication, the holds
+// ready for pickup first, followed by the waiting holds in queue order.
+func GetPublicationHolds(conn *sqlite.Conn, id string) ([]sirkulator.HoldExp, error) {
+	var res []sirkulator.HoldExp
+	q := qHoldExp + `
+    WHERE hold.publication_id=? AND hold.status IN ('waiting', 'ready')
+    ORDER BY hold.status='waiting', hold.id`
+	if err := sqlitex.Exec(conn, q, readHoldExps(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetPublicationHolds(%q): %w", id, err)
+	}
+	return res, nil
+}
+
+// GetPatronHolds returns the active holds of the given Patron.
+func GetPatronHolds(conn *sqlite.Conn, id int64) ([]sirkulator.HoldExp, error) {
+	var res []sirkulator.HoldExp
+	q := qHoldExp + `
+    WHERE hold.patron_id=? AND hold.status IN ('waiting', 'ready')
+    ORDER BY hold.status='waiting', hold.id`
+	if err := sqlitex.Exec(conn, q, readHoldExps(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetPatronHolds(%d): %w", id, err)
+	}
+	return res, nil
+}
+
+// GetReadyHolds returns all holds ready for pickup, ordered by pickup deadline.
+func GetReadyHolds(conn *sqlite.Conn) ([]sirkulator.HoldExp, error) {
+	var res []sirkulator.HoldExp
+	q := qHoldExp + `
+    WHERE hold.status='ready'
+    ORDER BY hold.expires_at`
+	if err := sqlitex.Exec(conn, q, readHoldExps(&res)); err != nil {
+		return res, fmt.Errorf("sql.GetReadyHolds: %w", err)
+	}
+	return res, nil
+}
+
+// ExpireHolds expires all active holds past their expiry time, and passes the items
+// set aside for uncollected holds on to the next hold in queue. It returns the
+// number of expired holds.
+func ExpireHolds(conn *sqlite.Conn, now time.Time) (n int, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	type expired struct {
+		id            int64
+		itemID        int64
+		publicationID string
+	}
+	var holds []expired
+	fn := func(stmt *sqlite.Stmt) error {
+		holds = append(holds, expired{
+			id:            stmt.ColumnInt64(0),
+			itemID:        stmt.ColumnInt64(1),
+			publicationID: stmt.ColumnText(2),
+		})
+		return nil
+	}
+	const q = `
+		SELECT id, IFNULL(item_id, 0), publication_id FROM hold
+		 WHERE status IN ('waiting', 'ready') AND expires_at <= ?
+		 ORDER BY id`
+	if err := sqlitex.Exec(conn, q, fn, now.Unix()); err != nil {
+		return 0, fmt.Errorf("sql.ExpireHolds: %w", err)
+	}
+
+	for _, h := range holds {
+		if err := closeHold(conn, h.id, sirkulator.HoldExpired, now); err != nil {
+			return n, fmt.Errorf("sql.ExpireHolds: %w", err)
+		}
+		if h.itemID != 0 {
+			if _, err := resolveHolds(conn, h.itemID, h.publicationID, now); err != nil {
+				return n, fmt.Errorf("sql.ExpireHolds: %w", err)
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ExpireHoldsJob is a job which expires holds no longer wanted by patrons,
+// as well as holds not collected before the pickup deadline.
+type ExpireHoldsJob struct {
+	DB *sqlitex.Pool
+}
+
+func (j *ExpireHoldsJob) Name() string {
+	return "expire_holds"
+}
+
+func (j *ExpireHoldsJob) Run(ctx context.Context, w io.Writer) error {
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	n, err := ExpireHolds(conn, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "expired %d holds\n", n)
+	return nil
+}
@@ -0,0 +1,180 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+// AddLedgerEntry appends the given transaction to the patron's ledger. The amount
+// must be positive; it is stored as negative for payments and waivers.
+func AddLedgerEntry(conn *sqlite.Conn, e sirkulator.LedgerEntry) (sirkulator.LedgerEntry, error) {
+	switch e.Type {
+	case sirkulator.LedgerFine, sirkulator.LedgerFee, sirkulator.LedgerPayment, sirkulator.LedgerWaiver:
+	default:
+		return e, sirkulator.Errorf(sirkulator.CodeInvalid, "unknown ledger transaction type: %q", e.Type)
+	}
+	if e.Amount <= 0 {
+		return e, sirkulator.Errorf(sirkulator.CodeInvalid, "amount must be greater than zero")
+	}
+	if e.Type.Credit() {
+		e.Amount = -e.Amount
+	}
+
+	now := time.Now()
+	stmt := conn.Prep(`
+		INSERT INTO ledger (patron_id, loan_id, type, amount, note, created_at)
+			VALUES ($patron_id, NULLIF($loan_id, 0), $type, $amount, $note, $now)
+		RETURNING id`)
+	stmt.SetInt64("$patron_id", e.PatronID)
+	stmt.SetInt64("$loan_id", e.LoanID)
+	stmt.SetText("$type", string(e.Type))
+	stmt.SetInt64("$amount", e.Amount)
+	stmt.SetText("$note", e.Note)
+	stmt.SetInt64("$now", now.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return e, fmt.Errorf("sql.AddLedgerEntry(%d): %w", e.PatronID, err)
+	}
+	e.ID = id
+	e.CreatedAt = time.Unix(now.Unix(), 0)
+	return e, nil
+}
+
+// GetLedger returns all transactions in the patron's ledger, with running
+// balance, ordered from newest to oldest.
+func GetLedger(conn *sqlite.Conn, patronID int64) ([]sirkulator.LedgerEntry, error) {
+	var res []sirkulator.LedgerEntry
+	const q = `
+		SELECT id, patron_id, IFNULL(loan_id, 0), type, amount, note, created_at,
+		       SUM(amount) OVER (ORDER BY id)
+		  FROM ledger
+		 WHERE patron_id=?
+		 ORDER BY id DESC`
+	fn := func(stmt *sqlite.Stmt) error {
+		res = append(res, sirkulator.LedgerEntry{
+			ID:        stmt.ColumnInt64(0),
+			PatronID:  stmt.ColumnInt64(1),
+			LoanID:    stmt.ColumnInt64(2),
+			Type:      sirkulator.LedgerType(stmt.ColumnText(3)),
+			Amount:    stmt.ColumnInt64(4),
+			Note:      stmt.ColumnText(5),
+			CreatedAt: time.Unix(stmt.ColumnInt64(6), 0),
+			Balance:   stmt.ColumnInt64(7),
+		})
+		return nil
+	}
+	if err := sqlitex.Exec(conn, q, fn, patronID); err != nil {
+		return res, fmt.Errorf("sql.GetLedger(%d): %w", patronID, err)
+	}
+	return res, nil
+}
+
+// GetBalance returns the patron's balance of fines and fees.
+func GetBalance(conn *sqlite.Conn, patronID int64) (int64, error) {
+	stmt := conn.Prep("SELECT IFNULL(SUM(amount), 0) FROM ledger WHERE patron_id=$patron_id")
+	stmt.SetInt64("$patron_id", patronID)
+	n, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return 0, fmt.Errorf("sql.GetBalance(%d): %w", patronID, err)
+	}
+	return n, nil
+}
+
+// accrueFine charges the patron the fine accrued on the given loan according to
+// the loan policy, minus any fines already charged for it. It returns the amount charged.
+func accrueFine(conn *sqlite.Conn, policy sirkulator.LoanPolicy, loan sirkulator.Loan, now time.Time) (int64, error) {
+	item, err := getItemByID(conn, loan.ItemID)
+	if err != nil {
+		return 0, err
+	}
+	patron, err := GetPatron(conn, loan.PatronID)
+	if err != nil {
+		return 0, err
+	}
+	fine := policy.Rule(patron.Category, item.Type, item.Branch).Fine(loan.DueAt, now)
+	if fine <= 0 {
+		return 0, nil
+	}
+
+	stmt := conn.Prep("SELECT IFNULL(SUM(amount), 0) FROM ledger WHERE loan_id=$loan_id AND type='fine'")
+	stmt.SetInt64("$loan_id", loan.ID)
+	charged, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return 0, err
+	}
+	if fine <= charged {
+		return 0, nil
+	}
+	_, err = AddLedgerEntry(conn, sirkulator.LedgerEntry{
+		PatronID: patron.ID,
+		LoanID:   loan.ID,
+		Type:     sirkulator.LedgerFine,
+		Amount:   fine - charged,
+		Note:     item.Barcode,
+	})
+	return fine - charged, err
+}
+
+// AccrueFines charges fines for all loans overdue at the given time, according to the
+// loan policy. It returns the number of loans charged.
+func AccrueFines(conn *sqlite.Conn, now time.Time) (n int, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	policy, err := GetLoanPolicy(conn)
+	if err != nil {
+		return 0, err
+	}
+	var loans []sirkulator.Loan
+	fn := func(stmt *sqlite.Stmt) error {
+		var l sirkulator.Loan
+		if err := readLoan(&l)(stmt); err != nil {
+			return err
+		}
+		loans = append(loans, l)
+		return nil
+	}
+	q := "SELECT " + loanColumns + " FROM loan WHERE checkin_at IS NULL AND due_at < ?"
+	if err := sqlitex.Exec(conn, q, fn, now.Unix()); err != nil {
+		return 0, fmt.Errorf("sql.AccrueFines: %w", err)
+	}
+	for _, loan := range loans {
+		amount, err := accrueFine(conn, policy, loan, now)
+		if err != nil {
+			return n, fmt.Errorf("sql.AccrueFines: loan %d: %w", loan.ID, err)
+		}
+		if amount > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// AccrueFinesJob is a job which charges patrons fines for overdue loans.
+type AccrueFinesJob struct {
+	DB *sqlitex.Pool
+}
+
+func (j *AccrueFinesJob) Name() string {
+	return "accrue_fines"
+}
+
+func (j *AccrueFinesJob) Run(ctx context.Context, w io.Writer) error {
+	conn := j.DB.Get(ctx)
+	if conn == nil {
+		return context.Canceled
+	}
+	defer j.DB.Put(conn)
+
+	n, err := AccrueFines(conn, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "charged fines on %d loans\n", n)
+	return nil
+}
@@ -0,0 +1,171 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+// MergeResources merges the resource with ID fromID into the resource with ID toID.
+// All relations, links, texts and the image of the merged resource are moved to
+// the surviving resource, unless it already has an image. The merged resource is
+// archived, and a redirect to the survivor is stored. The merge is recorded in the
+// edit history of both resources, with the given actor as author. The surviving
+// resource is returned.
+func MergeResources(conn *sqlite.Conn, fromID, toID, actor string) (res sirkulator.Resource, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if fromID == toID {
+		return res, sirkulator.Errorf(sirkulator.CodeInvalid, "cannot merge a resource with itself")
+	}
+
+	type resourceState struct {
+		typ      string
+		archived bool
+	}
+	states := make(map[string]resourceState)
+	fn := func(stmt *sqlite.Stmt) error {
+		states[stmt.ColumnText(0)] = resourceState{
+			typ:      stmt.ColumnText(1),
+			archived: stmt.ColumnInt64(2) != 0,
+		}
+		return nil
+	}
+	const q = "SELECT id, type, archived_at FROM resource WHERE id IN (?, ?)"
+	if err := sqlitex.Exec(conn, q, fn, fromID, toID); err != nil {
+		return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+	}
+	from, ok := states[fromID]
+	if !ok {
+		return res, sirkulator.ErrNotFound
+	}
+	to, ok := states[toID]
+	if !ok {
+		return res, sirkulator.Errorf(sirkulator.CodeNotFound, "resource %s does not exist", toID)
+	}
+	if from.typ != to.typ {
+		return res, sirkulator.Errorf(sirkulator.CodeInvalid, "cannot merge a %s with a %s", from.typ, to.typ)
+	}
+	if from.archived || to.archived {
+		return res, sirkulator.Errorf(sirkulator.CodeInvalid, "cannot merge archived resources")
+	}
+
+	fromLinks, err := getLinks(conn, fromID)
+	if err != nil {
+		return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+	}
+	toLinks, err := getLinks(conn, toID)
+	if err != nil {
+		return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+	}
+
+	at := time.Now()
+	now := at.Unix()
+	stmts := []struct {
+		q    string
+		args []any
+	}{
+		// Relations, removing those which became duplicates or point to the survivor itself.
+		{"UPDATE relation SET from_id=? WHERE from_id=?", []any{toID, fromID}},
+		{"UPDATE relation SET to_id=? WHERE to_id=?", []any{toID, fromID}},
+		{"DELETE FROM relation WHERE from_id=?1 AND to_id=?1", []any{toID}},
+		{`DELETE FROM relation WHERE id IN (
+			SELECT r1.id FROM relation r1
+			  JOIN relation r2 ON (
+			       r1.from_id=r2.from_id AND r1.to_id=r2.to_id AND r1.type=r2.type
+			       AND coalesce(r1.data, '') = coalesce(r2.data, '')
+			       AND r1.id > r2.id)
+			 WHERE r1.from_id=?1 OR r1.to_id=?1)`, []any{toID}},
+		// Links
+		{"INSERT OR IGNORE INTO link (resource_id, type, id) SELECT ?, type, id FROM link WHERE resource_id=?", []any{toID, fromID}},
+		{"DELETE FROM link WHERE resource_id=?", []any{fromID}},
+		// Texts
+		{"UPDATE resource_text SET resource_id=? WHERE resource_id=?", []any{toID, fromID}},
+		// Image; the survivor keeps its own if it has one.
+		{"UPDATE files.image SET id=?1 WHERE id=?2 AND NOT EXISTS (SELECT 1 FROM files.image WHERE id=?1)", []any{toID, fromID}},
+		{"DELETE FROM files.image WHERE id=?", []any{fromID}},
+		// Redirects, including those already pointing to the merged resource.
+		{"UPDATE resource_redirect SET to_id=? WHERE to_id=?", []any{toID, fromID}},
+		{"INSERT INTO resource_redirect (from_id, to_id, created_at) VALUES (?, ?, ?)", []any{fromID, toID, now}},
+		// Archive the merged resource
+		{"UPDATE resource SET archived_at=?1, updated_at=?1 WHERE id=?2", []any{now, fromID}},
+		{"UPDATE resource SET updated_at=? WHERE id=?", []any{now, toID}},
+	}
+	for _, s := range stmts {
+		if err := sqlitex.Exec(conn, s.q, nil, s.args...); err != nil {
+			return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+		}
+	}
+
+	mergedLinks, err := getLinks(conn, toID)
+	if err != nil {
+		return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+	}
+	edits := []struct {
+		id   string
+		diff sirkulator.ResourceDiff
+	}{
+		{toID, sirkulator.ResourceDiff{Links: linksChange(toLinks, mergedLinks), MergedFrom: fromID}},
+		{fromID, sirkulator.ResourceDiff{Links: linksChange(fromLinks, [][2]string{}), MergedInto: toID}},
+	}
+	for _, e := range edits {
+		if err := insertEdit(conn, e.id, actor, at, e.diff); err != nil {
+			return res, fmt.Errorf("sql.MergeResources(%s, %s): %w", fromID, toID, err)
+		}
+	}
+
+	return GetResource(conn, sirkulator.ParseResourceType(to.typ), toID)
+}
+
+// GetMergeCandidates returns resources which are possible duplicates of the
+// resource with the given ID, ie. those of the same type with the same label,
+// ignoring case. Archived resources are not included.
+func GetMergeCandidates(conn *sqlite.Conn, id string) ([]sirkulator.SimpleResource, error) {
+	var res []sirkulator.SimpleResource
+	fn := func(stmt *sqlite.Stmt) error {
+		res = append(res, sirkulator.SimpleResource{
+			Type:  sirkulator.ParseResourceType(stmt.ColumnText(1)),
+			ID:    stmt.ColumnText(0),
+			Label: stmt.ColumnText(2),
+		})
+		return nil
+	}
+	const q = `
+		SELECT r.id, r.type, r.label
+		  FROM resource r
+		  JOIN resource self ON (self.id=? AND r.type=self.type AND lower(r.label)=lower(self.label))
+		 WHERE r.id != self.id
+		   AND r.archived_at IS NULL
+		 ORDER BY r.created_at`
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetMergeCandidates(%s): %w", id, err)
+	}
+	return res, nil
+}
+
+// GetRedirect returns the resource which the resource with the given ID has been
+// merged into. If there is no redirect for the ID, sirkulator.ErrNotFound is returned.
+func GetRedirect(conn *sqlite.Conn, id string) (sirkulator.SimpleResource, error) {
+	var res sirkulator.SimpleResource
+	fn := func(stmt *sqlite.Stmt) error {
+		res.ID = stmt.ColumnText(0)
+		res.Type = sirkulator.ParseResourceType(stmt.ColumnText(1))
+		res.Label = stmt.ColumnText(2)
+		return nil
+	}
+	const q = `
+		SELECT r.id, r.type, r.label
+		  FROM resource_redirect
+		  JOIN resource r ON (r.id = resource_redirect.to_id)
+		 WHERE resource_redirect.from_id=?`
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetRedirect(%s): %w", id, err)
+	}
+	if res.ID == "" {
+		return res, sirkulator.ErrNotFound
+	}
+	return res, nil
+}
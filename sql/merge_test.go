@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+func TestMergeResources(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{"name":"Knut Hamsun"}', 0, 0),
+			       ('p2', 'person', 'Hamsun, Knut', '{"name":"Knut Hamsun"}', 0, 0),
+			       ('p3', 'person', 'Undset, Sigrid', '{"name":"Sigrid Undset"}', 0, 0),
+			       ('b1', 'publication', 'Sult', '{"title":"Sult"}', 0, 0),
+			       ('b2', 'publication', 'Pan', '{"title":"Pan"}', 0, 0),
+			       ('c1', 'corporation', 'Hamsun, Knut', '{"name":"Hamsun"}', 0, 0);
+		INSERT INTO relation (from_id, to_id, type, data)
+			VALUES ('b1', 'p1', 'has_contributor', '{"role":"aut"}'),
+			       ('b1', 'p2', 'has_contributor', '{"role":"aut"}'),
+			       ('b2', 'p2', 'has_contributor', '{"role":"aut"}'),
+			       ('p2', 'p1', 'same_as', NULL);
+		INSERT INTO link (resource_id, type, id)
+			VALUES ('p1', 'viaf', '1'), ('p2', 'viaf', '1'), ('p2', 'bibsys', '2');
+		INSERT INTO resource_text (resource_id, text, source, updated_at)
+			VALUES ('p2', 'Norsk forfatter', 'local', 0);
+		INSERT INTO files.image (id, type, width, height, size, data)
+			VALUES ('p2', 'jpeg', 1, 1, 1, x'00');
+		INSERT INTO resource_redirect (from_id, to_id, created_at)
+			VALUES ('p3', 'p2', 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MergeResources(conn, "p2", "c1", "test"); err == nil {
+		t.Error("merging resources of different types succeeded; want error")
+	}
+	if _, err := MergeResources(conn, "p2", "p2", "test"); err == nil {
+		t.Error("merging resource with itself succeeded; want error")
+	}
+	if _, err := MergeResources(conn, "px", "p1", "test"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("merging non-existing resource: got %v; want ErrNotFound", err)
+	}
+
+	candidates, err := GetMergeCandidates(conn, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].ID != "p2" {
+		t.Errorf("GetMergeCandidates(p1) = %v; want [p2]", candidates)
+	}
+
+	res, err := MergeResources(conn, "p2", "p1", "kari")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != "p1" || len(res.Links) != 2 {
+		t.Errorf("merged resource = %+v; want p1 with 2 links", res)
+	}
+
+	count := func(q, id string) int64 {
+		t.Helper()
+		stmt := conn.Prep(q)
+		stmt.BindText(1, id)
+		n, err := sqlitex.ResultInt64(stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	for _, test := range []struct {
+		q    string
+		id   string
+		want int64
+	}{
+		{"SELECT count(*) FROM relation WHERE to_id=?1 OR from_id=?1", "p2", 0},
+		{"SELECT count(*) FROM relation WHERE to_id=?", "p1", 2},
+		{"SELECT count(*) FROM link WHERE resource_id=?", "p2", 0},
+		{"SELECT count(*) FROM resource_text WHERE resource_id=?", "p1", 1},
+		{"SELECT count(*) FROM files.image WHERE id=?", "p1", 1},
+		{"SELECT count(*) FROM resource_redirect WHERE to_id=?", "p1", 2},
+		{"SELECT count(*) FROM resource WHERE archived_at IS NOT NULL AND id=?", "p2", 1},
+		{"SELECT count(*) FROM resource_edit_log WHERE actor='kari' AND json_extract(diff, '$.merged_from')=?", "p2", 1},
+		{"SELECT count(*) FROM resource_edit_log WHERE actor='kari' AND json_extract(diff, '$.merged_into')=?", "p1", 1},
+	} {
+		if got := count(test.q, test.id); got != test.want {
+			t.Errorf("%s %v = %d; want %d", test.q, test.id, got, test.want)
+		}
+	}
+
+	for _, id := range []string{"p2", "p3"} {
+		if to, err := GetRedirect(conn, id); err != nil || to.ID != "p1" {
+			t.Errorf("GetRedirect(%s) = %v, %v; want p1", id, to, err)
+		}
+	}
+	if _, err := GetRedirect(conn, "p1"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("GetRedirect(p1): got %v; want ErrNotFound", err)
+	}
+
+	if _, err := MergeResources(conn, "p2", "p1", "test"); err == nil {
+		t.Error("merging archived resource succeeded; want error")
+	}
+}
@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+// Migration is a change to the schema of one of the databases, taking it from
+// Version to Version+1, as recorded in the database's PRAGMA user_version.
+// The initial schema (schema.sql) is the migration from version 0.
+type Migration struct {
+	DB      string // main|oai|files
+	Version int
+	File    string
+	SQL     string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%s: %s (version %d -> %d)", m.DB, m.File, m.Version, m.Version+1)
+}
+
+// migrations returns all the embedded migrations of the given database, ordered
+// by version. The migration files must be named 0001.sql, 0002.sql and so on,
+// without gaps in the sequence.
+func migrations(db database) ([]Migration, error) {
+	schema, err := assets.ReadFile("assets/" + db.String() + "/schema.sql")
+	if err != nil {
+		return nil, fmt.Errorf("migrations(%s): %w", db, err)
+	}
+	res := []Migration{{DB: db.String(), Version: 0, File: "schema.sql", SQL: string(schema)}}
+
+	path := "assets/" + db.String() + "/migrations"
+	dir, err := assets.ReadDir(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("migrations(%s): %w", db, err)
+	}
+	for _, file := range dir { // directory entries are sorted by the embed package
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".sql") {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".sql"))
+		if err != nil {
+			return nil, fmt.Errorf("migrations(%s): invalid migration file name: %s", db, file.Name())
+		}
+		if n != len(res) {
+			return nil, fmt.Errorf("migrations(%s): %s is out of sequence; want %04d.sql", db, file.Name(), len(res))
+		}
+		b, err := assets.ReadFile(path + "/" + file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrations(%s): %w", db, err)
+		}
+		res = append(res, Migration{DB: db.String(), Version: n, File: "migrations/" + file.Name(), SQL: string(b)})
+	}
+	return res, nil
+}
+
+// pending returns the migrations which must be applied to a database at the
+// given version. It is an error if the version is newer than the latest
+// migration, as the database has been migrated by a newer version of the program.
+func pending(db database, version int) ([]Migration, error) {
+	all, err := migrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(all) {
+		return nil, fmt.Errorf("database %s has schema version %d, which is newer than the latest known version %d; refusing to start", db, version, len(all))
+	}
+	return all[version:], nil
+}
+
+// migrate brings the schema of the given database up to date, by applying
+// all pending migrations. Each migration is run in its own transaction, which
+// is rolled back unless the migration leaves the database at the expected version.
+func migrate(conn *sqlite.Conn, db database) error {
+	version, err := schemaVersion(conn, db.String())
+	if err != nil {
+		return fmt.Errorf("migrate(%s): %w", db, err)
+	}
+	todo, err := pending(db, version)
+	if err != nil {
+		return fmt.Errorf("migrate(%s): %w", db, err)
+	}
+	for _, m := range todo {
+		if err := m.run(conn); err != nil {
+			return fmt.Errorf("migrate(%s): %w", db, err)
+		}
+	}
+	return nil
+}
+
+func (m Migration) run(conn *sqlite.Conn) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := sqlitex.ExecScript(conn, m.SQL); err != nil {
+		return fmt.Errorf("%s: %w", m.File, err)
+	}
+	v, err := schemaVersion(conn, m.DB)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.File, err)
+	}
+	if v != m.Version+1 {
+		return fmt.Errorf("%s: schema version is %d after migration; want %d", m.File, v, m.Version+1)
+	}
+	return nil
+}
+
+func schemaVersion(conn *sqlite.Conn, schema string) (int, error) {
+	var v int
+	fn := func(stmt *sqlite.Stmt) error {
+		v = int(stmt.ColumnInt64(0))
+		return nil
+	}
+	// TODO properly build query string
+	if err := sqlitex.ExecTransient(conn, "PRAGMA "+schema+".user_version;", fn); err != nil {
+		return 0, fmt.Errorf("schemaVersion: %w", err)
+	}
+	return v, nil
+}
+
+// PendingMigrations returns the migrations which would be applied when opening
+// the databases in the given directory with OpenAt, without applying them. The
+// databases are opened read-only, and are not created if they don't exist.
+func PendingMigrations(dir string) ([]Migration, error) {
+	var res []Migration
+	for _, db := range []database{dbMain, dbOAI, dbFiles} {
+		version := 0
+		file := filepath.Join(dir, db.String()+".db")
+		if _, err := os.Stat(file); err == nil {
+			conn, err := sqlite.OpenConn("file:"+file+"?mode=ro", sqlite.SQLITE_OPEN_READONLY|sqlite.SQLITE_OPEN_URI)
+			if err != nil {
+				return nil, fmt.Errorf("sql.PendingMigrations(%s): %w", dir, err)
+			}
+			version, err = schemaVersion(conn, "main")
+			conn.Close()
+			if err != nil {
+				return nil, fmt.Errorf("sql.PendingMigrations(%s): %w", dir, err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("sql.PendingMigrations(%s): %w", dir, err)
+		}
+		todo, err := pending(db, version)
+		if err != nil {
+			return nil, fmt.Errorf("sql.PendingMigrations(%s): %w", dir, err)
+		}
+		res = append(res, todo...)
+	}
+	return res, nil
+}
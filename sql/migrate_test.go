@@ -0,0 +1,68 @@
+package sql
+
+import (
+	"strings"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+func TestMigrations(t *testing.T) {
+	for _, db := range []database{dbMain, dbOAI, dbFiles} {
+		ms, err := migrations(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range ms {
+			if m.Version != i {
+				t.Errorf("%s: migration %s has version %d; want %d", db, m.File, m.Version, i)
+			}
+		}
+	}
+}
+
+func TestOpenAtMigrations(t *testing.T) {
+	dir := t.TempDir()
+
+	todo, err := PendingMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todo) == 0 || todo[0].File != "schema.sql" {
+		t.Fatalf("PendingMigrations on empty dir = %v; want all migrations", todo)
+	}
+
+	db, err := OpenAt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo, err := PendingMigrations(dir); err != nil || len(todo) != 0 {
+		t.Errorf("PendingMigrations after OpenAt = %v, %v; want none", todo, err)
+	}
+	conn := db.Get(nil)
+
+	// A failing migration is rolled back.
+	bad := Migration{DB: "main", Version: 1000, File: "bad.sql", SQL: "CREATE TABLE bad (a TEXT);"}
+	if err := bad.run(conn); err == nil {
+		t.Error("migration not setting user_version succeeded; want error")
+	}
+	if _, err := conn.Prepare("SELECT * FROM bad"); err == nil {
+		t.Error("table created by failed migration exists")
+	}
+
+	// Pretend the database was migrated by a newer version.
+	if err := sqlitex.ExecScript(conn, "PRAGMA files.user_version = 1000;"); err != nil {
+		t.Fatal(err)
+	}
+	db.Put(conn)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PendingMigrations(dir); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("PendingMigrations with newer schema: got %v; want error", err)
+	}
+	if _, err := OpenAt(dir); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("OpenAt with newer schema: got %v; want error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+const noticeColumns = "id, patron_id, IFNULL(loan_id, 0), type, recipient, subject, body, status, error, attempts, created_at, IFNULL(sent_at, 0)"
+
+func readNotices(res *[]sirkulator.Notice) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		n := sirkulator.Notice{
+			ID:        stmt.ColumnInt64(0),
+			PatronID:  stmt.ColumnInt64(1),
+			LoanID:    stmt.ColumnInt64(2),
+			Type:      stmt.ColumnText(3),
+			Recipient: stmt.ColumnText(4),
+			Subject:   stmt.ColumnText(5),
+			Body:      stmt.ColumnText(6),
+			Status:    sirkulator.NoticeStatus(stmt.ColumnText(7)),
+			Error:     stmt.ColumnText(8),
+			Attempts:  stmt.ColumnInt(9),
+			CreatedAt: time.Unix(stmt.ColumnInt64(10), 0),
+		}
+		if t := stmt.ColumnInt64(11); t != 0 {
+			n.SentAt = time.Unix(t, 0)
+		}
+		*res = append(*res, n)
+		return nil
+	}
+}
+
+// GetOverdueLoans returns all active loans which are past their due date at the given time,
+// ordered by due date.
+func GetOverdueLoans(conn *sqlite.Conn, now time.Time) ([]sirkulator.LoanExp, error) {
+	var res []sirkulator.LoanExp
+	q := qLoanExp + `
+    WHERE loan.checkin_at IS NULL AND loan.due_at < ?
+    ORDER BY loan.due_at`
+	if err := sqlitex.Exec(conn, q, readLoanExps(&res), now.Unix()); err != nil {
+		return res, fmt.Errorf("sql.GetOverdueLoans: %w", err)
+	}
+	return res, nil
+}
+
+// GetLoanNoticeTypes returns the types of notices created for the given loan.
+func GetLoanNoticeTypes(conn *sqlite.Conn, loanID int64) ([]string, error) {
+	var res []string
+	fn := func(stmt *sqlite.Stmt) error {
+		res = append(res, stmt.ColumnText(0))
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT type FROM notice WHERE loan_id=?", fn, loanID); err != nil {
+		return res, fmt.Errorf("sql.GetLoanNoticeTypes(%d): %w", loanID, err)
+	}
+	return res, nil
+}
+
+// CreateNotice adds the given notice to the queue of pending notices.
+func CreateNotice(conn *sqlite.Conn, n sirkulator.Notice) (sirkulator.Notice, error) {
+	now := time.Now()
+	stmt := conn.Prep(`
+		INSERT INTO notice (patron_id, loan_id, type, recipient, subject, body, created_at)
+			VALUES ($patron_id, NULLIF($loan_id, 0), $type, $recipient, $subject, $body, $now)
+		RETURNING id`)
+	stmt.SetInt64("$patron_id", n.PatronID)
+	stmt.SetInt64("$loan_id", n.LoanID)
+	stmt.SetText("$type", n.Type)
+	stmt.SetText("$recipient", n.Recipient)
+	stmt.SetText("$subject", n.Subject)
+	stmt.SetText("$body", n.Body)
+	stmt.SetInt64("$now", now.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return n, sirkulator.Errorf(sirkulator.CodeConflict, "notice of type %s already created for loan %d", n.Type, n.LoanID)
+	} else if err != nil {
+		return n, fmt.Errorf("sql.CreateNotice(%q): %w", n.Type, err)
+	}
+	n.ID = id
+	n.Status = sirkulator.NoticePending
+	n.CreatedAt = time.Unix(now.Unix(), 0)
+	return n, nil
+}
+
+// GetUndeliveredNotices returns notices which are pending, or failed fewer than
+// maxAttempts times, oldest first.
+func GetUndeliveredNotices(conn *sqlite.Conn, maxAttempts int) ([]sirkulator.Notice, error) {
+	var res []sirkulator.Notice
+	q := "SELECT " + noticeColumns + " FROM notice WHERE status IN ('pending', 'failed') AND attempts < ? ORDER BY id"
+	if err := sqlitex.Exec(conn, q, readNotices(&res), maxAttempts); err != nil {
+		return res, fmt.Errorf("sql.GetUndeliveredNotices: %w", err)
+	}
+	return res, nil
+}
+
+// GetNotices returns the latest notices, newest first.
+func GetNotices(conn *sqlite.Conn, limit int) ([]sirkulator.Notice, error) {
+	var res []sirkulator.Notice
+	q := "SELECT " + noticeColumns + " FROM notice ORDER BY id DESC LIMIT ?"
+	if err := sqlitex.Exec(conn, q, readNotices(&res), limit); err != nil {
+		return res, fmt.Errorf("sql.GetNotices: %w", err)
+	}
+	return res, nil
+}
+
+// SetNoticeDelivered records the outcome of an attempt to deliver the given
+// notice. If deliveryErr is nil, the notice is marked as sent, otherwise as failed.
+func SetNoticeDelivered(conn *sqlite.Conn, id int64, deliveryErr error) error {
+	stmt := conn.Prep(`
+		UPDATE notice
+		   SET status=$status,
+		       error=$error,
+		       attempts=attempts+1,
+		       sent_at=NULLIF($sent_at, 0)
+		 WHERE id=$id`)
+	stmt.SetInt64("$id", id)
+	if deliveryErr == nil {
+		stmt.SetText("$status", string(sirkulator.NoticeSent))
+		stmt.SetText("$error", "")
+		stmt.SetInt64("$sent_at", time.Now().Unix())
+	} else {
+		stmt.SetText("$status", string(sirkulator.NoticeFailed))
+		stmt.SetText("$error", deliveryErr.Error())
+		stmt.SetInt64("$sent_at", 0)
+	}
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("sql.SetNoticeDelivered(%d): %w", id, err)
+	}
+	return nil
+}
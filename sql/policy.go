@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+// GetLoanPolicy returns all loan rules, ordered by patron category, item type and branch.
+func GetLoanPolicy(conn *sqlite.Conn) (sirkulator.LoanPolicy, error) {
+	var res sirkulator.LoanPolicy
+	const q = `
+		SELECT id, patron_category, item_type, branch, loan_days, max_renewals, max_loans, holds_allowed,
+		       fine_per_day, max_fine, max_balance
+		  FROM loan_rule
+		 ORDER BY patron_category, item_type, branch`
+	fn := func(stmt *sqlite.Stmt) error {
+		res = append(res, sirkulator.LoanRule{
+			ID:             stmt.ColumnInt64(0),
+			PatronCategory: stmt.ColumnText(1),
+			ItemType:       stmt.ColumnText(2),
+			Branch:         stmt.ColumnText(3),
+			LoanDays:       stmt.ColumnInt(4),
+			MaxRenewals:    stmt.ColumnInt(5),
+			MaxLoans:       stmt.ColumnInt(6),
+			HoldsAllowed:   stmt.ColumnInt(7) == 1,
+			FinePerDay:     stmt.ColumnInt64(8),
+			MaxFine:        stmt.ColumnInt64(9),
+			MaxBalance:     stmt.ColumnInt64(10),
+		})
+		return nil
+	}
+	if err := sqlitex.Exec(conn, q, fn); err != nil {
+		return res, fmt.Errorf("sql.GetLoanPolicy: %w", err)
+	}
+	return res, nil
+}
+
+// SaveLoanRule persists the given loan rule, creating it if ID is 0, otherwise updating it.
+func SaveLoanRule(conn *sqlite.Conn, rule sirkulator.LoanRule) (sirkulator.LoanRule, error) {
+	var stmt *sqlite.Stmt
+	if rule.ID == 0 {
+		stmt = conn.Prep(`
+			INSERT INTO loan_rule (patron_category, item_type, branch, loan_days, max_renewals, max_loans, holds_allowed,
+			                       fine_per_day, max_fine, max_balance)
+				VALUES ($patron_category, $item_type, $branch, $loan_days, $max_renewals, $max_loans, $holds_allowed,
+				        $fine_per_day, $max_fine, $max_balance)
+			RETURNING id`)
+	} else {
+		stmt = conn.Prep(`
+			UPDATE loan_rule
+			   SET patron_category=$patron_category,
+			       item_type=$item_type,
+			       branch=$branch,
+			       loan_days=$loan_days,
+			       max_renewals=$max_renewals,
+			       max_loans=$max_loans,
+			       holds_allowed=$holds_allowed,
+			       fine_per_day=$fine_per_day,
+			       max_fine=$max_fine,
+			       max_balance=$max_balance
+			 WHERE id=$id
+			RETURNING id`)
+		stmt.SetInt64("$id", rule.ID)
+	}
+	stmt.SetText("$patron_category", rule.PatronCategory)
+	stmt.SetText("$item_type", rule.ItemType)
+	stmt.SetText("$branch", rule.Branch)
+	stmt.SetInt64("$loan_days", int64(rule.LoanDays))
+	stmt.SetInt64("$max_renewals", int64(rule.MaxRenewals))
+	stmt.SetInt64("$max_loans", int64(rule.MaxLoans))
+	stmt.SetBool("$holds_allowed", rule.HoldsAllowed)
+	stmt.SetInt64("$fine_per_day", rule.FinePerDay)
+	stmt.SetInt64("$max_fine", rule.MaxFine)
+	stmt.SetInt64("$max_balance", rule.MaxBalance)
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return rule, sirkulator.Errorf(sirkulator.CodeConflict, "a rule for this combination of patron category, item type and branch already exists")
+	} else if errors.Is(err, sqlitex.ErrNoResults) {
+		return rule, sirkulator.ErrNotFound
+	} else if err != nil {
+		return rule, fmt.Errorf("sql.SaveLoanRule(%d): %w", rule.ID, err)
+	}
+	rule.ID = id
+	return rule, nil
+}
+
+// DeleteLoanRule deletes the loan rule with the given ID.
+func DeleteLoanRule(conn *sqlite.Conn, id int64) error {
+	stmt := conn.Prep("DELETE FROM loan_rule WHERE id=$id")
+	stmt.SetInt64("$id", id)
+	if _, err := stmt.Step(); err != nil {
+		return fmt.Errorf("sql.DeleteLoanRule(%d): %w", id, err)
+	}
+	return nil
+}
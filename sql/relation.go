@@ -0,0 +1,265 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/isbn"
+	"github.com/knakk/sirkulator/marc"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+func readRelations(res *[]sirkulator.Relation) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		rel := sirkulator.Relation{
+			ID:     stmt.ColumnInt64(0),
+			FromID: stmt.ColumnText(1),
+			ToID:   stmt.ColumnText(2),
+			Type:   stmt.ColumnText(3),
+		}
+		if data := stmt.ColumnText(4); data != "" {
+			if err := json.Unmarshal([]byte(data), &rel.Data); err != nil {
+				return err
+			}
+		}
+		*res = append(*res, rel)
+		return nil
+	}
+}
+
+// GetRelation returns the relation with the given ID.
+func GetRelation(conn *sqlite.Conn, id int64) (sirkulator.Relation, error) {
+	var res []sirkulator.Relation
+	const q = "SELECT id, from_id, to_id, type, data FROM relation WHERE id=?"
+	if err := sqlitex.Exec(conn, q, readRelations(&res), id); err != nil {
+		return sirkulator.Relation{}, fmt.Errorf("sql.GetRelation(%d): %w", id, err)
+	}
+	if len(res) == 0 {
+		return sirkulator.Relation{}, sirkulator.ErrNotFound
+	}
+	return res[0], nil
+}
+
+// GetRelations returns all relations to and from the resource with the given ID,
+// including unresolved relations (reviews), where ToID is empty.
+func GetRelations(conn *sqlite.Conn, id string) ([]sirkulator.Relation, error) {
+	var res []sirkulator.Relation
+	const q = "SELECT id, from_id, to_id, type, data FROM relation WHERE from_id=?1 OR to_id=?1 ORDER BY id"
+	if err := sqlitex.Exec(conn, q, readRelations(&res), id); err != nil {
+		return res, fmt.Errorf("sql.GetRelations(%s): %w", id, err)
+	}
+	return res, nil
+}
+
+// checkRelation verifies that the resources of the relation exist, and that
+// the relation type is allowed between resources of their types. A relation
+// without ToID is a review, which must have a label in its data. The role of
+// a contributor relation must be a known Marc relator, and yearly relations,
+// like award nominations, must have a year.
+func checkRelation(conn *sqlite.Conn, rel sirkulator.Relation) error {
+	relType := vocab.ParseRelation(rel.Type)
+	if relType == vocab.RelationInvalid {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "unknown relation type: %q", rel.Type)
+	}
+	if relType.Yearly() {
+		if _, ok := relationYear(rel); !ok {
+			return sirkulator.Errorf(sirkulator.CodeInvalid, "relation %s must have a year", rel.Type)
+		}
+	}
+	if rel.ToID == "" {
+		if label, _ := rel.Data["label"].(string); label == "" {
+			return sirkulator.Errorf(sirkulator.CodeInvalid, "relation without to_id must have a label")
+		}
+	}
+	if role, ok := rel.Data["role"]; ok {
+		code, _ := role.(string)
+		if _, err := marc.ParseRelator(code); err != nil {
+			return sirkulator.Errorf(sirkulator.CodeInvalid, "unknown role: %v", role)
+		}
+	}
+	fromType, err := resourceType(conn, rel.FromID)
+	if err != nil {
+		return err
+	}
+	if rel.ToID == "" {
+		if !relType.AllowedFrom(fromType) {
+			return sirkulator.Errorf(sirkulator.CodeInvalid, "relation %s is not allowed from a %s", rel.Type, fromType)
+		}
+		return nil
+	}
+	toType, err := resourceType(conn, rel.ToID)
+	if err != nil {
+		return err
+	}
+	if !relType.Allowed(fromType, toType) {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "relation %s is not allowed from a %s to a %s", rel.Type, fromType, toType)
+	}
+	return nil
+}
+
+// relationYear returns the year in the data of the given relation, and
+// reports whether it is set.
+func relationYear(rel sirkulator.Relation) (int, bool) {
+	switch y := rel.Data["year"].(type) {
+	case int:
+		return y, y != 0
+	case float64: // as decoded from JSON
+		return int(y), y != 0 && y == float64(int(y))
+	default:
+		return 0, false
+	}
+}
+
+// resourceType returns the type of the resource with the given ID,
+// or an error if the resource does not exist.
+func resourceType(conn *sqlite.Conn, id string) (string, error) {
+	res, err := GetSimpleResource(conn, id)
+	if errors.Is(err, sirkulator.ErrNotFound) {
+		return "", sirkulator.Errorf(sirkulator.CodeInvalid, "resource %q does not exist", id)
+	}
+	return res.Type.String(), err
+}
+
+func setRelationParams(stmt *sqlite.Stmt, rel sirkulator.Relation) error {
+	stmt.SetText("$from_id", rel.FromID)
+	stmt.SetText("$type", rel.Type)
+	if rel.ToID == "" {
+		stmt.SetNull("$to_id")
+		stmt.SetInt64("$queued_at", time.Now().Unix())
+	} else {
+		stmt.SetText("$to_id", rel.ToID)
+		stmt.SetNull("$queued_at")
+	}
+	if rel.Data == nil {
+		stmt.SetNull("$data")
+	} else {
+		b, err := json.Marshal(rel.Data)
+		if err != nil {
+			return err
+		}
+		stmt.SetBytes("$data", b)
+	}
+	return nil
+}
+
+// CreateRelation stores the given relation, and returns it with its ID set.
+// If the relation has no ToID, it is queued for review.
+func CreateRelation(conn *sqlite.Conn, rel sirkulator.Relation) (sirkulator.Relation, error) {
+	if err := checkRelation(conn, rel); err != nil {
+		return rel, err
+	}
+	stmt := conn.Prep(`
+		INSERT INTO relation (from_id, to_id, type, data, queued_at)
+			VALUES ($from_id, $to_id, $type, $data, $queued_at)
+		RETURNING id`)
+	if err := setRelationParams(stmt, rel); err != nil {
+		return rel, fmt.Errorf("sql.CreateRelation: %w", err)
+	}
+	id, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return rel, fmt.Errorf("sql.CreateRelation: %w", err)
+	}
+	rel.ID = id
+	return rel, nil
+}
+
+// UpdateRelation updates the relation with the ID of the given relation.
+// Setting ToID of a relation resolves it, if it was queued for review.
+func UpdateRelation(conn *sqlite.Conn, rel sirkulator.Relation) error {
+	if err := checkRelation(conn, rel); err != nil {
+		return err
+	}
+	stmt := conn.Prep(`
+		UPDATE relation
+		   SET from_id=$from_id, to_id=$to_id, type=$type, data=$data,
+		       queued_at=IIF($to_id IS NULL, coalesce(queued_at, $queued_at), NULL)
+		 WHERE id=$id
+		RETURNING id`)
+	stmt.SetInt64("$id", rel.ID)
+	if err := setRelationParams(stmt, rel); err != nil {
+		return fmt.Errorf("sql.UpdateRelation(%d): %w", rel.ID, err)
+	}
+	_, err := sqlitex.ResultInt64(stmt)
+	if errors.Is(err, sqlitex.ErrNoResults) {
+		return sirkulator.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("sql.UpdateRelation(%d): %w", rel.ID, err)
+	}
+	return nil
+}
+
+// DeleteRelation deletes the relation with the given ID.
+func DeleteRelation(conn *sqlite.Conn, id int64) error {
+	stmt := conn.Prep("DELETE FROM relation WHERE id=$id RETURNING id")
+	stmt.SetInt64("$id", id)
+	_, err := sqlitex.ResultInt64(stmt)
+	if errors.Is(err, sqlitex.ErrNoResults) {
+		return sirkulator.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("sql.DeleteRelation(%d): %w", id, err)
+	}
+	return nil
+}
+
+// SetLinks replaces the links of the resource with the given ID.
+func SetLinks(conn *sqlite.Conn, id string, links [][2]string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := sqlitex.Exec(conn, "DELETE FROM link WHERE resource_id=?", nil, id); err != nil {
+		return fmt.Errorf("sql.SetLinks(%s): %w", id, err)
+	}
+	for _, link := range links {
+		if link[0] == "" || link[1] == "" {
+			return sirkulator.Errorf(sirkulator.CodeInvalid, "link must have both type and id")
+		}
+		v := link[1]
+		if link[0] == "isbn" {
+			v = isbn.Clean(v)
+		}
+		const q = "INSERT OR IGNORE INTO link (resource_id, type, id) VALUES (?, ?, ?)"
+		if err := sqlitex.Exec(conn, q, nil, id, link[0], v); err != nil {
+			return fmt.Errorf("sql.SetLinks(%s): %w", id, err)
+		}
+	}
+	return nil
+}
+
+// UpdateLinks replaces all the links of the resource with the given ID, and
+// records the change in its edit history, with the given actor as author.
+func UpdateLinks(conn *sqlite.Conn, id string, links [][2]string, actor string) error {
+	err := editLinks(conn, id, actor, func() error {
+		return SetLinks(conn, id, links)
+	})
+	if err != nil {
+		return fmt.Errorf("sql.UpdateLinks(%s): %w", id, err)
+	}
+	return nil
+}
+
+// DeleteLink deletes the link of the given type and ID from the resource with the
+// given ID, and records the change in its edit history, with the given actor as author.
+func DeleteLink(conn *sqlite.Conn, resourceID, typ, id, actor string) error {
+	if typ == "isbn" {
+		id = isbn.Clean(id)
+	}
+	err := editLinks(conn, resourceID, actor, func() error {
+		stmt := conn.Prep("DELETE FROM link WHERE resource_id=$resource_id AND type=$type AND id=$id RETURNING id")
+		stmt.SetText("$resource_id", resourceID)
+		stmt.SetText("$type", typ)
+		stmt.SetText("$id", id)
+		_, err := sqlitex.ResultText(stmt)
+		if errors.Is(err, sqlitex.ErrNoResults) {
+			return sirkulator.ErrNotFound
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, sirkulator.ErrNotFound) {
+		return fmt.Errorf("sql.DeleteLink(%s, %s, %s): %w", resourceID, typ, id, err)
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+func TestRelationsAndLinks(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{}', 0, 0),
+			       ('b1', 'publication', 'Sult', '{}', 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", ToID: "px", Type: "has_contributor"}); err == nil {
+		t.Error("creating relation to non-existing resource succeeded; want error")
+	}
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", Type: "has_contributor"}); err == nil {
+		t.Error("creating review without label succeeded; want error")
+	}
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", ToID: "p1", Type: "published_by"}); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("creating published_by relation to a person: got %v; want invalid", err)
+	}
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "p1", ToID: "b1", Type: "has_contributor"}); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("creating has_contributor relation from a person: got %v; want invalid", err)
+	}
+	if _, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", ToID: "p1", Type: "has_contributor", Data: map[string]any{"role": "xyz"}}); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("creating contributor relation with unknown role: got %v; want invalid", err)
+	}
+
+	review, err := CreateRelation(conn, sirkulator.Relation{
+		FromID: "b1",
+		Type:   "has_contributor",
+		Data:   map[string]any{"label": "Hamsun, Knut", "role": "aut"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviews, _ := GetPublcationReviews(conn, "b1"); len(reviews) != 1 {
+		t.Errorf("got %d reviews; want 1", len(reviews))
+	}
+
+	// Resolving the review
+	review.ToID = "p1"
+	if err := UpdateRelation(conn, review); err != nil {
+		t.Fatal(err)
+	}
+	if reviews, _ := GetPublcationReviews(conn, "b1"); len(reviews) != 0 {
+		t.Errorf("got %d reviews after resolving; want 0", len(reviews))
+	}
+	rels, err := GetRelations(conn, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 1 || rels[0].ID != review.ID || rels[0].Data["role"] != "aut" {
+		t.Errorf("GetRelations(p1) = %+v; want the resolved relation", rels)
+	}
+
+	if err := DeleteRelation(conn, review.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetRelation(conn, review.ID); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("GetRelation after delete: got %v; want ErrNotFound", err)
+	}
+	if err := DeleteRelation(conn, review.ID); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("DeleteRelation twice: got %v; want ErrNotFound", err)
+	}
+
+	if err := UpdateLinks(conn, "b1", [][2]string{{"isbn", "82-02-01856-0"}, {"bibsys/pub", "1"}}, "kari"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteLink(conn, "b1", "isbn", "82-02-01856-0", "ola"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteLink(conn, "b1", "isbn", "82-02-01856-0", "ola"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("DeleteLink twice: got %v; want ErrNotFound", err)
+	}
+	res, err := GetResource(conn, sirkulator.TypePublication, "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Links) != 1 || res.Links[0] != [2]string{"bibsys/pub", "1"} {
+		t.Errorf("links = %v; want [[bibsys/pub 1]]", res.Links)
+	}
+	if res.UpdatedAt.Unix() == 0 {
+		t.Error("updated_at not set by link changes")
+	}
+	history, err := GetResourceHistory(conn, "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Actor != "ola" || history[0].Diff.Links == nil ||
+		string(history[0].Diff.Links.New) != `[["bibsys/pub","1"]]` {
+		t.Errorf("link changes not recorded in history: %+v", history)
+	}
+
+	if err := ArchiveResource(conn, "b1", "kari"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ArchiveResource(conn, "b1", "kari"); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := GetResource(conn, sirkulator.TypePublication, "b1"); res.ArchivedAt.IsZero() {
+		t.Error("resource not archived")
+	}
+	if history, _ = GetResourceHistory(conn, "b1"); len(history) != 3 || history[0].Actor != "kari" ||
+		history[0].Diff.Archived == nil || string(history[0].Diff.Archived.New) != "true" {
+		t.Errorf("archival not recorded once in history: %+v", history)
+	}
+
+	if _, err := RevertResource(conn, "b1", history[1].ID, "ola"); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := GetResource(conn, sirkulator.TypePublication, "b1"); !res.ArchivedAt.IsZero() {
+		t.Error("resource still archived after reverting archival")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,26 +35,11 @@ func readResource(res *sirkulator.Resource, t sirkulator.ResourceType) func(stmt
 }
 
 func readData(res *sirkulator.Resource, t sirkulator.ResourceType) func(stmt *sqlite.Stmt) error {
-	// TODO try rewrite with generics
-	switch t {
-	case sirkulator.TypePerson:
-		res.Data = &sirkulator.Person{}
-		return readResource(res, t)
-	case sirkulator.TypePublication:
-		res.Data = &sirkulator.Publication{}
-		return readResource(res, t)
-	case sirkulator.TypeCorporation:
-		res.Data = &sirkulator.Corporation{}
-		return readResource(res, t)
-	case sirkulator.TypeDewey:
-		res.Data = &sirkulator.Dewey{}
-		return readResource(res, t)
-	case sirkulator.TypePublisher:
-		res.Data = &sirkulator.Publisher{}
-		return readResource(res, t)
-	default:
+	res.Data = t.NewData()
+	if res.Data == nil {
 		panic("sql.GetResource: readData: TODO")
 	}
+	return readResource(res, t)
 }
 
 func readLinks(res *sirkulator.Resource) func(stmt *sqlite.Stmt) error {
@@ -86,6 +74,24 @@ func GetResource(conn *sqlite.Conn, t sirkulator.ResourceType, id string) (sirku
 	return res, nil
 }
 
+// GetSimpleResource returns the type and label of the resource with the given ID.
+func GetSimpleResource(conn *sqlite.Conn, id string) (sirkulator.SimpleResource, error) {
+	var res sirkulator.SimpleResource
+	fn := func(stmt *sqlite.Stmt) error {
+		res.ID = stmt.ColumnText(0)
+		res.Type = sirkulator.ParseResourceType(stmt.ColumnText(1))
+		res.Label = stmt.ColumnText(2)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT id, type, label FROM resource WHERE id=?", fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetSimpleResource(%s): %w", id, err)
+	}
+	if res.ID == "" {
+		return res, sirkulator.ErrNotFound
+	}
+	return res, nil
+}
+
 func GetDeweyParts(conn *sqlite.Conn, id string) ([][2]string, error) {
 	const q = `
       SELECT res.id, res.label
@@ -487,6 +493,60 @@ func GetPublisherPublications(conn *sqlite.Conn, id string, sortBy string, sortA
 	return res, nil
 }
 
+// GetSeriesPublications returns the publications in the series with the given ID,
+// in numbering order. Publications without a number come last, ordered by year.
+func GetSeriesPublications(conn *sqlite.Conn, id string) ([]sirkulator.SeriesPublication, error) {
+	var res []sirkulator.SeriesPublication
+	const q = `
+    SELECT
+        r.from_id,
+        resource.label,
+        coalesce(json_extract(r.data, '$.number'), ''),
+        json_extract(resource.data, '$.year')
+    FROM
+        relation r
+        JOIN resource ON (from_id=resource.id)
+    WHERE
+        r.type='in_series'
+    AND to_id=?
+    AND resource.archived_at IS NULL
+    ORDER BY 4, 2`
+
+	fn := func(stmt *sqlite.Stmt) error {
+		p := sirkulator.SeriesPublication{}
+		p.ID = stmt.ColumnText(0)
+		p.Type = sirkulator.TypePublication
+		p.Label = stmt.ColumnText(1)
+		p.Number = stmt.ColumnText(2)
+		p.Year = stmt.ColumnInt(3)
+		res = append(res, p)
+		return nil
+	}
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetSeriesPublications(%q): %w", id, err)
+	}
+
+	// Numbers are not always plain integers ("12", "volume 5", "2022:3"), so
+	// they are sorted by their first run of digits.
+	sort.SliceStable(res, func(i, j int) bool {
+		a, aok := seriesNumber(res[i].Number)
+		b, bok := seriesNumber(res[j].Number)
+		if aok != bok {
+			return aok
+		}
+		return a < b
+	})
+	return res, nil
+}
+
+var rxpDigits = regexp.MustCompile(`\d+`)
+
+// seriesNumber returns the first number in s, and whether there is one.
+func seriesNumber(s string) (int, bool) {
+	n, err := strconv.Atoi(rxpDigits.FindString(s))
+	return n, err == nil
+}
+
 func GetPublcationContributors(conn *sqlite.Conn, id string) ([]sirkulator.PublicationContribution, error) {
 	var res []sirkulator.PublicationContribution
 
@@ -591,7 +651,7 @@ func GetRelationsAsObject(conn *sqlite.Conn, id string) ([]sirkulator.RelationEx
         LEFT JOIN resource res ON (rel.from_id=res.id)
     WHERE
         rel.to_id=? AND
-        rel.type != 'has_contributor'`
+        rel.type NOT IN ('has_contributor', 'has_nominee', 'has_winner')`
 
 	fn := func(stmt *sqlite.Stmt) error {
 		r := sirkulator.RelationExp{
@@ -736,15 +796,54 @@ func GetImage(conn *sqlite.Conn, id string) (*sirkulator.Image, error) {
 	return &img, nil
 }
 
-func UpdateResource(conn *sqlite.Conn, res sirkulator.Resource, label string) (err error) {
+// CreateResource stores the given new resource, with its links. The resource
+// must have an ID, typically from sirkulator.GetNewID.
+func CreateResource(conn *sqlite.Conn, res sirkulator.Resource, actor string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if res.ID == "" {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "resource must have an ID")
+	}
+	now := time.Now()
+	if err := RecordEdit(conn, res, actor, now); err != nil {
+		return fmt.Errorf("sql.CreateResource(%s): %w", res.ID, err)
+	}
+	b, err := json.Marshal(res.Data)
+	if err != nil {
+		return fmt.Errorf("sql.CreateResource(%s): %w", res.ID, err)
+	}
+	const q = `INSERT INTO resource (type, id, label, data, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	if err := sqlitex.Exec(conn, q, nil, res.Type.String(), res.ID, res.Label, b, now.Unix(), now.Unix()); err != nil {
+		if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_PRIMARYKEY {
+			return sirkulator.Errorf(sirkulator.CodeConflict, "resource %s already exists", res.ID)
+		}
+		return fmt.Errorf("sql.CreateResource(%s): %w", res.ID, err)
+	}
+	if err := SetLinks(conn, res.ID, res.Links); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateResource updates the label and data of the given resource, and records
+// the changes in its edit history, with the given actor as author.
+func UpdateResource(conn *sqlite.Conn, res sirkulator.Resource, label, actor string) (err error) {
 	defer sqlitex.Save(conn)(&err)
+
+	now := time.Now()
+	res.Label = label
+	if err := RecordEdit(conn, res, actor, now); err != nil {
+		return err
+	}
+
 	stmt := conn.Prep(`
             UPDATE resource SET data=$data, label=$label, updated_at=$updated_at
             WHERE id=$id
         `)
 
 	stmt.SetText("$id", res.ID)
-	stmt.SetInt64("$updated_at", time.Now().Unix())
+	stmt.SetInt64("$updated_at", now.Unix())
 	stmt.SetText("$label", label)
 	b, err := json.Marshal(res.Data)
 	if err != nil {
@@ -757,6 +856,59 @@ func UpdateResource(conn *sqlite.Conn, res sirkulator.Resource, label string) (e
 	return nil
 }
 
+// ArchiveResource archives the resource with the given ID, and records it in its
+// edit history, with the given actor as author. Archived resources are kept,
+// but not shown in search results by default. Archiving an archived resource
+// does nothing.
+func ArchiveResource(conn *sqlite.Conn, id, actor string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := setArchived(conn, id, actor, true); err != nil {
+		if errors.Is(err, sirkulator.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("sql.ArchiveResource(%s): %w", id, err)
+	}
+	return nil
+}
+
+// setArchived archives or unarchives the resource with the given ID, and
+// records the change in its edit history, unless it already was.
+func setArchived(conn *sqlite.Conn, id, actor string, archived bool) error {
+	var wasArchived, found bool
+	fn := func(stmt *sqlite.Stmt) error {
+		found = true
+		wasArchived = stmt.ColumnType(0) != sqlite.SQLITE_NULL
+		return nil
+	}
+	if err := sqlitex.Exec(conn, "SELECT archived_at FROM resource WHERE id=?", fn, id); err != nil {
+		return err
+	}
+	if !found {
+		return sirkulator.ErrNotFound
+	}
+	if wasArchived == archived {
+		return nil
+	}
+
+	now := time.Now()
+	var archivedAt any // NULL when unarchiving
+	if archived {
+		archivedAt = now.Unix()
+	}
+	const q = "UPDATE resource SET archived_at=?, updated_at=? WHERE id=?"
+	if err := sqlitex.Exec(conn, q, nil, archivedAt, now.Unix(), id); err != nil {
+		return err
+	}
+	diff := sirkulator.ResourceDiff{
+		Archived: &sirkulator.FieldChange{
+			Old: json.RawMessage(strconv.FormatBool(wasArchived)),
+			New: json.RawMessage(strconv.FormatBool(archived)),
+		},
+	}
+	return insertEdit(conn, id, actor, now, diff)
+}
+
 func GetResourceTexts(conn *sqlite.Conn, id string) ([]sirkulator.ResourceText, error) {
 	var res []sirkulator.ResourceText
 
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+func TestCreateResource(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	res := sirkulator.Resource{
+		ID:    "p1",
+		Type:  sirkulator.TypePerson,
+		Label: "Undset, Sigrid",
+		Links: [][2]string{{"viaf", "19679452"}},
+		Data:  sirkulator.Person{Name: "Undset, Sigrid"},
+	}
+	if err := CreateResource(conn, res, "kari"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetResource(conn, sirkulator.TypePerson, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Label != res.Label || got.Data.(*sirkulator.Person).Name != "Undset, Sigrid" || len(got.Links) != 1 {
+		t.Errorf("GetResource(p1) = %+v; want created resource", got)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("created_at not set")
+	}
+
+	if err := CreateResource(conn, res, "kari"); !errors.Is(err, sirkulator.ErrConflict) {
+		t.Errorf("creating resource with existing ID: got %v; want conflict", err)
+	}
+}
+
+func TestGetSeriesPublications(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('s1', 'series', 'Ulvegutten Tal', '{}', 0, 0),
+			       ('b1', 'publication', 'Den glemte byen', '{"year": 2017}', 0, 0),
+			       ('b2', 'publication', 'Ulvegutten', '{"year": 2010}', 0, 0),
+			       ('b3', 'publication', 'Tal og Nea', '{"year": 2012}', 0, 0),
+			       ('b4', 'publication', 'Ekstra', '{"year": 2020}', 0, 0);
+		INSERT INTO resource (id, type, label, data, created_at, updated_at, archived_at)
+			VALUES ('b5', 'publication', 'Den glemte byen', '{"year": 2017}', 0, 0, 1);
+		INSERT INTO relation (from_id, to_id, type, data)
+			VALUES ('b1', 's1', 'in_series', '{"number": 8}'),
+			       ('b2', 's1', 'in_series', '{"number": "bind 1"}'),
+			       ('b3', 's1', 'in_series', '{"number": 2}'),
+			       ('b4', 's1', 'in_series', NULL),
+			       ('b5', 's1', 'in_series', '{"number": 8}');`); err != nil {
+		t.Fatal(err)
+	}
+
+	pubs, err := GetSeriesPublications(conn, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pubs {
+		got = append(got, p.ID+":"+p.Number)
+	}
+	want := []string{"b2:bind 1", "b3:2", "b1:8", "b4:"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GetSeriesPublications = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"github.com/knakk/sirkulator/vocab"
+)
+
+// GetReview returns the unresolved review (relation without to_id) with
+// the given ID, along with the resource it goes from.
+func GetReview(conn *sqlite.Conn, id int64) (sirkulator.RelationExp, error) {
+	rel, err := GetRelation(conn, id)
+	if err != nil {
+		return sirkulator.RelationExp{}, err
+	}
+	if rel.ToID != "" {
+		return sirkulator.RelationExp{}, sirkulator.Errorf(sirkulator.CodeNotFound, "review %d is already resolved", id)
+	}
+	from, err := GetSimpleResource(conn, rel.FromID)
+	if err != nil {
+		return sirkulator.RelationExp{}, err
+	}
+	label, _ := rel.Data["label"].(string)
+	return sirkulator.RelationExp{Relation: rel, Label: label, From: from}, nil
+}
+
+// GetReviewCandidates returns the resources which have a link (identifier)
+// matching any of the values in the data of the review with the given ID, or
+// the same label as the review, ignoring case, and which the review can be
+// resolved to given its relation type. Archived resources are not included.
+func GetReviewCandidates(conn *sqlite.Conn, id int64) ([]sirkulator.SimpleResource, error) {
+	review, err := GetReview(conn, id)
+	if err != nil {
+		return nil, err
+	}
+	relType := vocab.ParseRelation(review.Type)
+	fromType := review.From.Type.String()
+
+	var res []sirkulator.SimpleResource
+	fn := func(stmt *sqlite.Stmt) error {
+		if !relType.Allowed(fromType, stmt.ColumnText(1)) {
+			return nil
+		}
+		res = append(res, sirkulator.SimpleResource{
+			ID:    stmt.ColumnText(0),
+			Type:  sirkulator.ParseResourceType(stmt.ColumnText(1)),
+			Label: stmt.ColumnText(2),
+		})
+		return nil
+	}
+	const q = `
+		SELECT DISTINCT r.id, r.type, r.label
+		  FROM relation rel, resource r
+		 WHERE rel.id=?
+		   AND (r.id IN (
+		          SELECT l.resource_id
+		            FROM json_each(rel.data) d
+		            JOIN link l ON (l.id=d.value)
+		           WHERE d.type='text')
+		        OR lower(r.label)=lower(json_extract(rel.data, '$.label')))
+		   AND r.id != rel.from_id
+		   AND r.archived_at IS NULL
+		 ORDER BY r.label`
+	if err := sqlitex.Exec(conn, q, fn, id); err != nil {
+		return res, fmt.Errorf("sql.GetReviewCandidates(%d): %w", id, err)
+	}
+	return res, nil
+}
+
+// recordReviewResolution stores how the given review was resolved.
+func recordReviewResolution(conn *sqlite.Conn, review sirkulator.Relation, action, toID, actor string) error {
+	stmt := conn.Prep(`
+		INSERT INTO review_resolution (relation_id, from_id, type, data, action, to_id, actor, resolved_at)
+			VALUES ($relation_id, $from_id, $type, $data, $action, $to_id, $actor, $resolved_at)`)
+	stmt.SetInt64("$relation_id", review.ID)
+	stmt.SetText("$from_id", review.FromID)
+	stmt.SetText("$type", review.Type)
+	if review.Data == nil {
+		stmt.SetNull("$data")
+	} else {
+		b, err := json.Marshal(review.Data)
+		if err != nil {
+			return err
+		}
+		stmt.SetBytes("$data", b)
+	}
+	stmt.SetText("$action", action)
+	if toID == "" {
+		stmt.SetNull("$to_id")
+	} else {
+		stmt.SetText("$to_id", toID)
+	}
+	stmt.SetText("$actor", actor)
+	stmt.SetInt64("$resolved_at", time.Now().Unix())
+	_, err := stmt.Step()
+	return err
+}
+
+// ResolveReview resolves the review with the given ID by connecting it to
+// the existing resource with ID toID.
+func ResolveReview(conn *sqlite.Conn, id int64, toID, actor string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	return resolveReview(conn, id, toID, sirkulator.ReviewLinked, actor)
+}
+
+// ResolveReviewWithResource resolves the review with the given ID by creating
+// the given resource and connecting the review to it.
+func ResolveReviewWithResource(conn *sqlite.Conn, id int64, res sirkulator.Resource, actor string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := CreateResource(conn, res, actor); err != nil {
+		return err
+	}
+	return resolveReview(conn, id, res.ID, sirkulator.ReviewCreated, actor)
+}
+
+func resolveReview(conn *sqlite.Conn, id int64, toID, action, actor string) error {
+	review, err := GetReview(conn, id)
+	if err != nil {
+		return err
+	}
+	if toID == "" {
+		return sirkulator.Errorf(sirkulator.CodeInvalid, "no resource to connect review to")
+	}
+	if err := recordReviewResolution(conn, review.Relation, action, toID, actor); err != nil {
+		return fmt.Errorf("sql.ResolveReview(%d): %w", id, err)
+	}
+	review.ToID = toID
+	return UpdateRelation(conn, review.Relation)
+}
+
+// DismissReview resolves the review with the given ID by deleting it.
+func DismissReview(conn *sqlite.Conn, id int64, actor string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	review, err := GetReview(conn, id)
+	if err != nil {
+		return err
+	}
+	if err := recordReviewResolution(conn, review.Relation, sirkulator.ReviewDismissed, "", actor); err != nil {
+		return fmt.Errorf("sql.DismissReview(%d): %w", id, err)
+	}
+	return DeleteRelation(conn, id)
+}
+
+// GetReviewResolutions returns the most recent review resolutions, newest first.
+func GetReviewResolutions(conn *sqlite.Conn, limit int) ([]sirkulator.ReviewResolution, error) {
+	var res []sirkulator.ReviewResolution
+	fn := func(stmt *sqlite.Stmt) error {
+		r := sirkulator.ReviewResolution{
+			ID:         stmt.ColumnInt64(0),
+			RelationID: stmt.ColumnInt64(1),
+			Type:       stmt.ColumnText(2),
+			Action:     stmt.ColumnText(4),
+			From: sirkulator.SimpleResource{
+				ID:    stmt.ColumnText(5),
+				Type:  sirkulator.ParseResourceType(stmt.ColumnText(6)),
+				Label: stmt.ColumnText(7),
+			},
+			To: sirkulator.SimpleResource{
+				ID:    stmt.ColumnText(8),
+				Type:  sirkulator.ParseResourceType(stmt.ColumnText(9)),
+				Label: stmt.ColumnText(10),
+			},
+			Actor: stmt.ColumnText(11),
+			At:    time.Unix(stmt.ColumnInt64(12), 0),
+		}
+		if data := stmt.ColumnText(3); data != "" {
+			if err := json.Unmarshal([]byte(data), &r.Data); err != nil {
+				return err
+			}
+		}
+		res = append(res, r)
+		return nil
+	}
+	const q = `
+		SELECT rr.id, rr.relation_id, rr.type, rr.data, rr.action,
+		       rr.from_id, f.type, f.label,
+		       coalesce(rr.to_id, ''), coalesce(t.type, ''), coalesce(t.label, ''),
+		       rr.actor, rr.resolved_at
+		  FROM review_resolution rr
+		  LEFT JOIN resource f ON (f.id=rr.from_id)
+		  LEFT JOIN resource t ON (t.id=rr.to_id)
+		 ORDER BY rr.id DESC
+		 LIMIT ?`
+	if err := sqlitex.Exec(conn, q, fn, limit); err != nil {
+		return res, fmt.Errorf("sql.GetReviewResolutions(%d): %w", limit, err)
+	}
+	return res, nil
+}
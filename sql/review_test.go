@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+)
+
+func TestReviews(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	if err := sqlitex.ExecScript(conn, `
+		INSERT INTO resource (id, type, label, data, created_at, updated_at)
+			VALUES ('p1', 'person', 'Hamsun, Knut', '{}', 0, 0),
+			       ('c1', 'corporation', 'Gyldendal', '{}', 0, 0),
+			       ('b1', 'publication', 'Sult', '{}', 0, 0);
+		INSERT INTO link (resource_id, type, id) VALUES ('p1', 'viaf', '12345'), ('b1', 'isbn', '12345');`); err != nil {
+		t.Fatal(err)
+	}
+
+	newReview := func(data map[string]any) int64 {
+		t.Helper()
+		rel, err := CreateRelation(conn, sirkulator.Relation{FromID: "b1", Type: "has_contributor", Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rel.ID
+	}
+
+	// Candidates by identifier, excluding the publication itself, which
+	// cannot be a contributor.
+	linked := newReview(map[string]any{"label": "Hamsun", "role": "aut", "viaf": "12345"})
+	candidates, err := GetReviewCandidates(conn, linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].ID != "p1" {
+		t.Errorf("GetReviewCandidates = %v; want [p1]", candidates)
+	}
+	if err := ResolveReview(conn, linked, "c1x", "kari"); !errors.Is(err, sirkulator.ErrInvalid) {
+		t.Errorf("resolving review to missing resource: got %v; want invalid", err)
+	}
+	if err := ResolveReview(conn, linked, "p1", "kari"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveReview(conn, linked, "p1", "kari"); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("resolving review twice: got %v; want not found", err)
+	}
+
+	created := newReview(map[string]any{"label": "Nygaard, William", "role": "edt"})
+	res := sirkulator.Resource{
+		ID:    "p2",
+		Type:  sirkulator.TypePerson,
+		Label: "Nygaard, William",
+		Data:  sirkulator.Person{Name: "Nygaard, William"},
+	}
+	if err := ResolveReviewWithResource(conn, created, res, "kari"); err != nil {
+		t.Fatal(err)
+	}
+	if rel, _ := GetRelation(conn, created); rel.ToID != "p2" || rel.Data["role"] != "edt" {
+		t.Errorf("review resolved with new resource = %+v; want to p2 with role edt", rel)
+	}
+
+	dismissed := newReview(map[string]any{"label": "Ukjent"})
+	if err := DismissReview(conn, dismissed, "ola"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetRelation(conn, dismissed); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("dismissed review still exists: %v", err)
+	}
+
+	if reviews, _ := GetAllReviews(conn, 10); len(reviews) != 0 {
+		t.Errorf("got %d unresolved reviews; want 0", len(reviews))
+	}
+
+	resolutions, err := GetReviewResolutions(conn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ action, to, actor string }{
+		{sirkulator.ReviewDismissed, "", "ola"},
+		{sirkulator.ReviewCreated, "p2", "kari"},
+		{sirkulator.ReviewLinked, "p1", "kari"},
+	}
+	if len(resolutions) != len(want) {
+		t.Fatalf("got %d resolutions; want %d", len(resolutions), len(want))
+	}
+	for i, w := range want {
+		got := resolutions[i]
+		if got.Action != w.action || got.To.ID != w.to || got.Actor != w.actor || got.From.ID != "b1" {
+			t.Errorf("resolution %d = %+v; want %s to %q by %s", i, got, w.action, w.to, w.actor)
+		}
+	}
+	if resolutions[0].Data["label"] != "Ukjent" {
+		t.Errorf("dismissed review data not kept: %v", resolutions[0].Data)
+	}
+}
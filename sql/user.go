@@ -0,0 +1,262 @@
+package sql
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/knakk/sirkulator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const userColumns = "id, username, name, role, created_at, updated_at"
+
+func readUsers(res *[]sirkulator.User) func(stmt *sqlite.Stmt) error {
+	return func(stmt *sqlite.Stmt) error {
+		*res = append(*res, sirkulator.User{
+			ID:        stmt.ColumnInt64(0),
+			Username:  stmt.ColumnText(1),
+			Name:      stmt.ColumnText(2),
+			Role:      sirkulator.Role(stmt.ColumnText(3)),
+			CreatedAt: time.Unix(stmt.ColumnInt64(4), 0),
+			UpdatedAt: time.Unix(stmt.ColumnInt64(5), 0),
+		})
+		return nil
+	}
+}
+
+// MinPasswordLength is the minimum number of characters in a user's password.
+const MinPasswordLength = 8
+
+func hashPassword(password string) (string, error) {
+	if len([]rune(password)) < MinPasswordLength {
+		return "", sirkulator.Errorf(sirkulator.CodeInvalid, "password must be at least %d characters", MinPasswordLength)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", sirkulator.Errorf(sirkulator.CodeInvalid, "invalid password: %v", err)
+	}
+	return string(b), nil
+}
+
+// SaveUser persists the given user, creating it if ID is 0, otherwise updating it.
+// The password is required when creating a user; when updating, an empty
+// password leaves the existing password unchanged.
+func SaveUser(conn *sqlite.Conn, user sirkulator.User, password string) (sirkulator.User, error) {
+	if user.Username == "" {
+		return user, sirkulator.Errorf(sirkulator.CodeInvalid, "username is required")
+	}
+	if !user.Role.Valid() {
+		return user, sirkulator.Errorf(sirkulator.CodeInvalid, "unknown role: %q", user.Role)
+	}
+	var hash string
+	if password != "" || user.ID == 0 {
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return user, err
+		}
+	}
+
+	now := time.Now()
+	var stmt *sqlite.Stmt
+	if user.ID == 0 {
+		stmt = conn.Prep(`
+			INSERT INTO user (username, name, password_hash, role, created_at, updated_at)
+				VALUES ($username, $name, $password_hash, $role, $now, $now)
+			RETURNING id`)
+	} else {
+		stmt = conn.Prep(`
+			UPDATE user
+			   SET username=$username,
+			       name=$name,
+			       password_hash=IIF($password_hash = '', password_hash, $password_hash),
+			       role=$role,
+			       updated_at=$now
+			 WHERE id=$id
+			RETURNING id`)
+		stmt.SetInt64("$id", user.ID)
+	}
+	stmt.SetText("$username", user.Username)
+	stmt.SetText("$name", user.Name)
+	stmt.SetText("$password_hash", hash)
+	stmt.SetText("$role", string(user.Role))
+	stmt.SetInt64("$now", now.Unix())
+	id, err := sqlitex.ResultInt64(stmt)
+	if sqlite.ErrCode(err) == sqlite.SQLITE_CONSTRAINT_UNIQUE {
+		return user, sirkulator.Errorf(sirkulator.CodeConflict, "username %s is already in use", user.Username)
+	} else if errors.Is(err, sqlitex.ErrNoResults) {
+		return user, sirkulator.ErrNotFound
+	} else if err != nil {
+		return user, fmt.Errorf("sql.SaveUser(%q): %w", user.Username, err)
+	}
+	if user.ID == 0 {
+		user.CreatedAt = time.Unix(now.Unix(), 0)
+	}
+	user.ID = id
+	user.UpdatedAt = time.Unix(now.Unix(), 0)
+	return user, nil
+}
+
+// GetUser returns the user with the given ID.
+func GetUser(conn *sqlite.Conn, id int64) (sirkulator.User, error) {
+	var res []sirkulator.User
+	q := "SELECT " + userColumns + " FROM user WHERE id=?"
+	if err := sqlitex.Exec(conn, q, readUsers(&res), id); err != nil {
+		return sirkulator.User{}, fmt.Errorf("sql.GetUser(%d): %w", id, err)
+	}
+	if len(res) == 0 {
+		return sirkulator.User{}, sirkulator.ErrNotFound
+	}
+	return res[0], nil
+}
+
+// GetUsers returns all users, ordered by username.
+func GetUsers(conn *sqlite.Conn) ([]sirkulator.User, error) {
+	var res []sirkulator.User
+	q := "SELECT " + userColumns + " FROM user ORDER BY username"
+	if err := sqlitex.Exec(conn, q, readUsers(&res)); err != nil {
+		return res, fmt.Errorf("sql.GetUsers: %w", err)
+	}
+	return res, nil
+}
+
+// DeleteUser deletes the user with the given ID, and all its sessions.
+func DeleteUser(conn *sqlite.Conn, id int64) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err := sqlitex.Exec(conn, "DELETE FROM session WHERE user_id=?", nil, id); err != nil {
+		return fmt.Errorf("sql.DeleteUser(%d): %w", id, err)
+	}
+	if err := sqlitex.Exec(conn, "DELETE FROM user WHERE id=?", nil, id); err != nil {
+		return fmt.Errorf("sql.DeleteUser(%d): %w", id, err)
+	}
+	return nil
+}
+
+// Authenticate returns the user with the given username and password, or
+// an error with code CodeUnauthorized if there is no such user or the
+// password is wrong.
+func Authenticate(conn *sqlite.Conn, username, password string) (sirkulator.User, error) {
+	var res []sirkulator.User
+	var hash string
+	fn := func(stmt *sqlite.Stmt) error {
+		hash = stmt.ColumnText(6)
+		return readUsers(&res)(stmt)
+	}
+	q := "SELECT " + userColumns + ", password_hash FROM user WHERE username=?"
+	if err := sqlitex.Exec(conn, q, fn, username); err != nil {
+		return sirkulator.User{}, fmt.Errorf("sql.Authenticate(%q): %w", username, err)
+	}
+	if len(res) == 0 {
+		// Compare with a dummy hash anyway, so that response time does not reveal
+		// whether the username exists.
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return sirkulator.User{}, sirkulator.Errorf(sirkulator.CodeUnauthorized, "wrong username or password")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return sirkulator.User{}, sirkulator.Errorf(sirkulator.CodeUnauthorized, "wrong username or password")
+	}
+	return res[0], nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("sirkulator"), bcrypt.DefaultCost)
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateSession creates a new session for the user with the given ID, lasting
+// for sirkulator.SessionDuration. Expired sessions of all users are deleted.
+func CreateSession(conn *sqlite.Conn, userID int64) (s sirkulator.Session, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	now := time.Now()
+	if err := sqlitex.Exec(conn, "DELETE FROM session WHERE expires_at < ?", nil, now.Unix()); err != nil {
+		return s, fmt.Errorf("sql.CreateSession(%d): %w", userID, err)
+	}
+
+	s = sirkulator.Session{
+		UserID:    userID,
+		CreatedAt: time.Unix(now.Unix(), 0),
+		ExpiresAt: time.Unix(now.Add(sirkulator.SessionDuration).Unix(), 0),
+	}
+	if s.Token, err = randomToken(); err != nil {
+		return s, fmt.Errorf("sql.CreateSession(%d): %w", userID, err)
+	}
+	if s.CSRFToken, err = randomToken(); err != nil {
+		return s, fmt.Errorf("sql.CreateSession(%d): %w", userID, err)
+	}
+
+	stmt := conn.Prep(`
+		INSERT INTO session (token_hash, user_id, csrf_token, created_at, expires_at)
+			VALUES ($token_hash, $user_id, $csrf_token, $created_at, $expires_at)`)
+	stmt.SetText("$token_hash", hashToken(s.Token))
+	stmt.SetInt64("$user_id", userID)
+	stmt.SetText("$csrf_token", s.CSRFToken)
+	stmt.SetInt64("$created_at", s.CreatedAt.Unix())
+	stmt.SetInt64("$expires_at", s.ExpiresAt.Unix())
+	if _, err := stmt.Step(); err != nil {
+		return s, fmt.Errorf("sql.CreateSession(%d): %w", userID, err)
+	}
+	return s, nil
+}
+
+// GetSession returns the session with the given token, along with its user.
+// If the session does not exist or has expired, sirkulator.ErrNotFound is returned.
+func GetSession(conn *sqlite.Conn, token string, now time.Time) (sirkulator.Session, sirkulator.User, error) {
+	var s sirkulator.Session
+	var users []sirkulator.User
+	fn := func(stmt *sqlite.Stmt) error {
+		s.UserID = stmt.ColumnInt64(6)
+		s.CSRFToken = stmt.ColumnText(7)
+		s.CreatedAt = time.Unix(stmt.ColumnInt64(8), 0)
+		s.ExpiresAt = time.Unix(stmt.ColumnInt64(9), 0)
+		return readUsers(&users)(stmt)
+	}
+	const q = `
+		SELECT user.id, user.username, user.name, user.role, user.created_at, user.updated_at,
+		       session.user_id, session.csrf_token, session.created_at, session.expires_at
+		  FROM session JOIN user ON (user.id = session.user_id)
+		 WHERE session.token_hash=? AND session.expires_at > ?`
+	if err := sqlitex.Exec(conn, q, fn, hashToken(token), now.Unix()); err != nil {
+		return s, sirkulator.User{}, fmt.Errorf("sql.GetSession: %w", err)
+	}
+	if len(users) == 0 {
+		return s, sirkulator.User{}, sirkulator.ErrNotFound
+	}
+	s.Token = token
+	return s, users[0], nil
+}
+
+// DeleteSession deletes the session with the given token.
+func DeleteSession(conn *sqlite.Conn, token string) error {
+	if err := sqlitex.Exec(conn, "DELETE FROM session WHERE token_hash=?", nil, hashToken(token)); err != nil {
+		return fmt.Errorf("sql.DeleteSession: %w", err)
+	}
+	return nil
+}
+
+// CountUsers returns the number of users.
+func CountUsers(conn *sqlite.Conn) (int, error) {
+	stmt := conn.Prep("SELECT count(*) FROM user")
+	n, err := sqlitex.ResultInt64(stmt)
+	if err != nil {
+		return 0, fmt.Errorf("sql.CountUsers: %w", err)
+	}
+	return int(n), nil
+}
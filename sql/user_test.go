@@ -0,0 +1,76 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/knakk/sirkulator"
+)
+
+func TestUsersAndSessions(t *testing.T) {
+	db, err := OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := db.Get(nil)
+	defer db.Put(conn)
+
+	var sErr *sirkulator.Error
+	if _, err := SaveUser(conn, sirkulator.User{Username: "kari", Role: sirkulator.RoleCirculation}, "short"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeInvalid {
+		t.Errorf("SaveUser with short password got %v; want invalid", err)
+	}
+	if _, err := SaveUser(conn, sirkulator.User{Username: "kari", Role: "boss"}, "secret123"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeInvalid {
+		t.Errorf("SaveUser with unknown role got %v; want invalid", err)
+	}
+	user, err := SaveUser(conn, sirkulator.User{Username: "kari", Name: "Kari", Role: sirkulator.RoleCirculation}, "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveUser(conn, sirkulator.User{Username: "kari", Role: sirkulator.RoleAdmin}, "secret123"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeConflict {
+		t.Errorf("SaveUser with duplicate username got %v; want conflict", err)
+	}
+
+	if _, err := Authenticate(conn, "kari", "wrong"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeUnauthorized {
+		t.Errorf("Authenticate with wrong password got %v; want unauthorized", err)
+	}
+	if _, err := Authenticate(conn, "ola", "secret123"); !errors.As(err, &sErr) || sErr.Code != sirkulator.CodeUnauthorized {
+		t.Errorf("Authenticate with unknown user got %v; want unauthorized", err)
+	}
+	if got, err := Authenticate(conn, "kari", "secret123"); err != nil || got.ID != user.ID {
+		t.Fatalf("Authenticate = %+v, %v; want %+v", got, err, user)
+	}
+
+	// Updating without password keeps the existing one.
+	user.Role = sirkulator.RoleCataloguer
+	if _, err := SaveUser(conn, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Authenticate(conn, "kari", "secret123"); err != nil || got.Role != sirkulator.RoleCataloguer {
+		t.Errorf("Authenticate after update = %+v, %v; want cataloguer", got, err)
+	}
+
+	session, err := CreateSession(conn, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Token == "" || session.CSRFToken == "" {
+		t.Fatalf("CreateSession = %+v; want tokens", session)
+	}
+	got, gotUser, err := GetSession(conn, session.Token, time.Now())
+	if err != nil || got.CSRFToken != session.CSRFToken || gotUser.Username != "kari" {
+		t.Errorf("GetSession = %+v, %+v, %v; want session of kari", got, gotUser, err)
+	}
+	if _, _, err := GetSession(conn, session.Token, session.ExpiresAt.Add(time.Second)); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("GetSession after expiry got %v; want ErrNotFound", err)
+	}
+
+	if err := DeleteUser(conn, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetSession(conn, session.Token, time.Now()); !errors.Is(err, sirkulator.ErrNotFound) {
+		t.Errorf("GetSession after deleting user got %v; want ErrNotFound", err)
+	}
+}
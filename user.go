@@ -0,0 +1,61 @@
+package sirkulator
+
+import "time"
+
+// Role determines which parts of the staff interface a User has access to.
+type Role string
+
+const (
+	RoleCataloguer  Role = "cataloguer"  // metadata and holdings
+	RoleCirculation Role = "circulation" // circulation and patrons
+	RoleAdmin       Role = "admin"       // everything, including maintenance and user administration
+)
+
+// Roles are all the available roles.
+var Roles = []Role{RoleCataloguer, RoleCirculation, RoleAdmin}
+
+// Valid reports whether the role is one of the defined Roles.
+func (r Role) Valid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// User is a staff user account.
+type User struct {
+	ID        int64
+	Username  string
+	Name      string
+	Role      Role
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Can reports whether the user has access to parts of the system requiring
+// any of the given roles. Admins have access to everything.
+func (u User) Can(roles ...Role) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, r := range roles {
+		if u.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is an authenticated session of a User.
+type Session struct {
+	Token     string // only known when the session is created, the hash is stored
+	UserID    int64
+	CSRFToken string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionDuration is how long a session lasts before the user must log in again.
+const SessionDuration = 12 * time.Hour
@@ -39,6 +39,12 @@ type Identifier struct {
 	URL   string // Optional
 }
 
+// IsIdentifier reports whether the given code is a known identifier type.
+func IsIdentifier(code string) bool {
+	_, found := identifiers[code]
+	return found
+}
+
 // ParseIdentifier creates an Identifier from the give code and value.
 // If the code is not known, the Identifier Label will be set as the code,
 // and the URL will be empty.
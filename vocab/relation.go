@@ -22,6 +22,9 @@ const (
 	RelationHasClassification Relation = "has_classification" // has_dewey?
 	RelationSubsidiaryOf      Relation = "subsidiary_of"      // TODO has_parent is enough?
 	RelationImprintOf         Relation = "imprint_of"
+	RelationHasNominee        Relation = "has_nominee"
+	RelationHasWinner         Relation = "has_winner"
+	RelationOrganizedBy       Relation = "organized_by"
 	// TODO:
 	// - followed_by
 	// - derived_from
@@ -38,8 +41,89 @@ var relationLabels = map[string][4]string{
 	"has_parent":         {"Has parent", "Hører til under", "Is parent of", "Er overordnet"}, //  TODO norwegian label sounds odd
 	"has_part":           {"Has part", "Inneholder del", "Is part of", "Er del av"},
 	"has_classification": {"Has classification", "Klassifisert som", "Is classification of", "Er klassifikasjon for"},
-	"subsidiary of":      {"Subsidiary of", "Datterselskap av", "Has subsidiary", "Har datterselskap"},
+	"subsidiary_of":      {"Subsidiary of", "Datterselskap av", "Has subsidiary", "Har datterselskap"},
 	"imprint_of":         {"Imprint of", "Imprint under", "Has imprint", "Har imprint"},
+	"has_nominee":        {"Has nominee", "Har nominert", "Nominated for", "Nominert til"},
+	"has_winner":         {"Has winner", "Har vinner", "Winner of", "Vinner av"},
+	"organized_by":       {"Organized by", "Deles ut av", "Organizer of", "Deler ut"},
+}
+
+// relationDomains lists the resource types which a relation can go from,
+// and the resource types it can go to.
+var relationDomains = map[Relation][2][]string{
+	RelationHasContributor:    {{"publication"}, {"person", "corporation"}},
+	RelationHasSubject:        {{"publication"}, {"person", "corporation", "publisher", "publication"}},
+	RelationPublishedBy:       {{"publication"}, {"publisher"}},
+	RelationInSeries:          {{"publication"}, {"series"}},
+	RelationHasParent:         {{"corporation", "publisher", "dewey"}, {"corporation", "publisher", "dewey"}},
+	RelationHasPart:           {{"publication", "dewey"}, {"publication", "dewey"}},
+	RelationHasClassification: {{"publication"}, {"dewey"}},
+	RelationSubsidiaryOf:      {{"corporation", "publisher"}, {"corporation", "publisher"}},
+	RelationImprintOf:         {{"publisher"}, {"publisher", "corporation"}},
+	RelationHasNominee:        {{"literary_award"}, {"person", "publication"}},
+	RelationHasWinner:         {{"literary_award"}, {"person", "publication"}},
+	RelationOrganizedBy:       {{"literary_award"}, {"corporation"}},
+}
+
+var allRelations = []Relation{
+	RelationHasContributor,
+	RelationHasSubject,
+	RelationPublishedBy,
+	RelationInSeries,
+	RelationHasParent,
+	RelationHasPart,
+	RelationHasClassification,
+	RelationSubsidiaryOf,
+	RelationImprintOf,
+	RelationHasNominee,
+	RelationHasWinner,
+	RelationOrganizedBy,
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedFrom reports whether the relation can go from a resource of the given type.
+func (r Relation) AllowedFrom(fromType string) bool {
+	return contains(relationDomains[r][0], fromType)
+}
+
+// Allowed reports whether the relation can go from a resource of type
+// fromType to a resource of type toType.
+func (r Relation) Allowed(fromType, toType string) bool {
+	return contains(relationDomains[r][0], fromType) && contains(relationDomains[r][1], toType)
+}
+
+// Targets returns the resource types which the relation can go to
+// from a resource of the given type.
+func (r Relation) Targets(fromType string) []string {
+	if !r.AllowedFrom(fromType) {
+		return nil
+	}
+	return relationDomains[r][1]
+}
+
+// RelationOptions returns the relations which can go from a resource
+// of the given type, as pairs of relation and localized label.
+func RelationOptions(lang language.Tag, fromType string) (res [][2]string) {
+	for _, r := range allRelations {
+		if r.AllowedFrom(fromType) {
+			res = append(res, [2]string{string(r), r.Label(lang)})
+		}
+	}
+	return res
+}
+
+// Yearly reports whether the relation must state the year it applies to,
+// as for nominations and winners of literary awards.
+func (r Relation) Yearly() bool {
+	return r == RelationHasNominee || r == RelationHasWinner
 }
 
 func ParseRelation(s string) Relation {
@@ -62,6 +146,12 @@ func ParseRelation(s string) Relation {
 		return RelationSubsidiaryOf
 	case "imprint_of":
 		return RelationImprintOf
+	case "has_nominee":
+		return RelationHasNominee
+	case "has_winner":
+		return RelationHasWinner
+	case "organized_by":
+		return RelationOrganizedBy
 	default:
 		return RelationInvalid
 	}